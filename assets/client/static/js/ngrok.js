@@ -304,6 +304,7 @@ ngrok.directive({
 ngrok.controller({
     "HttpTxns": function($scope, txnSvc) {
         $scope.tunnels = window.data.UiState.Tunnels;
+        $scope.server = window.data.UiState.Server;
         $scope.txns = txnSvc.all();
 
         if (!!window.WebSocket) {
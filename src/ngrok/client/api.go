@@ -0,0 +1,370 @@
+package client
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v1"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"ngrok/client/mvc"
+	"ngrok/client/views/web"
+	"ngrok/msg"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// how long starting a tunnel with the api waits for the server to open it
+	apiTunnelTimeout = 10 * time.Second
+
+	// tunnel configurations posted to the api may not be larger than this
+	maxApiBody = 1024 * 1024
+)
+
+// A tunnel as the api shows it
+type apiTunnel struct {
+	Name      string `json:"name"`
+	PublicUrl string `json:"public_url"`
+	Proto     string `json:"proto"`
+	LocalAddr string `json:"local_addr"`
+}
+
+func newApiTunnel(t mvc.Tunnel) apiTunnel {
+	return apiTunnel{
+		Name:      t.Name,
+		PublicUrl: t.PublicUrl,
+		Proto:     strings.SplitN(t.PublicUrl, "://", 2)[0],
+		LocalAddr: t.LocalAddr,
+	}
+}
+
+// An error the api answers with, and its status code
+type apiError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// Serve the JSON api for managing tunnels next to the web interface
+func (ctl *Controller) serveApi(httpView *web.WebHttpView) {
+	handle := func(pattern string, h http.HandlerFunc) {
+		http.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			if err := ctl.checkApiRequest(r); err != nil {
+				writeApiError(w, err)
+				return
+			}
+			h(w, r)
+		})
+	}
+
+	handle("/api/tunnels", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			ctl.listTunnels(w)
+		case "POST":
+			ctl.startTunnel(w, r)
+		default:
+			writeApiError(w, &apiError{http.StatusMethodNotAllowed, "Use GET or POST"})
+		}
+	})
+
+	handle("/api/tunnels/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			writeApiError(w, &apiError{http.StatusMethodNotAllowed, "Use DELETE"})
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, "/api/tunnels/")
+		if err := ctl.GetModel().stopTunnel(name); err != nil {
+			writeApiError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	handle("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		ctl.status(w)
+	})
+
+	handle("/api/requests", func(w http.ResponseWriter, r *http.Request) {
+		requests := make([]interface{}, 0)
+		if httpView != nil {
+			requests = append(requests, httpView.HttpRequests.Slice()...)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"requests": requests})
+	})
+}
+
+// Web pages the user visits can send requests to the api too, so it only
+// answers requests with its token, addressed to it and not to a name that
+// was rebound to it, and not coming from another site
+func (ctl *Controller) checkApiRequest(r *http.Request) error {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(ctl.config.ApiToken)) != 1 {
+		return &apiError{http.StatusUnauthorized, "Missing or wrong api token, send it as 'Authorization: Bearer <api_token>'"}
+	}
+
+	if !apiHost(r.Host, ctl.config.InspectAddr, false) {
+		return &apiError{http.StatusForbidden, fmt.Sprintf("Requests for host %s aren't allowed", r.Host)}
+	}
+
+	// browsers always say where requests that change anything come from,
+	// other clients don't
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !apiHost(u.Host, ctl.config.InspectAddr, true) {
+			return &apiError{http.StatusForbidden, fmt.Sprintf("Requests from %s aren't allowed", origin)}
+		}
+	}
+
+	if r.Method == "POST" {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			return &apiError{http.StatusUnsupportedMediaType, "Send the tunnel as application/json"}
+		}
+	}
+	return nil
+}
+
+// Returns true if host, with or without a port, is the inspect address,
+// localhost or an IP address, only a loopback one if loopback is set. Other
+// names could have been pointed at us.
+func apiHost(host, inspectAddr string, loopback bool) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+
+	if inspectHost, _, _ := net.SplitHostPort(inspectAddr); host == inspectHost || host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && (!loopback || ip.IsLoopback())
+}
+
+func (ctl *Controller) listTunnels(w http.ResponseWriter) {
+	tunnels := make([]apiTunnel, 0)
+	for _, t := range ctl.State().GetTunnels() {
+		tunnels = append(tunnels, newApiTunnel(t))
+	}
+	sort.Sort(apiTunnelsByName(tunnels))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tunnels": tunnels})
+}
+
+// Start a tunnel from a body with its name and the same fields as a tunnel
+// of the configuration file, in JSON
+func (ctl *Controller) startTunnel(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxApiBody))
+	if err != nil {
+		writeApiError(w, &apiError{http.StatusBadRequest, err.Error()})
+		return
+	}
+
+	// JSON is YAML, so the tunnel reads just like the configuration file
+	var named struct {
+		Name string `yaml:"name"`
+	}
+	config := new(TunnelConfiguration)
+	if err = yaml.Unmarshal(body, &named); err == nil {
+		err = yaml.Unmarshal(body, config)
+	}
+	if err != nil {
+		writeApiError(w, &apiError{http.StatusBadRequest, fmt.Sprintf("Invalid tunnel: %v", err)})
+		return
+	}
+
+	if named.Name == "" || strings.Contains(named.Name, "/") {
+		writeApiError(w, &apiError{http.StatusBadRequest, "A tunnel needs a name without slashes"})
+		return
+	}
+
+	opened, err := ctl.GetModel().startTunnel(named.Name, config)
+	if err != nil {
+		writeApiError(w, err)
+		return
+	}
+
+	tunnels := make([]apiTunnel, 0)
+	for _, t := range opened {
+		tunnels = append(tunnels, newApiTunnel(t))
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"tunnels": tunnels})
+}
+
+func (ctl *Controller) status(w http.ResponseWriter) {
+	state := ctl.State()
+
+	var status string
+	switch state.GetConnStatus() {
+	case mvc.ConnConnecting:
+		status = "connecting"
+	case mvc.ConnReconnecting:
+		status = "reconnecting"
+	case mvc.ConnOnline:
+		status = "online"
+	}
+
+	connMeter, connTimer := state.GetConnectionMetrics()
+	bytesInCount, bytesIn := state.GetBytesInMetrics()
+	bytesOutCount, bytesOut := state.GetBytesOutMetrics()
+	percentiles := connTimer.Percentiles([]float64{0.5, 0.9, 0.99})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":         status,
+		"server":         state.GetServerAddr(),
+		"client_version": state.GetClientVersion(),
+		"server_version": state.GetServerVersion(),
+		"metrics": map[string]interface{}{
+			// durations are in nanoseconds
+			"connections": map[string]interface{}{
+				"count":  connMeter.Count(),
+				"rate1":  connMeter.Rate1(),
+				"rate5":  connMeter.Rate5(),
+				"rate15": connMeter.Rate15(),
+				"p50":    percentiles[0],
+				"p90":    percentiles[1],
+				"p99":    percentiles[2],
+			},
+			"bytes_in": map[string]interface{}{
+				"count": bytesInCount.Count(),
+				"mean":  bytesIn.Mean(),
+			},
+			"bytes_out": map[string]interface{}{
+				"count": bytesOutCount.Count(),
+				"mean":  bytesOut.Mean(),
+			},
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeApiError(w http.ResponseWriter, err error) {
+	e, ok := err.(*apiError)
+	if !ok {
+		e = &apiError{http.StatusInternalServerError, err.Error()}
+	}
+	writeJSON(w, e.Status, map[string]*apiError{"error": e})
+}
+
+type apiTunnelsByName []apiTunnel
+
+func (a apiTunnelsByName) Len() int      { return len(a) }
+func (a apiTunnelsByName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a apiTunnelsByName) Less(i, j int) bool {
+	if a[i].Name != a[j].Name {
+		return a[i].Name < a[j].Name
+	}
+	return a[i].PublicUrl < a[j].PublicUrl
+}
+
+// Open a tunnel for the api and wait for the server to open it
+func (c *ClientModel) startTunnel(name string, config *TunnelConfiguration) ([]mvc.Tunnel, error) {
+	if err := config.normalize(name); err != nil {
+		return nil, &apiError{http.StatusBadRequest, err.Error()}
+	}
+
+	// the server answers once for each protocol, http+https is two
+	expected := 0
+	for proto, _ := range config.Protocols {
+		expected += len(strings.Split(proto, "+"))
+	}
+
+	c.mu.Lock()
+	if _, ok := c.tunnelConfig[name]; ok {
+		c.mu.Unlock()
+		return nil, &apiError{http.StatusConflict, fmt.Sprintf("Tunnel %s already exists", name)}
+	}
+
+	if c.ctlConn == nil {
+		c.mu.Unlock()
+		return nil, &apiError{http.StatusServiceUnavailable, "Not connected to the server"}
+	}
+
+	req, reqTunnel := c.requestTunnel(name, config, true)
+	req.opened = make(chan *msg.NewTunnel, expected)
+	c.tunnelConfig[name] = config
+	c.apiTunnels[name] = true
+	ctlConn := c.ctlConn
+	c.mu.Unlock()
+
+	if err := c.send(ctlConn, reqTunnel); err != nil {
+		c.forgetTunnel(name, config)
+		return nil, &apiError{http.StatusServiceUnavailable, fmt.Sprintf("Failed to request tunnel %s: %v", name, err)}
+	}
+
+	timeout := time.After(apiTunnelTimeout)
+	tunnels := make([]mvc.Tunnel, 0)
+	for len(tunnels) < expected {
+		select {
+		case m := <-req.opened:
+			if m.Error != "" {
+				c.forgetTunnel(name, config)
+				return nil, &apiError{http.StatusBadGateway, fmt.Sprintf("Server failed to allocate tunnel: %s", m.Error)}
+			}
+
+			tunnels = append(tunnels, mvc.Tunnel{
+				Name:      name,
+				PublicUrl: m.Url,
+				LocalAddr: config.Protocols[m.Protocol],
+				Protocol:  c.protoMap[m.Protocol],
+			})
+
+		case <-timeout:
+			// if the server opens it later, it's closed again
+			c.forgetTunnel(name, config)
+			return nil, &apiError{http.StatusGatewayTimeout, fmt.Sprintf("Timed out waiting for tunnel %s", name)}
+		}
+	}
+	return tunnels, nil
+}
+
+// Drop a tunnel the api failed to start, along with the protocols of it
+// that did open, so that it can be tried again
+func (c *ClientModel) forgetTunnel(name string, config *TunnelConfiguration) {
+	var msgs []msg.Message
+	c.mu.Lock()
+	if c.tunnelConfig[name] == config {
+		msgs = c.closeTunnels(config)
+		delete(c.tunnelConfig, name)
+		delete(c.apiTunnels, name)
+	}
+	ctlConn := c.ctlConn
+	c.mu.Unlock()
+
+	c.sendChanges(ctlConn, msgs)
+	c.update()
+}
+
+// Close the tunnel called name, whether it was started with the api or by
+// the configuration
+func (c *ClientModel) stopTunnel(name string) error {
+	c.mu.Lock()
+	config, ok := c.tunnelConfig[name]
+	if !ok {
+		c.mu.Unlock()
+		return &apiError{http.StatusNotFound, fmt.Sprintf("No tunnel named %s", name)}
+	}
+
+	msgs := c.closeTunnels(config)
+	delete(c.tunnelConfig, name)
+	delete(c.apiTunnels, name)
+	ctlConn := c.ctlConn
+	c.mu.Unlock()
+
+	c.sendChanges(ctlConn, msgs)
+	c.update()
+	return nil
+}
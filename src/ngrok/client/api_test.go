@@ -0,0 +1,235 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"ngrok/msg"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckApiRequest(t *testing.T) {
+	ctl := &Controller{config: &Configuration{ApiToken: "tok", InspectAddr: "ngrok.local:4040"}}
+
+	cases := []struct {
+		method, host, token, origin, contentType string
+		status                                   int
+	}{
+		{"GET", "127.0.0.1:4040", "tok", "", "", 0},
+		{"GET", "localhost:4040", "tok", "", "", 0},
+		{"GET", "ngrok.local:4040", "tok", "", "", 0},
+		{"GET", "[::1]:4040", "tok", "", "", 0},
+		{"GET", "192.168.1.5:4040", "tok", "", "", 0},
+
+		{"GET", "127.0.0.1:4040", "", "", "", http.StatusUnauthorized},
+		{"GET", "127.0.0.1:4040", "wrong", "", "", http.StatusUnauthorized},
+		{"GET", "127.0.0.1:4040", "tokens", "", "", http.StatusUnauthorized},
+
+		// a name of the attacker's that points at the client
+		{"GET", "evil.example.com:4040", "tok", "", "", http.StatusForbidden},
+
+		{"GET", "127.0.0.1:4040", "tok", "http://127.0.0.1:4040", "", 0},
+		{"GET", "127.0.0.1:4040", "tok", "http://localhost:4040", "", 0},
+		{"GET", "127.0.0.1:4040", "tok", "http://evil.example.com", "", http.StatusForbidden},
+		{"GET", "127.0.0.1:4040", "tok", "http://192.168.1.5:4040", "", http.StatusForbidden},
+		{"GET", "127.0.0.1:4040", "tok", "null", "", http.StatusForbidden},
+
+		{"POST", "127.0.0.1:4040", "tok", "", "application/json", 0},
+		{"POST", "127.0.0.1:4040", "tok", "", "application/json; charset=utf-8", 0},
+		{"POST", "127.0.0.1:4040", "tok", "", "text/plain", http.StatusUnsupportedMediaType},
+		{"POST", "127.0.0.1:4040", "tok", "", "", http.StatusUnsupportedMediaType},
+		{"DELETE", "127.0.0.1:4040", "tok", "", "", 0},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "http://"+c.host+"/api/tunnels", nil)
+		if c.token != "" {
+			r.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		if c.origin != "" {
+			r.Header.Set("Origin", c.origin)
+		}
+		if c.contentType != "" {
+			r.Header.Set("Content-Type", c.contentType)
+		}
+
+		err := ctl.checkApiRequest(r)
+		status := 0
+		if err != nil {
+			status = err.(*apiError).Status
+		}
+		if status != c.status {
+			t.Errorf("%s for %s with token %q from %q as %q: got %d (%v), want %d",
+				c.method, c.host, c.token, c.origin, c.contentType, status, err, c.status)
+		}
+	}
+}
+
+// Answer the next tunnel request the server gets the way the control loop
+// hands answers to the api, with url or with an error if url is empty
+func answerTunnelRequest(c *ClientModel, received chan msg.Message, url string) {
+	go func() {
+		reqTunnel, ok := (<-received).(*msg.ReqTunnel)
+		if !ok {
+			return
+		}
+
+		answer := &msg.NewTunnel{ReqId: reqTunnel.ReqId, Url: url, Protocol: reqTunnel.Protocol}
+		if url == "" {
+			answer.Error = "refused"
+		}
+
+		c.mu.Lock()
+		req := c.requests[reqTunnel.ReqId]
+		c.mu.Unlock()
+		req.opened <- answer
+	}()
+}
+
+// Post a tunnel to the api and decode the answer into v
+func postTunnel(t *testing.T, c *ClientModel, body string, v interface{}) int {
+	ctl := &Controller{model: c}
+	w := httptest.NewRecorder()
+	ctl.startTunnel(w, httptest.NewRequest("POST", "/api/tunnels", strings.NewReader(body)))
+
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("got body %q: %v", w.Body.String(), err)
+	}
+	return w.Code
+}
+
+func TestApiStartTunnel(t *testing.T) {
+	c, received := testModel(t, map[string]*TunnelConfiguration{})
+	answerTunnelRequest(c, received, "http://web.ngrok.me")
+
+	var started struct {
+		Tunnels []apiTunnel `json:"tunnels"`
+	}
+	status := postTunnel(t, c, `{"name": "web", "proto": {"http": "8080"}}`, &started)
+	if status != http.StatusCreated {
+		t.Fatalf("got status %d", status)
+	}
+	want := apiTunnel{Name: "web", PublicUrl: "http://web.ngrok.me", Proto: "http", LocalAddr: "127.0.0.1:8080"}
+	if len(started.Tunnels) != 1 || started.Tunnels[0] != want {
+		t.Errorf("got tunnels %+v, want %+v", started.Tunnels, want)
+	}
+	if !c.apiTunnels["web"] || c.tunnelConfig["web"] == nil {
+		t.Error("the tunnel isn't kept as an api tunnel")
+	}
+
+	var failed struct {
+		Error apiError `json:"error"`
+	}
+	if status := postTunnel(t, c, `{"name": "web", "proto": {"http": "8081"}}`, &failed); status != http.StatusConflict {
+		t.Errorf("starting the tunnel twice got %d %+v", status, failed.Error)
+	}
+}
+
+func TestApiStartTunnelRefused(t *testing.T) {
+	c, received := testModel(t, map[string]*TunnelConfiguration{})
+	answerTunnelRequest(c, received, "")
+
+	var failed struct {
+		Error apiError `json:"error"`
+	}
+	if status := postTunnel(t, c, `{"name": "web", "proto": {"http": "8080"}}`, &failed); status != http.StatusBadGateway {
+		t.Fatalf("got %d %+v", status, failed.Error)
+	}
+
+	// it's forgotten, so it can be tried again
+	if _, ok := c.tunnelConfig["web"]; ok || c.apiTunnels["web"] {
+		t.Error("the refused tunnel was kept")
+	}
+	answerTunnelRequest(c, received, "http://web.ngrok.me")
+	var started struct {
+		Tunnels []apiTunnel `json:"tunnels"`
+	}
+	if status := postTunnel(t, c, `{"name": "web", "proto": {"http": "8080"}}`, &started); status != http.StatusCreated {
+		t.Errorf("trying again got %d", status)
+	}
+}
+
+func TestApiStartTunnelInvalid(t *testing.T) {
+	c, received := testModel(t, map[string]*TunnelConfiguration{})
+
+	for body, want := range map[string]int{
+		`{"proto": {"http": "8080"}}`:                      http.StatusBadRequest,
+		`{"name": "a/b", "proto": {"http": "8080"}}`:       http.StatusBadRequest,
+		`{"name": "web"}`:                                  http.StatusBadRequest,
+		`{"name": "web", "proto": {"gopher": "8080"}}`:     http.StatusBadRequest,
+		`{"name": "web", "proto": {"tcp": "file:///srv"}}`: http.StatusBadRequest,
+		`["web"]`: http.StatusBadRequest,
+	} {
+		var failed struct {
+			Error apiError `json:"error"`
+		}
+		if status := postTunnel(t, c, body, &failed); status != want {
+			t.Errorf("%s: got %d %+v, want %d", body, status, failed.Error, want)
+		}
+	}
+	serverMessages(t, received, 0)
+
+	c.ctlConn = nil
+	var failed struct {
+		Error apiError `json:"error"`
+	}
+	if status := postTunnel(t, c, `{"name": "web", "proto": {"http": "8080"}}`, &failed); status != http.StatusServiceUnavailable {
+		t.Errorf("starting a tunnel while disconnected got %d %+v", status, failed.Error)
+	}
+}
+
+func TestApiStopTunnel(t *testing.T) {
+	c, received := testModel(t, map[string]*TunnelConfiguration{"web": httpTunnel("127.0.0.1:8080")})
+	openTunnel(c, "web", "http://web.ngrok.me")
+
+	if err := c.stopTunnel("web"); err != nil {
+		t.Fatal(err)
+	}
+	msgs := serverMessages(t, received, 1)
+	if m, ok := msgs[0].(*msg.CloseTunnel); !ok || m.Url != "http://web.ngrok.me" {
+		t.Errorf("the server got %#v", msgs[0])
+	}
+	if len(c.tunnels)+len(c.tunnelConfig) != 0 {
+		t.Errorf("got tunnels %v and configuration %v", c.tunnels, c.tunnelConfig)
+	}
+
+	err := c.stopTunnel("web")
+	if e, ok := err.(*apiError); !ok || e.Status != http.StatusNotFound {
+		t.Errorf("stopping an unknown tunnel got %v", err)
+	}
+}
+
+func TestApiStartTunnelWritesWithoutLock(t *testing.T) {
+	c, received := testModel(t, map[string]*TunnelConfiguration{})
+
+	// the request can't be written yet, as if the server weren't reading
+	c.writeMu.Lock()
+	answerTunnelRequest(c, received, "http://web.ngrok.me")
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		(&Controller{model: c}).startTunnel(w, httptest.NewRequest("POST", "/api/tunnels", strings.NewReader(`{"name": "web", "proto": {"http": "8080"}}`)))
+		done <- w.Code
+	}()
+
+	// the model isn't held up meanwhile
+	configured := func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.tunnelConfig["web"] != nil
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !configured() {
+		if time.Now().After(deadline) {
+			t.Fatal("the tunnel was never configured")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	c.writeMu.Unlock()
+	if status := <-done; status != http.StatusCreated {
+		t.Errorf("got status %d", status)
+	}
+}
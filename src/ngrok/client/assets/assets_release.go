@@ -83,7 +83,7 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return nil
 }
 
-var _assetsClientPageHtml = "\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xd4\x59\x7b\x6f\xdb\x38\x12\xff\xbb\xfd\x14\x8c\x8a\x85\x6d\x20\x92\x9d\x47\x73\x5b\xc7\x36\xd0\x64\xaf\xd8\xee\x5e\xd3\x5e\x93\xde\x02\x57\x14\x01\x2d\x52\x16\x63\x8a\xd4\x92\x94\x1f\x97\xcd\x77\xbf\x21\x25\xbf\x6d\xc9\x71\x17\x87\x3d\x01\x45\x43\x72\x66\x38\x1c\xfe\xf8\x9b\x21\xdd\x89\x4d\xc2\x7b\x2f\x11\x7c\x9d\x98\x62\x92\xff\xe9\x9a\x86\x19\x4e\x7b\x62\xa0\xe4\xb0\xd3\xcc\x1b\x8b\x41\xce\xc4\x10\xc5\x8a\x46\x5d\xaf\xa9\x0d\x36\x2c\x6c\x86\x5a\x37\x63\x36\x88\x39\xfc\x33\x41\xc2\x44\x00\x3d\x1e\x52\x94\x77\x3d\x6d\xa6\x9c\xea\x98\x52\xe3\x55\x1b\xe9\x4b\x69\xb4\x51\x38\xdd\xcb\x88\x0e\x15\x4b\x0d\xd2\x2a\x5c\x58\x79\x58\xf7\xe4\x41\x7b\xbd\x4e\x33\x17\xad\xd6\x1d\x0d\xfb\x14\x67\x86\x45\xd3\x67\x2a\x3e\xfc\x9e\x51\x35\xf5\x4f\x82\x37\xc1\xc9\x01\xf3\xe6\xea\x81\x61\x09\xc5\x03\xf9\x4c\x65\x2c\x06\x19\xc7\xea\x30\x2d\x5f\x63\xc1\x0c\xfb\x0f\x3d\xc0\xeb\x3e\xd6\xf4\xe2\xfc\x99\x4a\x0e\x56\x15\x3a\x66\x9a\xd2\xae\x67\xe8\xc4\x34\x1f\xf0\x08\xe7\xbd\x4b\x5b\x6f\xbf\x31\x13\x44\x8e\x03\x82\x0d\x46\x5d\xf4\xcb\xed\xc7\x9b\x20\xc5\x4a\xd3\xfa\xe3\x0f\x28\x40\x3f\x3c\x35\x2e\x17\x66\xb7\x4c\x64\x11\xb5\x3c\x8f\x05\xdb\xea\x04\x7d\x49\xa6\xe8\x11\x25\x58\x0d\x98\xf0\x8d\x4c\xdb\xe8\x75\x2b\x9d\x5c\xa2\xa7\x15\x31\x83\xfb\x9c\xda\xa9\x71\xa2\x41\x3c\x92\xc2\xf8\x1a\xe2\xd9\x46\x27\xa7\x56\xda\x75\x44\x38\x61\x7c\xda\x46\xd7\x32\x53\x8c\xaa\x63\x94\x48\x21\x75\x8a\x43\xba\x6e\x2e\x30\x13\xe1\x6b\xca\x69\x68\xa4\x42\x46\x81\xc9\x30\x53\x5a\xaa\x36\x4a\x25\x13\x86\xaa\x2a\x8d\x76\x2c\x47\xd4\xea\xf5\x71\x38\x84\x60\x67\x82\xf8\xa1\xe4\xd6\xc2\x2b\x42\xc8\x86\xff\x2a\xc8\x95\x29\x39\x5e\x6e\xcc\xcc\xac\x08\xbb\xb8\x6c\x9a\x8d\xa2\x37\xf0\x5d\xee\x23\xda\x72\xdf\xa6\x68\x3e\x3e\x8e\x99\xa1\xab\x83\x6b\x8b\x4d\xb1\x89\x37\x9c\x1a\x33\x62\xe2\x36\x3a\x6b\xd9\xfd\x29\xd3\x1e\x03\xb7\xa4\x94\x6c\x1a\x90\x8a\xf8\x76\xb0\x8d\xfa\x8a\xe2\xa1\x6f\x3b\xd6\x9d\x74\x42\x6e\xb8\x4c\xca\x46\x2d\xe2\x72\xdc\x46\x31\x23\x84\x8a\x5d\xfe\x00\x28\x2d\x06\x0b\x06\x6e\xe6\x14\x9c\x37\x1c\xf2\xc4\xc0\x07\x5f\xbb\x9e\x3b\x2f\xcb\xb4\x47\xd8\x08\x85\x1c\x6b\xdd\xf5\x42\xc0\x16\x66\x82\x2a\xcf\x8a\xdb\x96\x92\x9c\x53\xd5\xf5\x7e\x36\x26\xbd\x9b\x88\x75\x4c\x2f\x2b\x0b\x3c\xea\x63\x85\xf2\xff\x7c\x26\xc0\x6f\x4d\x67\xcd\x88\x4d\x28\xb1\xa0\x5f\x33\xb0\xdd\x08\x68\x5b\x27\x36\x45\x77\x3b\xbc\x5d\xd6\xc9\xe3\x99\x74\x5f\x61\x41\xbc\x22\x4f\xbc\xf2\x66\x19\x09\x97\xe8\x66\x7c\xc9\xb3\x92\x49\x9c\x30\x67\x33\x61\x1c\x1a\x36\xa2\x40\x49\x78\x31\xdb\x7b\xd1\xb7\xc8\x45\x9f\x29\x90\xb3\x36\xda\x4e\xdc\x69\x72\x56\x61\xf4\xc8\xf7\xab\x66\x5d\x99\xe6\x63\x66\x0e\x9a\x67\xcd\xcc\xb5\x14\x11\x1b\x64\x0a\x68\x56\x8a\xfd\x4c\xf8\x7e\x49\x20\x9b\x19\xdf\xb1\x9d\x4d\xd8\xcf\x2d\xa0\xd8\xec\xde\xd6\x65\xb1\x00\x50\xd5\xb1\x1c\x03\xf1\x02\x40\x03\x4e\xc5\xc0\xc4\xdd\x6e\xcb\x9b\x6d\x86\x92\xe3\x0a\xd4\x01\x73\x8a\x0b\x24\xa3\x48\x53\x73\xb6\x07\xec\xc6\x94\x73\x0f\xb9\xf3\xd6\xf5\x52\x4c\x08\x13\x83\x36\x3a\x05\xb6\xc8\x29\xbd\x0c\x8d\xf1\x79\xef\x46\x42\x19\x92\x6f\x0e\x32\x12\x11\xa6\x53\x8e\xa7\x68\x4a\x0d\x9c\xdb\xf3\xde\xcb\x17\x2f\x5e\x74\x62\x85\x9a\x65\x56\x5e\xf7\xee\x24\x1a\x50\xc8\x87\x06\x2b\xc7\xb5\x09\x1e\x52\x84\x67\x96\xad\x61\x29\x28\x2c\x0a\x4d\x21\x49\x20\x93\xc1\x91\xe2\xe8\xcb\xe7\x7f\xe8\x36\xcc\xf2\xba\x02\x0d\xbb\x76\x6b\x45\x08\xf0\x0e\xb1\x57\x34\xa5\xd8\x40\xf4\x11\x13\xc5\x34\x36\x19\xa7\xb3\x60\x71\x60\x22\x77\x12\xc0\x51\x70\xb8\xeb\xdd\xf7\x39\x16\xc3\xd9\x39\x7c\x7c\x44\x26\xf8\x94\xf5\x39\x0b\xbf\x28\x8e\x9e\x9e\xbc\xde\x46\x57\x8e\xbf\x74\x1f\x18\xef\xc4\x59\x3e\x9a\xfe\x2f\x41\xd8\x7b\x36\x06\x77\x61\x0f\x50\xf1\x96\xf3\xa5\x23\x6d\x61\xb2\x55\xd2\x95\x0f\x33\x9b\x79\x63\x39\x9f\x97\x21\x13\x8a\x83\x35\xd6\x07\xc6\xbf\xc1\xa3\xf7\x86\x26\x79\x42\xc8\xad\x3e\xd6\x66\x39\xbd\xd6\x66\xfa\xad\xa3\xba\x7a\xe3\xc9\x5b\x01\xc3\x44\x38\x38\xd8\x94\x91\xab\xb2\x70\xd8\xf5\x2c\x46\x67\x0a\x55\x6c\x6a\xc8\xfc\xb8\xe5\x59\x16\x40\xb4\x14\x2f\x9b\xb8\x73\xa8\x4c\x44\x00\x81\x09\x3e\x50\x13\x4b\xf2\xc9\xe6\x73\x8b\x18\xbb\x4d\x70\xdb\x20\x95\xb3\x2c\x6c\xe8\x34\xb8\x85\xba\x32\xd3\xce\xc0\x3e\xaa\x1d\xbb\x6b\x73\x8f\x32\xce\x7d\x65\x6f\x0a\x73\xbf\x7e\x2a\x08\xd4\x19\xb4\xb2\x15\x2e\xc1\xa8\xda\x85\x50\xb7\x99\x7b\x61\xd4\x75\x6f\x40\x6b\x47\x4a\xf7\x16\xd0\x3d\x3a\xb2\xed\x6a\xfa\x03\x30\xfb\x11\xcf\x18\x29\x43\xd3\xda\xfc\xe7\x55\xdb\xed\x22\xe9\x2e\x87\x96\x13\xde\xdf\x7e\xfc\xf1\xa2\x75\x52\x07\x87\xec\x9e\x28\xd3\x00\x62\x98\xd9\x4b\x32\x43\xcb\xe6\x9e\x7d\x8f\x8f\x77\x70\x01\x7a\x27\x55\x82\xcd\x8a\xa5\x0a\x0a\x71\x1b\x55\xb2\x47\x5b\x03\x7e\xf0\xc2\xe7\x45\x03\x83\xdd\xf1\xed\x95\xcd\x5e\x65\x58\x0f\xcd\xc0\xb3\x47\xdc\x8a\x54\x54\xdc\x2d\x38\x8d\x4c\x1b\xfd\x68\x13\xd1\x5a\xc8\x20\x22\x4b\xa8\x9c\x83\xf2\xbb\xd6\xfa\x1d\x8b\xcd\xb4\xad\xdc\xdc\x62\xdf\x7f\xfa\xd3\x97\x09\x25\x8c\xb8\x36\x93\xe0\x9a\x33\x2a\xcc\x5b\x42\xe0\x32\x92\x72\x66\xea\x5e\xdb\x6b\x7c\x6d\x7d\xfb\xae\xe5\x97\x0d\xed\xce\xdd\xab\xa9\xe2\xe8\x08\x78\x6b\xeb\xb9\x2c\x88\xbe\xb4\x8e\x38\xdb\xa0\x47\x20\x9d\x2d\x44\x18\x9f\x55\x6c\xa0\x14\x7d\x23\x0a\x82\x06\xfe\x86\x52\x04\xc8\x19\x41\x5f\xd7\xfb\xec\x9a\x9e\xbd\x94\xc2\x44\xb7\x59\x02\xa1\x9f\x1e\xff\x0c\x19\x1d\x8a\xfb\xe3\xcf\x78\x7c\x7c\xc5\x04\x74\x95\x39\x9a\x07\xaa\xdc\x85\x79\x48\x98\xbe\xc3\xfd\x7a\xad\x98\xa9\x56\x99\x24\x86\x74\x3a\xc2\x7c\xc6\x1b\xff\xb4\xaf\x1e\xe8\x93\xbb\x3b\x83\xd3\x59\xca\xa9\xb6\x8b\xf8\x3d\x28\xfa\x00\x6b\xb9\x46\x85\x59\xeb\x93\xbd\x35\xe5\xca\x57\xf0\x17\x04\xc4\xad\xb4\xe8\x29\x56\x5d\x75\x3e\x0e\x59\x7b\x11\xdd\xe7\xae\xbd\x50\x5b\x5d\x76\xde\xb9\xcf\xb2\x0f\x72\x15\x00\x50\xed\x66\xaa\x68\xaf\x13\x4a\x32\xaf\x4a\x62\x00\xf8\x1c\xac\x60\xe2\x8e\x4e\x8c\x43\xaa\x15\xb2\x75\x9e\xda\x92\xe6\xbe\xcb\xcf\x7c\xbb\x9e\xe1\xea\x92\x77\x57\x53\x43\xf5\x9f\xe2\x5e\xe9\x18\x70\xc6\x0a\xbb\xb5\xd1\xb9\xbd\x4e\xb4\xdc\xad\xc2\xb3\x7c\xb2\x27\xa1\xe8\x74\x07\xa3\xe8\x54\x0a\x4d\x2b\x28\x65\x51\xe5\xb9\x72\x48\xbb\x72\xe8\xda\xf6\x14\x3b\xb6\x5a\x23\x59\x6e\x29\xdf\x8a\x6a\xe6\xd8\x27\xc7\x1c\xcc\x0f\xcb\x07\x19\x5c\x5f\x3f\xc9\xb6\xeb\xff\xe2\x28\x83\xa3\x7f\xf5\xb3\x0c\x2e\xfe\x95\x0f\x73\xee\xde\x41\xa7\xf9\x19\x43\xd5\x37\xc6\xf5\xe6\x91\xef\xa3\x2f\x50\x07\xfd\x4b\xb2\x90\xa2\x5f\xf0\x08\xdf\xe6\x8f\xd6\xb7\x3f\xfd\x8a\xea\x52\xf0\x29\x12\x94\x12\x4a\x20\x4f\x83\x00\xdc\x25\x30\x4a\xf1\x80\x36\x56\xde\x5b\x8a\x97\xee\x5e\x3d\xca\x44\x68\x6b\xbb\x7a\xe3\x71\x84\x15\xca\x46\x5d\x22\xc3\x2c\x81\x02\x28\x08\x15\x5c\xcd\xe8\xdf\x39\xb5\xad\x7a\x2d\xd7\xa8\x35\x2e\xb3\x51\xe0\x1e\xae\x6b\x6b\x0f\xe4\x35\x3b\x82\xf5\x54\x84\x5d\xa3\x32\x6a\x5b\xf6\xf1\xbd\xd6\x6c\x8e\x19\x81\x9b\x7c\x60\xcb\xb7\x91\x75\x3b\x08\x65\xd2\x3c\xff\xf5\x4b\x42\xde\x5d\xfc\xf6\x6f\x72\xd6\x3a\xfd\x10\x8d\x25\x9e\x7e\x18\x07\x0f\xba\x76\x69\x1d\xd1\x0b\x3f\x40\xb5\x70\x42\x5f\x4d\xef\xf0\xe0\x06\x27\x74\xe1\x0e\x14\x66\x97\xda\xbe\x7f\xc3\xf8\x0d\xec\x51\xc0\x80\xb5\x94\xb9\xa2\x91\x54\xb4\x9e\x8d\x8e\x75\xe3\xa9\x51\x6f\x2c\xde\xe0\x57\x43\xf9\xd6\x32\x8e\x7d\xfc\xe0\x18\x22\x11\x23\x13\x53\xe4\xf8\x8b\x85\xe8\x37\xe7\xf6\xd6\xb8\xcd\x3b\x16\x5b\xd1\x9d\xfd\x24\xb0\xe8\xfa\xe3\x0f\xf4\xf5\xdb\xe5\xa6\x6c\x90\x66\x3a\xae\x7f\xad\x59\x9c\x02\x46\x6b\xc7\xa8\x16\xe6\x73\xde\xe7\xa1\x82\x9e\xe5\x27\xe2\x04\xd6\xd5\x46\xb5\x08\xf6\xd5\x3e\x69\xd7\x8e\x97\xc6\x52\xc5\x2c\xb5\xdd\x17\x0f\xdc\xb5\x57\x61\x78\x41\x5a\xad\x15\x19\xfb\x73\xd7\x42\xa0\xd5\xfa\x1b\xe9\x47\x2b\x02\x10\xab\x2c\xb9\x67\xa4\x8d\x4e\x4f\x4e\xde\x9c\xbe\x5e\x1e\x83\xf8\xdc\x73\xdc\xa7\x1c\x74\xdf\x6d\xf3\xc0\x0a\x94\xcd\x6e\xc7\x53\xa9\x99\x85\x19\x88\x24\x8c\x10\x4e\x5d\x35\xbe\x21\xe6\x5e\x81\xed\xe3\x3f\x8a\x30\xd7\x74\x3e\xfa\xf4\x6d\xe7\xcf\x29\x9d\xa6\xe5\xeb\xde\x4b\x48\x2e\xee\x07\xc5\xff\x06\x00\x00\xff\xff\x1e\x58\x3f\x2f\x58\x1c\x00\x00"
+var _assetsClientPageHtml = "\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xd4\x59\xfb\x6f\xdb\x38\xf2\xff\xb9\xf9\x2b\x18\x15\x0b\xdb\x40\x24\x39\x4d\xda\xef\xc6\x91\x0d\xb4\xd9\x6f\xb1\xdd\xbd\x3e\x2e\x49\x6f\x81\x2b\x8a\x82\x12\x47\x16\x1b\x8a\x54\xc9\x91\x1f\xeb\xf5\xff\x7e\xa0\x1e\x7e\x5b\x4e\xd2\xe2\xb0\x67\x09\x48\x48\xce\x7c\x66\x86\xfc\x70\xf8\x50\x90\x60\x2a\x06\x47\x84\x10\x12\x24\x40\x59\xf9\xaf\x7d\x02\xe4\x28\x60\x20\x87\x5a\xdd\x05\x7e\x59\x58\x36\x0a\x2e\xef\x48\xa2\x21\xee\x3b\xbe\x41\x8a\x3c\xf2\x23\x63\xfc\x84\x0f\x13\xc1\x87\x09\x7a\x29\x97\x5e\x64\x8c\x43\x34\x88\xbe\x63\x70\x2a\xc0\x24\x00\xe8\x1c\x06\x09\x95\x42\x83\x9a\x66\xf7\x02\x31\x91\xe6\x19\x12\xa3\xa3\x25\xca\xd7\x4d\x4f\xbe\x1a\x67\x10\xf8\xa5\xe8\x61\xdd\xd1\x5d\x08\x34\x47\x1e\x4f\x1f\xa8\xf8\xf5\x5b\x0e\x7a\xea\x9e\x7a\x17\xde\xe9\x23\xec\x96\xea\x1e\xf2\x14\xe8\x50\x3d\x50\x99\xca\x61\x2e\xa8\x7e\x9c\x96\x6b\xa8\xe4\xc8\xff\x84\x47\x78\x1d\x52\x03\x2f\xce\x1f\xa8\x54\xd0\xea\x80\x0e\x4e\x33\xe8\x3b\x08\x13\xf4\xbf\xd2\x11\x2d\x6b\x57\x86\xde\xbe\x63\x2e\x99\x1a\x7b\x8c\x22\x25\x7d\xf2\xdb\xcd\xfb\x77\x5e\x46\xb5\x81\xf6\xec\x27\xe2\x91\x9f\xe6\x9d\xcb\x25\xec\x0e\x43\x96\x51\xab\x76\x2c\x63\xd7\x0d\x84\x8a\x4d\xc9\x8c\xa4\x54\x0f\xb9\x74\x51\x65\x3d\xf2\xbc\x9b\x4d\x2e\xc9\x7c\x4d\x0c\x69\x28\xc0\x9a\xa6\xa9\x21\x33\x12\x2b\x89\xae\xe1\x7f\x42\x8f\x9c\x3e\xb3\xd2\x45\x45\x4c\x53\x2e\xa6\x3d\x72\xa5\x72\xcd\x41\x9f\x90\x54\x49\x65\x32\x1a\xc1\x26\x9c\x87\x13\xe9\x1a\x10\x10\xa1\xd2\x04\x35\x99\x91\x28\xd7\x46\xe9\x1e\xc9\x14\x97\x08\xfa\x90\x46\x2f\x51\x23\xb0\x7a\x21\x8d\xee\x86\x5a\xe5\x92\xb9\x91\x12\x16\xe1\x29\x63\x6c\x53\x1d\xb5\x57\x9a\x03\x76\xb2\x5a\xa8\x61\xd6\x84\xed\xbb\x03\x36\x8e\x2f\x2e\x2e\x2e\x2e\x8f\x36\x24\x77\x89\x76\x8b\xdf\xb6\x68\xd9\x3e\x4e\x38\xc2\x7a\xe3\x46\xb0\x19\xc5\x64\xcb\xa9\x31\x67\x98\xf4\xc8\x59\xd7\x8e\x4f\x93\xf6\x58\xd3\x2c\x03\xb6\x0d\xa0\x34\x73\x6d\x63\x8f\x84\x1a\xe8\x9d\x3b\x56\x9a\x5d\xee\x12\x2a\x9a\x9b\xa4\x6c\xaf\xc5\x42\x8d\x7b\x24\xe1\x8c\x81\xdc\xe7\x4f\xe0\x17\xa9\xb1\xca\xc0\x7e\x99\x82\xcb\x42\xc1\x3c\x39\x74\x69\x96\xf5\x9d\x62\xbe\xac\x50\x33\x60\x7c\x44\x22\x41\x8d\xe9\x3b\x91\x92\x48\xb9\x04\xed\x10\x39\x74\x6d\x49\x2b\x21\x40\xf7\x9d\x5f\x11\xb3\xdb\x89\xdc\xe4\xf4\xaa\xb2\xa4\xa3\x90\x6a\x52\xfe\x71\xb9\x1c\x81\x36\x50\x17\x63\x3e\x01\x66\x49\xbf\x01\xb0\x1b\xc4\xe5\xd2\x3a\xb1\x2d\xba\xdf\xe1\xdd\xb2\xf6\x09\x68\x2d\x1d\x6a\x2a\x99\x53\xad\x13\x4f\x9d\x7a\x45\xa2\x0d\xba\xb9\xa8\x95\x25\x1d\x35\x18\xa9\x56\xa1\x5a\x98\x46\xc8\x47\xe0\x0c\x02\xba\xb4\xf6\x46\x86\x96\xe4\xe4\x1a\xbe\xe5\x60\xd0\x58\xc3\x81\x2f\xf8\x01\xd0\x63\xd7\x3d\x64\x75\xcd\xcc\xfb\x1c\x1f\x65\x67\x03\xe6\x4a\xc9\x98\x0f\x73\x4d\x91\x2b\x79\x3f\x08\xd7\xdd\xdf\x1e\xf8\x79\xb5\x39\xd8\xf5\x04\xd9\x4a\x2f\x5b\xba\xd8\x14\x4a\xb2\x5c\x08\x57\xdb\x3d\x40\x41\x47\x93\xa8\x71\xdf\x31\xa0\x47\x96\x1a\x37\xc5\x5f\x32\x9b\x91\xb2\x86\xcc\xe7\x81\x9f\xed\x36\x11\xf8\x8c\x8f\x06\x47\xf7\xa8\xde\x55\x65\x29\xbe\x30\x8f\x13\x69\x3c\x01\x72\x88\x49\xbf\xdf\x75\x6a\xb7\xb5\x1a\x1f\x20\xb6\xc9\xa8\x7c\x41\x54\x1c\x1b\xc0\xb3\x7b\x30\x7b\x0c\x42\x38\xa4\x98\xd2\x7d\x27\xa3\x8c\x71\x39\xec\x91\x67\xdd\x6c\x52\xae\x1a\x7b\x20\xec\x1b\x24\xe7\x83\x77\x8a\xe8\x6a\xfc\x09\x2a\xc2\xb8\xc9\x04\x9d\x92\x29\x60\xe0\x27\xe7\x83\xa3\x27\x4f\x9e\x04\x89\x26\x7e\x13\xca\xf3\xc1\xad\x22\x43\x40\x62\x90\xea\x22\x9d\xa7\xf4\x0e\x08\xad\x91\x2d\xb0\x92\x40\x54\x4c\xa6\x2a\xd7\x04\x73\x29\x41\x90\x8f\xd7\xff\x30\xbd\xc0\x4f\x9e\xef\xc7\xae\xa6\x56\xb3\x40\xc5\x4a\x3b\xf4\x1a\x32\xa0\xd8\x77\x90\x70\x59\x99\xb1\xeb\xfd\x82\x35\x02\x28\x2b\x26\x1b\x52\x3d\x04\xec\x3b\x5f\x42\x41\xe5\x5d\x3d\xd5\x67\x33\x82\xde\x87\x3c\x14\x3c\xfa\xa8\x05\x99\xcf\x9d\xc1\x56\x55\x49\xf1\xec\x3e\x33\xa5\x99\xca\xff\x5d\x12\x0e\x1e\xcc\xc1\x3d\xc4\xb1\xa4\x79\x29\xc4\x4a\xd6\xb0\x34\xa9\x5b\x57\x7f\x41\xb1\x43\xa9\x31\xcb\xc2\xea\x96\x61\x8f\x01\xfb\x06\xa8\x37\x17\x96\xdb\x89\x7c\x47\x47\x6f\x10\xd2\x62\x92\x57\xa8\xb3\x56\xbd\x6d\x68\xf5\xb8\x79\x59\x64\xd3\x76\x67\xee\xac\x91\x61\x22\x0b\x3a\xd8\x55\xc9\xd6\x47\x82\x47\x77\x7d\xc7\x72\xb4\x56\x68\x70\xc5\xbe\x01\xb2\x3a\x8c\x6a\x21\x77\x06\xab\xfd\x65\xf7\x06\x25\x55\x26\xd2\xbb\x86\x6f\xde\x5b\xc0\x44\xb1\x0f\x14\x93\x82\x31\x76\x98\x02\x1f\x57\x8e\x3a\xbb\x9e\x00\xd9\x12\xc3\x64\xde\x0d\x52\xcc\x4d\x01\x70\x1f\xd5\xc0\x8e\xda\xc2\xa3\x65\x4a\xac\x31\x7f\xa9\x72\x74\x01\x68\x65\x0f\xb8\x14\xf8\xa8\x77\xb7\x06\x7e\x31\x98\xf7\xe2\xe8\x6e\x6a\xed\xd9\x35\xac\xa4\xef\xe3\x63\x5b\xde\xc6\xda\xc4\xd3\x6a\xec\xc6\x22\xe7\xac\x61\x08\x37\xed\x9f\x37\xc8\xda\xb7\xec\xc9\xe2\xfc\x69\x73\xc2\x9b\x9b\xf7\x3f\xbf\xe8\x9e\xb6\x6f\x27\xd2\x8e\x89\xc6\xce\x7c\xbe\x98\x4c\x69\x8e\xd0\x64\xbb\xfe\xcd\x66\xb7\x3c\x85\xd7\x4a\xa7\x14\xd7\x90\x9a\x3d\xf1\xad\x2b\xfb\xd1\xf7\x74\xf8\xa3\x03\xe7\xb5\x34\x8f\x94\x74\xed\xa9\xd0\x1e\x62\xf9\x80\xd4\xe4\x69\x56\xb7\x36\xea\xa5\xa8\x3a\xbe\x08\x88\xb1\x47\x7e\xce\x26\x97\x9b\x5d\x36\x9b\xdd\xae\xb0\x72\x41\xca\xef\x8a\xf5\x3b\x82\xcd\x8d\xdd\x2d\x14\xc1\xbe\xf9\xf0\xc3\xc3\xbc\x52\x52\x5e\xe1\xc4\xbb\x12\x1c\x24\xbe\x64\x4c\x7b\x26\x13\x1c\xdb\x4e\xcf\xe9\x7c\xea\x7e\xfe\xae\xf0\x9b\x9a\xf6\xaf\xdd\xeb\x4b\xc5\xf1\xf1\x35\x7c\xdb\x39\x2f\xab\x44\xdf\xd0\x9b\x41\x72\xb6\x95\x1e\x67\x33\xb2\x23\x11\x26\x67\x0d\x28\xd6\x1f\x25\x43\x94\x55\x82\xd6\x60\xb7\x22\xed\x8e\x43\x42\x94\x7d\xe7\xba\x28\x3a\x04\x69\x68\xfa\xce\x4d\x9e\xa6\x54\x4f\x4f\x7e\x05\xca\x40\x9b\x93\x6b\x3a\x3e\x79\xc5\x25\xd5\xd3\x26\x47\xcb\x8e\x6a\x76\x61\xd1\x25\xdc\xdc\xd2\xb0\xdd\xaa\x2c\xb5\x0e\x2e\x12\x77\x30\x1d\x51\x51\xe7\x8d\x7f\xda\x8b\x15\xf2\xa1\x38\x9e\x3b\x04\xf3\x4c\x80\xb1\x41\x7c\xf3\xaa\xba\x41\xe0\x97\x1a\x07\x60\xad\x4f\xf6\x60\x56\x2a\xbf\x52\x6c\xea\x90\xb0\x88\xb4\xaa\xa9\xa2\x6e\x60\xc1\xa3\x63\xaf\x7a\xf7\xa1\xb1\x57\x6a\xeb\x61\x97\x95\xf7\x09\xfb\x51\xae\x5e\xd3\xf1\x61\x37\x33\x0d\x83\x20\x52\x0c\x6a\xbe\x26\x88\xd9\x82\xac\xd7\x74\x7c\x6b\xcf\x13\x96\xa9\x56\x68\x10\xf8\x56\xe1\xc7\xfa\x59\x92\xf4\x01\xae\xae\x78\xf7\x6a\x8a\x60\x7e\x88\x7b\x8d\x6d\x89\x5e\xcf\x6e\x3d\x72\x6e\x8f\x13\xdd\xe2\x54\xe1\x10\x7f\x9f\xde\x5a\xb8\x36\xa1\x98\x6c\x4f\x46\x31\x99\x92\x06\x1a\xba\xc0\xa6\x94\xe5\x2e\xcf\x22\x79\xf6\xb2\x34\x37\x57\xb6\xa6\x1a\xb1\xf5\x3d\x52\x72\xb6\xc7\xaf\x85\x6f\x87\x33\x47\xd5\x2d\xb5\x56\x73\x84\x0f\xcd\x0f\xab\x13\xd9\x64\x5b\x33\xd9\x64\xff\x23\x53\xd9\x64\x7f\xfb\xb9\x6c\xb2\xbf\xf5\x64\x36\xd9\xa3\x67\x73\x5d\xbc\x47\xd3\x8e\xea\x8d\xaa\xcd\xe2\xb1\xeb\x92\x8f\x06\xf4\xbf\x14\x8f\x80\xfc\x46\x47\xf4\xa6\xb8\xbf\x26\x37\xbf\xfc\x4e\xda\x4a\x8a\x29\x91\x00\x0c\x18\x51\x32\x02\xa2\x24\xa1\x24\xa3\x43\xe8\xac\x5d\xe9\x54\x97\xe9\x83\x76\x9c\xcb\xc8\xee\xed\xda\x9d\xd9\x88\x6a\x92\x8f\xfa\x4c\x45\x79\x0a\x12\xbd\x48\x03\x45\xf8\x7f\x01\xb6\xd4\x6e\x95\x1a\xad\xce\x65\x3e\xf2\x8a\xbb\xf1\xd6\xc6\x1d\x7c\xcb\xb6\x50\x33\x95\x51\x1f\x75\x0e\xb6\x64\x3f\xa1\xb4\x7c\x7f\xcc\xd9\x10\xd0\xb3\xdb\xb7\x91\x75\xdb\x8b\x54\xea\x9f\xff\xfe\x31\x65\xaf\x5f\xfc\xf1\x6f\x76\xd6\x7d\xf6\x36\x1e\x2b\x3a\x7d\x3b\xf6\xbe\x9a\xd6\xa5\x75\xc4\x2c\xfd\x18\x02\x56\x4e\x98\x57\xd3\x5b\x3a\x7c\x47\x53\x58\xba\xf3\xa9\xfb\xf9\xd2\xd8\x2b\x76\x90\xf8\x4e\x31\xf0\xb8\x34\xa0\xf1\x15\xc4\x4a\x43\x3b\x1f\x9d\x98\xce\xbc\xd3\xee\x2c\xaf\xf9\xd7\xbb\xf2\xa5\xdd\xab\xd8\xcb\x0f\x41\x73\x19\x25\x04\x13\x20\x45\xfe\xe2\x11\xf9\xa3\x70\x7b\x67\xbf\x2d\x2a\x96\x43\xd1\xaf\xbf\x3a\x2c\xab\xfe\xfa\x8b\x7c\xfa\x7c\xb9\x2d\xeb\x65\xb9\x49\xda\x9f\x5a\x96\xa7\xb7\x34\x6c\x9d\x90\x56\xb1\xd6\xf1\xe8\x4b\xd9\x55\xad\x93\xb5\x5b\xe8\x54\x31\xe8\x91\x56\x0c\xc0\x42\x1a\xdd\xb5\x4e\x56\xda\x32\xcd\x6d\x6a\xfb\x52\xdd\xa1\xb7\x9e\x46\xd1\x0b\xd6\xed\xae\xc9\xd8\xcf\x72\x4b\x81\x6e\xf7\xff\x58\x18\xaf\x09\xc4\x4a\xe7\xe9\x17\xce\x7a\xe4\xd9\xe9\xe9\xc5\xb3\xe7\xab\x6d\x48\xc3\x2f\x82\x86\x20\x7a\xa4\xf5\x7a\x97\x07\x56\xa0\xc9\xba\x6d\xcf\x94\xe1\x96\x66\x3d\xd2\x4a\x39\x63\x02\x8a\xdd\xf8\x96\x58\x71\xd1\x8c\xc0\x7a\x24\xa6\xc2\xc0\xa2\x75\xfe\x79\xef\x17\x9b\xc0\xb7\xf9\x7a\x70\x14\xf8\x09\xa6\x62\x70\xf4\x9f\x01\x00\x79\x82\x38\xcd\xbb\x1c\x00\x00"
 
 func assetsClientPageHtmlBytes() ([]byte, error) {
 	return bindataRead(
@@ -98,7 +98,7 @@ func assetsClientPageHtml() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "assets/client/page.html", size: 7256, mode: os.FileMode(436), modTime: time.Unix(1452132613, 0)}
+	info := bindataFileInfo{name: "assets/client/page.html", size: 7355, mode: os.FileMode(436), modTime: time.Unix(1792349259, 0)}
 	a := &asset{bytes: bytes, info:  info}
 	return a, nil
 }
@@ -283,7 +283,7 @@ func assetsClientStaticJsJqueryTimeagoJs() (*asset, error) {
 	return a, nil
 }
 
-var _assetsClientStaticJsNgrokJs = "\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xb4\x1a\x7f\x73\xdb\xb6\xf5\xff\x7e\x0a\x88\xe7\x45\xd4\x2c\x51\x4a\xdb\x65\x3d\xcb\xf2\x6d\x49\xdb\x5b\xb6\x2e\xe9\xc5\xde\xf6\x87\x93\xdb\x41\x24\x24\xd1\xa6\x48\x96\x00\x2d\x6b\xa9\xbe\xfb\xde\x03\x40\x0a\x04\x41\xca\xee\x32\xde\xd9\x12\x81\xf7\x1e\xde\x6f\x3c\x3c\xe8\x81\x16\x24\x5d\x17\xd9\x3d\x59\x10\x9a\xae\xcb\x84\x16\xc1\x36\x8b\xca\x84\xf9\x9e\x1c\xf7\xc6\xe4\x16\xbe\x5d\xd3\x34\x16\xf1\x7f\x98\xf7\x69\x34\xff\xea\x2b\xc4\xda\xb0\xc7\x0f\x2c\x2f\x00\x6f\x55\xa6\xa1\x88\xb3\xd4\x5f\xee\x05\xe3\x23\xf2\xf9\x2b\x02\x0f\xc2\x2c\xcb\x15\xcc\xdf\x7e\x9a\xd7\x23\x94\x87\x71\x6c\x8c\xad\xb2\x82\xf8\x38\x11\x2f\x66\x73\x12\x5f\x4a\x12\x41\xc2\xd2\xb5\xd8\xcc\xc9\xf9\x79\x5c\x91\xab\x49\x02\xb2\x04\xba\x8d\x81\x44\x3d\x15\xaf\x88\x3f\xf0\xe3\xdf\x7d\x37\x22\x2f\x5e\x90\x78\xb0\x98\x99\x88\xf8\x00\x2f\x41\x5e\xf2\x8d\xef\x7d\x14\xde\x68\xee\x9c\x0b\x68\x9e\x27\x7b\x1f\x5e\xc7\x8a\xd3\x91\x9b\xc4\xf0\x63\x3a\xb4\x48\xd8\x82\xe1\x73\x68\xf2\xb7\x24\x97\xe4\xe5\xab\x6e\xbe\x66\x26\x5b\x0e\xdc\xd9\xe3\xd7\x33\xf2\xeb\xaf\xa0\x82\x2b\xf8\xfe\x47\x66\x53\x92\x2c\x68\x06\x03\x93\xbf\x03\x61\x09\x67\xdd\xd0\xd7\xa2\x88\xd3\x75\xb0\x2a\xb2\xed\x9b\x0d\x2d\xde\x64\x11\xf3\x97\x23\x37\x33\x35\xbb\xcb\x40\x64\x0a\xd1\x07\xa1\x0c\xe0\xa3\x40\xc4\x14\xc8\x58\xef\x38\xa1\x09\xa3\x84\x6a\x5e\x59\x1e\x05\xb4\x0d\x1f\x02\x67\xfc\x27\xb6\x12\xa0\xe4\xef\xc8\xc4\x42\x98\x92\xaf\x8d\xb5\xa4\x57\x69\x8f\xaa\xf1\x5a\xee\x64\x31\x4b\x48\x53\xff\x6d\x90\xa6\xe3\x74\x3b\x4d\x43\xb6\x82\x89\xb2\x48\x25\xf4\x5d\x16\xa7\xbe\x87\x34\x60\x4e\x46\x57\xb0\xa2\xa1\xc8\x8a\xbd\xef\x89\xc7\xf4\xfa\x21\x84\x68\xab\xa3\xc9\x0c\xa4\xbc\xc8\x42\xc6\xf9\xeb\x2c\xda\x37\x02\x0e\xde\xc7\x64\x19\xa7\xb4\xd8\x9b\x92\xe1\x78\xa0\x86\x31\x5c\xe4\x97\x79\x73\x36\xe6\x3f\x66\xc5\x16\x67\xf1\xed\x4d\x96\x0a\x96\x8a\x9b\x7d\xce\xc8\x62\x41\x3c\x14\x29\x0e\x29\x2e\x32\x7d\x9c\xec\x76\xbb\x09\xa8\x74\x3b\x29\x0b\x50\x77\x08\xee\x11\x79\x16\x39\xf6\x18\x73\xc1\x2b\x72\x3f\xd5\x56\xb4\xc0\x36\x94\xff\x50\x14\x19\x66\x8d\xc1\x40\x8e\xc8\x57\x23\x90\x51\x5a\xbe\x4f\x05\x7d\x7c\x93\x50\x8e\x14\x9b\x06\xf3\x04\x7b\x14\xd3\xc7\x6d\xe2\x5d\x90\xe6\xe3\xe1\xe0\xb8\x09\xdc\x90\xc3\xc4\x71\x01\x4b\xca\x1b\xd1\x26\xdd\x09\x1c\x72\xde\x66\x03\x07\x7b\xd8\xb8\xe3\x59\x5a\x23\x79\xf2\xcd\x45\xfa\x8e\x3e\x80\x33\x15\x71\x2e\x0c\xa6\x8d\xc1\xbe\x15\x4c\x54\x37\xce\xe1\xd6\x36\xbb\x99\x4c\xa7\x53\x12\x31\x34\xb3\x34\x5a\x33\x0f\xb5\x7c\x0d\x1f\x49\xec\x06\xd8\x06\x73\x79\xde\x89\xc4\x63\x02\xbf\xa6\x9c\xbd\xfa\x36\x50\xab\xf9\xf5\xcc\x28\x40\x1d\x38\xf3\x0f\xf0\x96\x43\x48\x89\x78\xb5\x6f\xf8\x8c\x28\x68\xca\x57\xca\xa5\x2d\x8f\x51\x86\x77\x19\x51\xdb\x42\x7d\x1a\xca\x31\x1c\xb0\xb5\xc9\x34\xfd\x18\x36\x9b\xc1\xa0\x5e\xdb\xd6\x8b\x80\xf8\x6b\x8e\x68\x11\x1e\xee\x97\x8c\x96\x28\x04\x89\x32\xc6\x49\x9e\x65\x45\xb2\x27\x54\x60\xea\xda\x52\x10\x2f\x5d\x13\x74\xc5\x16\xb2\x34\x82\x1d\xb2\x83\x85\xe9\xbd\x36\x17\x2e\xc5\x1f\x19\xb8\xad\xb9\xff\x64\x18\x60\xde\xa2\x70\x68\x8c\x1c\x08\x38\x5b\xb8\x21\x7e\x6b\x17\x3a\x74\x6d\x61\x83\x81\xa1\xd6\x3e\x0f\xda\x24\x77\x3c\xd8\xc4\xeb\x4d\x02\x7f\xc2\x37\xb0\xc6\xc4\x70\x91\x07\x9a\x94\xec\x84\xaf\x81\xaa\x6b\x42\xc1\x1d\x97\xca\x4e\x87\x82\x6c\xe8\x03\x23\x94\x0c\xf3\x84\xc6\x29\x6a\x6e\xa8\x93\xce\x98\xf0\x8c\xec\xd8\x30\x49\xc8\x5d\xc9\x05\x09\xb3\x7c\x4f\x62\xc8\x6c\x8c\x87\x34\x47\xab\x54\xc9\x37\xe8\xe4\xbf\xfe\x1e\x14\x0c\x16\x08\x99\x3f\x7d\x31\x5d\x6f\xc7\x64\xf8\x82\x6e\xf3\xf9\x70\x74\x1c\xbf\xd4\xe3\x89\x68\x0c\x5f\xe9\xe1\x35\x0e\xdb\x7b\xd2\x41\x7b\xa4\xb1\x2f\x7c\x60\xbf\x98\xdb\x42\xc1\x7e\x31\xf5\x2b\x95\x0f\x63\xc1\x07\xba\x7b\x6d\x96\x68\xd5\x83\x94\x54\x08\x46\xad\x98\xd4\x78\x96\x43\x98\xd4\xd0\x62\xaa\x16\xf4\x35\x91\x40\x15\x82\x46\xe4\x34\xd8\x78\xed\xcc\x21\x06\x59\xad\xc7\x8a\x5a\x33\x17\x74\xfa\x98\xb1\x47\x4a\xae\x5f\xcb\xcd\xd1\x58\x70\xde\xad\x3e\x9e\x37\xf5\xc7\x73\x93\x3d\x7c\x0f\xb8\xa0\xa2\xe4\xee\x4d\x69\xf8\xf5\xf0\x42\x45\xe0\x24\x4e\x57\x99\x95\x66\x86\xdf\xe0\xec\xb6\x14\xb0\x6d\x5a\x33\xdf\xd6\x78\x3b\x5a\xa4\xe0\x5e\x36\xc0\x1f\x6a\x00\x86\xf9\xc6\x4c\x52\x92\xab\x6b\xc9\xd5\xed\xec\x53\x2b\x4f\xc9\xe9\xdf\x66\x71\x85\xd8\x32\xb9\x41\xef\x99\x36\x07\xcc\x3e\xa3\x2b\xc2\x5f\xc2\xea\xb8\x90\x36\xfb\x71\xcd\x4e\xbb\xdf\x3c\xa6\xa6\xd9\xa1\x04\x33\xf9\x3b\xc6\x16\xce\x04\xf0\x69\x68\xc4\xf0\x1c\x3d\x0b\x2e\xe3\x5a\x88\x3d\x69\x2d\xb9\x83\x65\x3f\xc6\x8f\xd2\x1e\x35\x94\x4c\x71\x63\xa4\x12\xc6\x1c\x06\x5c\x66\xcc\xb3\x1d\xc3\x7a\xea\xef\x54\x6c\x02\x78\xf1\x5f\xce\x0c\x0c\x3c\x2f\xcc\x5a\x96\x94\x15\xa9\x2e\xde\x25\x5e\x91\x95\x69\xa4\x96\x23\xbf\x57\x24\x47\x50\x53\xab\x2f\xae\xa2\x18\xb7\x61\xa8\xab\x19\x49\x69\x9a\x71\x30\x59\x1a\x41\xae\x2c\x53\xd1\x10\x29\x45\x47\x41\xf5\x7c\x5f\x16\xb2\x36\x99\x37\xa6\xb7\x38\x0d\x30\x53\x02\x4c\xcf\x66\xb0\x32\x7e\x18\xeb\x99\xa8\x00\xba\xe5\xf3\x86\x8f\x03\xfe\x95\x42\xb1\xf7\xdd\x26\x9e\xd6\x2c\xc2\x4f\x25\xfc\x18\x4e\x0b\xe4\x9c\x78\xfc\x54\xad\xd2\x49\xa8\xa2\xb0\x6d\x90\xa8\xed\x7f\x3c\xef\x82\x21\x1f\xd8\xf1\xfc\x0b\x04\x51\xe8\x5d\x9c\x46\xd9\x2e\x88\xa8\xa0\x01\x78\x86\x96\x0b\x27\x03\xd8\x89\x7f\xa0\xe1\xc6\x3f\xba\x4a\xd3\x29\x0d\x87\xf2\xab\x7d\xfa\x30\x9a\x5b\x4b\x52\xc1\x7a\xdc\x4d\x86\x25\xca\xa6\x69\xb1\xc8\xd6\xa0\xb9\x08\xe0\xce\x5b\x5a\xa9\x31\x51\x2d\x45\x63\x23\x3e\x1e\xf9\xa4\xf0\xca\x05\x5a\xe7\x3d\x29\xac\xfb\xb8\x57\x49\x20\x61\x20\xb7\x39\x0f\x54\x06\x78\x14\x5d\x34\x44\xfd\x1e\xd4\xea\x70\x09\x1e\x94\x29\xdf\xc4\x2b\xe1\xff\xf5\xfa\xfd\xbb\x40\xfa\x6f\x0d\x6e\x89\x68\xe9\xb9\xc1\x47\x43\x8b\x4a\x46\x57\x5e\xeb\x90\xa2\xad\xa6\xc3\x31\xe7\xd3\x24\xb9\x68\x9f\x00\xab\x47\x4b\x2e\x6a\x87\xb1\xb1\x25\x2f\x17\x9d\x66\xaf\x99\x76\x8c\x1b\xf4\x4d\x9f\xad\x57\x71\x45\x87\x2d\xe5\x53\x24\x8c\xf9\x9f\x9f\xc0\xa5\xe6\x64\xa0\xd5\x8b\x65\x36\x3a\xdd\xdb\x08\x4f\xa7\x6a\x0c\x5e\x1c\x91\x27\x23\x41\x1d\xad\xa3\x18\x92\x20\x42\xfa\x8a\xb6\x77\xcf\xf6\x90\xe1\xbc\x0e\x05\xb7\xdc\x0a\x1f\x0e\xf5\x1f\xb0\xda\x16\x5b\xc4\x22\x81\x09\xef\x4f\xd6\x86\x2d\xe7\xca\x3c\x61\x1c\x26\x17\xd6\xe4\x61\x6c\x09\x29\xcb\xbd\x0b\x19\x3f\xf6\x14\x87\xec\x1c\x0a\x20\xf2\x83\x45\x44\xb0\x2d\xa0\x09\x5c\xdc\x23\xe7\xcd\x6a\xe1\x32\x8a\x1f\x48\xba\x9e\xf0\x4d\xb6\x5b\x78\x70\x48\xf9\x1b\xdb\x73\x7f\xe4\x5d\x0d\x2d\x48\x05\xbd\x79\x75\xf5\xf9\xb3\x94\xe4\x70\xb8\x9c\xc2\x9b\x1b\x4c\xd0\x65\xc2\x48\x88\x95\xcf\xc2\x53\x2f\x10\x3b\x14\x52\x9f\x13\x41\x23\x61\x5f\x71\x02\x22\x32\x2a\x16\x9e\x0f\xba\x1f\x13\xb9\xbf\x8c\x48\x9c\x6a\x15\x75\xe3\x6b\x1a\x1b\xe0\x8f\x00\x2a\x41\xfe\xe0\xed\x04\x78\x84\xe0\x6a\x13\x93\x08\x51\x0f\x7f\x53\x51\x74\x88\x3b\x95\x22\xb6\x26\x61\x02\xb4\x7b\x35\x6c\x5a\x23\x89\xd3\x7b\xc3\xa1\xce\xa4\xc3\xb8\x62\x4b\xcd\x04\xda\x26\x66\x6e\xee\x3a\xae\xc9\x26\x16\x0a\x0f\xfa\xd2\xd8\x4a\x6d\x80\x50\xa7\x02\xcc\xbc\xd6\xd1\xac\x7a\x34\xc8\x8a\x42\xe0\x3a\x8e\x73\x9d\xb1\xaa\x93\xed\x58\x65\x5b\x34\x38\xff\x32\x31\x53\x91\x72\x06\x8d\xb7\x14\x69\xe7\x5c\x96\xc2\x6c\x98\xc4\xe1\x3d\x82\xbc\xf0\x7e\x63\x54\x1d\x43\x67\xd8\xb6\x6e\x99\x54\x2e\x9e\x52\x08\x22\xfa\x30\xc9\xe3\x24\xe9\xf0\xd1\xe1\x65\x12\x9b\xfe\x0d\x92\x49\xb7\xa6\xcb\x77\x74\x0b\x8e\x8d\x73\x9a\xda\xe7\x8f\x43\x95\xb0\x3e\x42\x29\x1f\xf3\x1b\xba\xf4\x01\x6c\x74\xe8\x0b\x1e\x4a\x36\x05\x5b\x2d\x3c\x4d\x07\xe4\x5e\x78\x9c\x89\x0a\xd7\xc3\xb0\xa5\x4b\xf4\x71\xda\xe5\xc4\x49\xdc\xcb\xb8\xca\x10\x83\x01\x6a\xbd\x92\x3b\x2f\x93\x64\x52\xe0\x51\xd9\xbb\x22\x97\xcb\x52\x08\x28\x7a\xf4\x1c\xc0\x11\xf8\x9b\xe4\x45\xbc\x85\x9a\xda\x64\xec\x68\x1c\x5f\x72\x06\x6f\xc8\x99\xc2\xbf\x72\x72\x02\xfc\x95\x49\x7f\x28\x41\xed\xc9\xc5\x4f\x30\xe4\x4b\x7f\x1a\xc3\xfe\xc3\xb6\x2c\x15\x63\x42\x85\x28\x5a\x07\x1a\x7c\x74\x8c\x68\x1b\xe0\x85\x06\x42\xe2\x00\x0f\x78\x9e\xc4\xc2\xf7\xc6\x76\xe7\xff\x88\xa7\x8c\x04\x2a\x06\xc4\x26\x25\xd8\xbb\xbb\x90\x94\x51\x1a\x95\x56\x57\x38\xb7\x57\x11\x27\xa3\xf2\x88\x77\x06\x19\x17\xa4\x0f\xa4\x03\xd5\x1c\x56\x6f\xa7\x57\xaf\xf2\x05\xee\x9f\x16\x27\xa7\x73\xc3\xb8\x33\x39\x60\xab\xe3\x0b\x25\x07\x4d\xaa\xb5\x69\xaa\x49\x79\x92\x53\xd3\x7d\xc1\xdf\x1b\xe1\x9b\x57\x47\xcf\x37\x9a\xd6\x1d\x11\x0e\x5b\x89\xd9\xcb\x3e\x1c\xd4\xb5\x13\xe9\x05\x86\x63\xac\xd9\x97\x3b\x1c\x1c\x9e\xaf\xf7\x59\x07\x83\x8d\xdd\x7b\x60\xb6\xe9\xb1\xcd\x68\x34\xf5\xbb\x92\x12\x54\xad\x1d\x22\x5e\xca\x76\x2e\xcc\x01\x81\x68\x82\x6d\x42\x0d\x80\xa7\x6e\x98\x9e\xe2\x3c\x7c\x00\x85\xce\x9d\xef\x09\x4c\x1b\x3c\x77\xf1\xa8\xaa\x30\x55\x40\x2d\x3c\x29\xdd\xcf\xaa\x9a\xd0\x55\x81\xa6\xe2\xa6\x61\xf0\x72\x8a\x8d\xaa\x47\x5b\xe7\x37\x9a\xb0\x42\x9c\xb0\xb6\xea\xea\x3a\xed\xa6\x77\xff\xd3\xdb\xff\x98\x9c\x61\xb2\xea\xa9\x02\xce\x76\xd8\x3c\xf5\xcd\xb0\xa9\xc2\x45\x43\x20\x33\xb0\xb1\x3b\xae\x83\xec\x07\x0b\x7a\x03\x09\x5d\xc5\x78\x55\x02\xbd\x5f\xad\x20\x53\xc1\x31\x6b\xf2\xb2\x8b\x0c\x3e\x78\x7a\xcc\x14\xe8\xa2\x8b\x88\xd5\xe0\x31\x9f\x3a\x75\x63\xeb\xe6\x1d\xf8\x13\xf7\x53\xf8\xdf\xb7\x62\xb5\x6a\x8d\xa1\x6f\x50\x7b\x11\xea\x75\xd6\x90\x7d\x9f\xb5\x54\xa5\x2f\x84\x0d\xf0\x5f\x75\xe1\xf5\xcd\x53\x30\xf1\xa9\x19\x55\x57\x82\x72\xd1\x76\x02\xb5\x9f\xce\xd3\x93\x53\xbc\xfa\x4a\x1c\x94\x31\x1b\x13\x38\x23\x63\x9b\x04\x59\x0e\x37\x71\x12\xa9\xe5\xab\x2b\xf2\x98\x5c\x22\x84\xf3\x72\xb3\xef\x69\xe9\xce\x20\x7e\x1b\xdb\x87\xd5\x4e\xc1\x4e\x8b\xde\x0b\x71\xe8\xb7\x74\xdb\xc0\xfd\x6c\x55\xbb\x5c\x85\xd3\x0d\xdd\xb3\xb0\xf4\xc7\xca\x19\x8f\xae\x2c\x63\x3a\x58\x41\xfa\xf4\x3d\x4c\x96\xde\x28\x00\xf6\xfc\x99\xdd\x39\x30\x1f\xfb\xd7\x0d\xc2\xb2\xdd\xf9\xf9\x29\x93\xe9\x70\x9c\x2c\x34\x4b\x60\x1a\xe9\xb8\xff\xc4\x53\x4e\x45\xa8\x97\x02\xfa\xbb\xa6\x72\x49\x5a\x9d\x32\xd7\x73\xe6\xd7\x6b\x8d\x02\x55\x77\xf8\xa3\x20\xe4\xdc\xf7\x96\x34\xbc\x5f\xcb\x66\xe1\x24\xcc\x12\xc8\xad\x63\x28\xce\x0b\x9a\xae\x99\xab\xb0\xb2\x9f\x65\xc1\xe8\x7d\x3f\x58\xb7\xbb\xb8\x67\xda\xa3\x87\xee\x2e\x44\xd5\x46\x32\x9a\x04\x21\xec\xd6\x45\x96\xc0\xce\x50\x75\x09\xfe\x22\x44\x8e\x2d\x39\xcf\x91\xd7\xd5\x25\xbd\xa9\xc3\xfa\x60\x96\xa6\x10\xe2\x56\x67\xef\x1f\x31\xb6\xe7\x59\x70\xa3\x66\xe7\x2d\xac\xc7\xaa\x41\x0a\x54\x03\x9a\x24\xfe\xc8\xee\xe2\x0f\x34\xbd\x7f\xb1\xe5\x75\x16\xde\xb3\x56\x81\x87\xde\xb5\x93\x7d\x54\xb6\x23\x35\x94\xef\xed\xf8\xc5\x74\xea\x91\x73\x92\x64\xea\x5a\x38\xd8\x40\x41\x8d\x2d\xcb\xe9\xbf\x77\xdc\xb6\xd6\x8e\x07\x59\x0a\x2c\xa5\xa7\x0e\xa6\xa0\x2f\x9e\x25\xe0\x79\xd9\x1a\xc3\x00\xe4\x0a\x05\x8b\xc8\x8e\x2d\xb9\x5c\x58\xba\x3c\x98\x39\x99\x88\x78\xcb\x48\x99\x83\x22\x58\x6b\xb9\x83\x95\xe1\xe5\xf2\x50\x69\x73\xba\x6e\xb4\x2d\xf5\x50\xdf\x4e\xaa\x7e\x7c\x71\x72\x97\xac\x74\x1c\x45\x15\x51\x69\x22\xd7\x3d\xe7\x53\x78\x65\xfa\x47\x0c\xf5\xba\x30\x70\x52\x5d\x60\x1d\xa2\xb5\x24\xf1\x2f\xbc\x51\x2f\x02\xd2\x7c\x02\x2f\x61\x92\xf1\x86\xd6\xc2\xc4\x79\x3c\xea\xe2\x45\xe2\x47\x17\xe8\x2b\x88\xd9\x5a\xb1\xfe\xda\x2c\xfd\x31\x4e\x3e\xb0\x5f\x4a\xc6\xc5\xb3\x42\x45\x9e\xcf\xf7\x7d\x7e\x76\x16\xd0\x3b\xfa\xe8\x3b\x9a\x6e\x7b\x3c\x3b\x78\x3f\xbf\xbf\xbe\x71\x1c\x12\xca\x22\x81\xc9\xe9\x06\xf8\x9a\xc6\xe9\x54\xad\xe3\x80\x43\xbb\xc3\x09\x04\x39\x8c\xa3\x8b\xda\x33\x54\xab\x70\x84\x3d\x46\xeb\xaa\xdb\x79\x01\x22\x7f\xa7\xc2\x84\x75\xf3\xea\x8a\x4e\x21\x6f\x7e\xac\x65\x1c\x9d\x64\xec\xca\x56\x8d\xce\x0f\xcd\x1b\x5c\x4b\x85\x6a\x51\x9b\x71\x18\x7d\x62\xcf\xb6\x41\x26\x85\x53\xff\x89\x8c\x69\xe0\xf4\x54\xae\x16\x3f\x58\xbc\x2a\x05\x8d\xe6\x2e\xcf\xe1\x39\xf8\x23\x73\xb9\xce\x59\x7d\xd4\x6f\x3b\x51\xad\xf6\xe6\x8d\xed\x97\xd3\x7b\xf3\xe6\xb7\xa5\x31\xb9\x6c\x5b\xf3\x3c\x7f\xae\xea\x25\xa1\xff\xbb\xee\x8f\x97\x92\x95\xf2\x61\x6b\x7b\x47\x1f\xde\xc2\x41\xf9\x59\x51\x5b\xb5\xed\x2d\xa5\x5b\xeb\x57\x50\xfe\x71\x83\x1b\x99\xed\x49\x3d\xbc\xa5\xf7\xcc\x49\xae\x79\x96\x6f\xc8\x64\x52\xec\x6c\x47\xe8\xbd\xbb\x5b\x32\x70\x2e\xdc\x97\xb2\x52\x38\x9c\xca\xba\xa2\x6d\xa5\x25\xc5\xc1\x4d\xbf\x5b\x99\x29\x5a\x63\xbc\xbd\x7e\xff\xdd\xab\xd9\xcb\x46\x9f\xa6\x95\xa1\xb5\x2b\x3a\x33\xb7\x56\x32\xee\xf0\xdf\xcb\xab\x16\x5e\x5d\x95\x06\x22\x03\xea\xfa\x26\xb7\xaf\xf0\xb1\x79\xba\x01\x2d\xfc\x28\x7f\x6a\xf4\x3f\xb3\x75\x16\xa0\x4a\xe9\x3a\xf3\x9b\xf2\x22\xb5\xe7\xb0\xf4\x6c\xf7\xbe\x91\xbe\x50\x93\x99\x4e\x89\xd8\xc4\x9c\x84\xb4\xe4\x50\xbb\xeb\x5f\x33\x13\x91\xe9\x2a\x04\x66\xe1\x0f\x58\xe5\x82\x6e\x73\x5e\xe3\x01\xa5\xb7\xa9\x60\xc5\x03\x4d\x9a\x8b\x77\x97\x18\x87\x91\x3c\x9b\x7f\x33\x3b\x5e\x57\x83\x0f\xe2\x46\xf1\xdf\x00\x00\x00\xff\xff\xb8\xd2\x0f\x52\x50\x2d\x00\x00"
+var _assetsClientStaticJsNgrokJs = "\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xbc\x5a\x7b\x73\xdb\xb8\xb5\xff\x5f\x9f\xe2\x88\xe3\x6b\x91\x1b\x99\x52\xb2\x7b\x73\x77\xf4\xf0\xdc\x26\xbb\x3b\x4d\xbb\x4d\x76\x6c\xb7\xfb\x87\xe3\xe9\x40\x24\x24\x21\xa6\x00\x86\x00\x2d\xa9\x5e\x7d\xf7\xce\x01\x41\x0a\x04\x49\xd9\x49\x33\x25\x35\x89\x04\x9c\xc7\xef\xbc\xf0\xf4\x03\xc9\x80\xaf\x32\x71\x0f\x73\x20\x7c\x95\x27\x24\x0b\x37\x22\xce\x13\xea\x7b\xba\xdd\x1b\xc2\xad\xc7\x57\xd7\x84\x33\xc5\xfe\x45\xbd\xbb\x60\xda\xeb\x21\xd7\x9a\xee\xae\x68\x9a\xc1\x1c\x96\x39\x8f\x14\x13\xdc\x5f\xec\x15\x95\x01\x3c\xf6\x00\x00\x90\x66\x91\x2f\x61\x0e\xb7\x77\xd3\xaa\x85\xc8\x88\x31\xab\x6d\x29\x32\xf0\x91\x94\xcd\xc7\x53\x60\x33\x2d\x22\x4c\x28\x5f\xa9\xf5\x14\x5e\xbc\x60\xa5\xb8\x4a\x24\xcc\x41\x13\xdd\xb2\xbb\x69\xaf\xea\x62\x4b\xf0\xfb\x3e\xfb\x9f\x1f\x03\x38\x3f\x07\xd6\x9f\x8f\x6d\x46\x7c\x17\xf9\x32\x4c\x73\xb9\xf6\xbd\x8f\xca\x0b\xa6\xad\x7d\x21\x49\xd3\x64\xef\x2f\xf2\xe5\xb0\x40\x1a\xb4\x8b\x18\x7c\xe4\x03\x47\x84\x6b\x18\xbe\x87\x3a\xbe\x05\xcc\xe0\xe5\xeb\x6e\x5c\x63\x2f\x38\xc9\x3b\xde\xbd\x1a\xc3\x1f\x7f\xc0\x02\x2e\x61\xbc\xfb\x3f\xea\x4a\xd2\x10\x0c\xc0\xd0\xc6\x77\x00\x9a\x48\xda\x4d\x7d\xad\x32\xc6\x57\xe1\x32\x13\x9b\xb7\x6b\x92\xbd\x15\x31\xf5\x17\x41\x3b\x98\x0a\xee\x22\x54\xa2\x60\xf4\x5f\xbe\xb6\x89\x8f\x06\x81\x6d\x90\xa5\xef\xd8\x61\x04\xa3\x77\x8a\xfe\x22\xf2\x68\xa0\x6d\x1d\x06\x3e\x5a\x93\x4c\xfe\x4a\x97\x0a\xe6\xf0\x23\x5c\x38\x0c\x23\x78\x65\x44\x56\x59\x65\x32\xaa\xe2\x6b\xa4\x93\x03\x16\x6a\x70\x0f\xbd\x26\x49\x3d\x71\xba\x93\xa6\x66\x5b\x46\x55\x9e\x71\x2d\xe5\x93\x60\xdc\xf7\x50\xc6\xa1\xd7\xd3\xd5\x15\x2e\x49\xa4\x44\xb6\xf7\x3d\xb5\xe3\xd7\x0f\x91\x37\x3c\x56\x53\x09\x15\x8d\x4f\x33\x11\x51\x29\xdf\x88\x78\x5f\x2b\x38\x11\xef\x87\xb0\x60\x9c\x64\x7b\xdb\xb2\x85\x88\xf7\x61\xd1\x8c\xe5\xa2\xbf\x58\xc0\xb1\x97\xc9\x5f\x44\xb6\xc1\x5e\xfc\xf5\x56\x70\x45\xb9\xba\xd9\xa7\x14\xe6\x73\xf0\xb0\x0e\x58\x44\xb0\xaa\x47\xbb\x8b\xed\x76\x7b\xb1\x14\xd9\xe6\x22\xcf\x12\xca\x23\x11\xd3\xd8\x73\xc4\xd1\x1d\x93\x4a\x96\xe2\x7e\xad\xa2\xe8\x90\xad\x89\xfc\x39\xcb\x04\x8e\x1a\xfd\xbe\x6e\xd1\x3f\xad\x42\x46\x6b\xe5\x9e\x2b\xb2\x7b\x9b\x10\x89\x12\xeb\x01\xf3\x14\xdd\xa9\xd1\x6e\x93\x78\x93\xb2\xc9\x3c\x1e\x36\x0e\xeb\xc4\x35\x3b\x6c\x9e\x36\x62\x2d\x79\xad\x9a\xa2\x3b\x89\x23\x29\x5d\x5a\xf0\xb0\xf1\x04\x8c\x4f\x52\xf0\x8a\xc9\xd3\xbf\xda\x44\x7f\x22\x0f\x44\x46\x19\x4b\xd5\x51\x83\x67\x35\x9e\xd2\x60\xb3\xb6\xf3\x1c\x6e\xdd\xb0\xdb\x83\xe9\x68\x04\x31\xc5\x30\xeb\x68\x56\xcd\x58\xa5\xcd\x5c\xab\x62\x7b\x43\x77\x58\x9b\x9e\xf7\xc4\xc0\x63\x13\xbf\x21\x92\xbe\xfe\x21\x2c\xb4\xf9\x55\x4f\x10\xa2\x7b\x2d\x39\x35\x6c\x69\x46\x95\x62\xcb\x7d\xd5\x86\x39\xa3\x32\xc2\x25\x26\x69\x33\x63\x30\x7a\x93\xd6\x20\x9a\x58\x14\x51\xa8\xba\x0e\xb7\x56\x02\x36\x26\x99\x7a\x1e\x9f\x9f\x43\xbf\x5f\xe9\x76\xfd\xa2\xb2\xbd\xd3\x62\x4c\x78\xb8\x5f\x50\x92\xa3\x11\x10\x0b\x2a\x21\x15\x22\x4b\xf6\x40\x14\xa0\x09\x44\x29\xc6\x57\x80\xa9\xd8\x60\x46\x0c\x8d\x92\xed\xcf\x4d\xd6\x20\x8b\xe7\xa2\x68\x73\xfc\x11\xc0\x6d\x85\xfe\xce\x0a\xc0\xb4\xe7\x30\x5b\x03\x22\xbe\x07\x88\x88\x8a\xd6\xe0\x37\x66\xa1\x23\xdd\xc1\xf1\x5c\xdf\x72\xab\xcb\x65\x63\x5b\x27\x9f\x64\xb8\x66\xab\x75\xc2\x56\x6b\xe5\x5b\x5c\xc3\x23\x5d\x10\x3e\x90\x24\xa7\x4f\xe4\xda\x68\x04\x95\xa0\xf0\x93\xd4\xce\xe6\x03\x05\x6b\xf2\x40\x81\xc0\x20\x4d\x08\xe3\xe8\xb9\x81\x19\x74\x86\x20\x05\x6c\xe9\x20\x49\xe0\x53\x2e\x15\x44\x22\xdd\x03\x53\x12\xa8\x8c\x48\x8a\x51\x29\x07\xdf\xb0\x13\x7f\xf5\x3d\xcc\x68\x9a\x90\x88\xfa\xa3\xf3\xd1\x6a\x33\x84\xc1\x39\xd9\xa4\xd3\x41\x70\x6c\x9f\x99\xf6\x44\xd5\x9a\x2f\x4d\xf3\x0a\x9b\x2d\x13\xf5\xb7\xc3\xb4\xe7\xce\x0b\x57\xf4\xb3\x3d\x2d\x64\xf4\xb3\xed\x5f\x4c\x99\x7e\x46\x3f\x87\x57\x64\xfb\xc6\x5e\xa2\x95\x0f\xd6\x4f\x51\x82\x71\xa3\x26\x0d\x9f\x85\x02\x3f\xb6\x34\x98\x97\x6b\x41\xdf\x08\x09\xf5\x02\x2d\xb0\x2a\xa7\x06\xe3\x4d\xeb\x18\x62\x89\x35\x7e\x2c\xa5\xd5\xc7\x82\xce\x1c\xb3\xe6\x48\x1f\x05\xe1\x64\x39\x04\x4b\xe1\xb4\xdb\x7d\x32\xad\xfb\x4f\xa6\x36\x3c\xfc\x1d\x4a\x45\x54\x2e\xdb\x27\xa5\xc1\xab\xc1\xa4\xa8\xc0\x0b\xc6\x97\xc2\x19\x66\x06\xdf\x63\xef\x26\x57\x34\x76\x7b\x7e\xa8\xf8\xb6\x24\xe3\x8c\xaf\x5c\x82\xff\xad\x08\x28\x4e\x94\xf6\x20\xa5\x51\x5d\x6b\x54\xb7\xe3\xbb\xc6\x38\xa5\xbb\xbf\x2e\xe2\x32\x6d\x0d\xb9\x4c\xbf\x36\xe6\x32\x2d\x63\xe0\xe0\xb0\x05\x7f\x8b\xa8\xcb\xb4\x0a\xbb\x4c\x9f\x8c\xfb\xcd\x8e\xdb\x61\x57\x3b\x6e\xe3\x33\x44\x57\xf4\xb3\xaf\x76\x3c\xbc\xa2\x9f\x83\x69\xb3\x53\xa6\xa6\x57\xa6\xad\x8a\xe8\xb3\x74\x21\x28\x25\x7e\x61\x3b\x1a\xdb\x54\x7a\x88\x1b\xa2\x94\x88\x49\x26\x6a\x2c\x95\x0a\xb1\xa5\xb8\x9e\xfa\x1b\x51\xeb\x30\x15\x5b\xff\xe5\xd8\xe2\xc0\xfd\xc2\xd8\xc2\x6d\xad\x48\xcd\xe2\x5d\xf3\x65\x22\xe7\x71\xa1\x0e\xbe\x2b\x44\x06\x30\x32\x5f\xa6\xbd\x66\x04\x70\x1a\x26\x99\xa4\xc0\x09\x17\x92\x46\x82\xc7\x10\x89\x9c\xab\x8a\x02\xb1\x71\x4c\x14\x74\xcf\x4f\x79\xa6\x17\x61\x47\x51\xd8\xbd\xc1\x6e\x2e\x61\x04\xfe\xcb\xf1\x78\x0c\xdf\x01\xfe\x67\xa1\xb5\x59\x61\x0e\x1b\x79\xec\xc2\x1c\xdf\x48\xb8\x2c\x58\x1c\xbf\x38\x7c\xc6\xb3\xfe\x06\x55\x21\xfd\x10\x5e\x05\xf0\x02\x3c\xf9\xd4\x5a\xa5\x53\x50\x29\x61\x53\x13\x51\xc5\xbf\x4a\x00\x12\x29\xf6\x60\x66\x29\x34\x59\xed\xb4\x4f\xb6\x8c\xc7\x62\x1b\xc6\x44\x91\xf0\x66\xc7\x8d\x5d\xd8\x19\x2e\x45\xf6\x33\x89\xd6\xfe\x31\x55\x6c\xeb\x6a\x09\xe5\x97\xf3\xf4\x21\x98\x3a\x2a\x89\xa2\x27\xd2\x0d\x9d\xd7\x47\xdb\x8c\x2c\x1a\xdb\xbd\x56\x7e\x6b\x25\x3b\x1e\x4c\x1b\x5e\xa9\x38\xd1\x2d\x59\x6d\x22\xae\xbe\x69\x24\x88\x43\xed\x78\x63\xbf\xa7\x8d\x6d\xdf\xee\x95\x16\x60\x65\xc9\xdb\xf1\x5d\x50\x63\x36\xe9\x6b\x91\xc7\xf1\xa4\x66\xea\x4f\x44\x11\xd7\x20\x14\x15\xe6\x5c\xae\xd9\x52\xf9\x7f\xb9\xfe\xf0\x3e\xd4\xf9\x5b\x91\x07\xd3\x1a\xb9\xe3\xe7\x1a\x8e\xf2\xd5\x5e\xd4\x58\x1b\x8b\xa0\x13\x56\x34\x6b\xe9\x70\x1c\xf3\x49\x92\x58\xa6\xb8\x52\x8d\xe5\xaa\x4a\x18\x97\x5b\x63\xa9\xfb\xc2\x95\x51\x86\xde\x6d\xb7\xe4\xdb\x39\x7b\xb2\x3a\x5c\x2b\x9f\x63\x21\x93\x7f\x7a\x06\x4a\x83\xa4\xdf\x37\x29\x74\x7e\xae\x93\xee\x5d\x8c\xbb\xd3\xa2\x2d\x7c\x17\x1f\xd5\x55\x95\xa7\x2b\xa1\xd8\x5a\xc7\x2c\xa3\x9a\xd2\x2f\x2c\xf5\xee\xe9\xfe\x81\x24\x9e\xa5\xd9\x56\x6b\x54\xd6\x71\xc8\x48\xa4\x74\xe2\x80\xc3\x8f\x62\x2a\xa1\x13\xf0\xfe\xdf\x99\xb0\xf1\xa3\xf2\x34\xa1\x72\x02\xde\xdc\xe9\xb4\xdc\x80\x1f\xb3\xdc\x9b\xe8\xfa\x71\xbb\xa4\xca\x58\xa4\x26\xe0\xfd\xec\x08\x51\x74\x93\x26\x44\xa1\x72\x0f\x5e\xd4\xba\x06\xb3\x98\x3d\x00\x5f\x5d\xc8\xb5\xd8\xce\xbd\x35\x91\x7f\xa5\x7b\xe9\x07\xde\xe5\xc0\xa1\xc4\xcf\x60\xb6\x7e\x7d\xf9\xf8\xa8\x2d\x39\x1c\x66\xa3\xf5\xeb\x0e\x32\x45\x16\x09\x85\x08\x57\x3e\x73\xaf\xf8\x91\x92\x8c\x6c\x64\xbb\x5c\xc3\x84\xe7\x8a\x17\x19\x4d\x29\x51\x73\xcf\xbf\xa7\xfb\x21\xe8\xf9\x25\x00\xc6\x8d\x8b\xba\xf9\x8d\x8c\xf5\xe5\xe3\x23\xdc\xd3\x3d\x20\x3e\xb5\x7e\x8a\x3c\x46\x72\xad\xa4\x60\x88\x3b\x19\x06\xb3\x91\xca\x5a\x7b\xb1\x07\x4d\x6c\x74\x0e\x66\xa3\x98\x3d\x5c\x0e\xea\xd1\x48\x18\xbf\xb7\x12\xea\x4c\x27\x8c\x9b\xcd\xf8\x16\x3d\xa1\x89\x09\xcc\xdb\x93\xd0\x7e\xf4\x21\x16\x1a\xcf\x78\xc9\x5d\xb8\x2d\x80\xc7\x32\x5b\x31\x73\xa6\x56\xa1\xd9\xaf\x21\x59\x92\x44\x3a\xc5\x6c\x6a\xa5\xa3\x56\xcd\x60\x3b\x2c\x66\x14\x0c\xb8\xfc\x36\x35\x53\x8a\x6a\x2d\x1a\x6f\xa1\x78\x67\x9f\xe0\x0b\xc5\xa3\x84\x45\xf7\x48\x72\xee\x7d\x65\x55\x1d\x4b\x67\xd0\x8c\x6e\x9e\x94\x29\xce\xc9\x03\x70\xf2\x70\x91\xb2\x24\xe9\xc8\xf1\xc1\x2c\x61\x76\x7e\x2b\xb2\xd0\x69\x4d\x16\xef\xc9\x86\x4a\x0f\xfb\x8c\xb4\xc7\x8f\x83\x62\xc0\xfa\x38\x98\x00\x93\x37\x64\xe1\x2b\xb2\x08\x0e\xdd\xc9\x3f\x98\x11\x58\x67\x74\x39\xf7\x8c\x1c\x16\xdd\xcf\x3d\x49\x55\xc9\xeb\x61\xd9\x92\x05\xe6\x38\xe9\x80\x37\x4a\x58\x47\x4f\x01\xbc\x18\x21\xfa\x7d\xf4\x7a\x69\x77\x9a\x27\xc9\x45\x86\x7b\x6e\xef\x12\x66\x8b\x5c\x29\xc1\xcb\xbe\x85\xe2\xb0\x50\xfc\x22\xcd\xd8\x86\x64\x7b\x1b\xd8\x31\x38\xbe\x46\xb6\x50\x1c\x91\x15\xfc\x97\xad\x48\x06\xb3\x51\x9e\x9c\x2e\x25\x48\x85\x54\xbf\x32\x7e\xef\xeb\x7c\x1a\x02\x4d\xe8\x86\x72\x35\x04\xa2\x54\xd6\xd8\xd0\x54\x89\x17\x96\x31\xc0\x0b\x0d\xa4\xc4\x06\x19\xca\x34\x61\xca\xf7\x86\xf6\x01\x6e\xf9\x14\x7c\x45\x90\x6e\xc8\x02\xe6\x8e\xa4\xdb\xf1\x5d\x17\x53\x11\x94\xda\x4a\xab\x0d\x59\xbb\x16\xf5\x64\x55\x1e\xf9\xce\x52\x92\x51\xae\x42\x26\x6d\x84\x4c\x3e\x57\xbb\xa9\x54\x85\xf3\xa7\x83\xe4\x49\x14\x56\x81\x99\x9e\x6a\x70\xc0\xa3\x8e\x6f\x34\x38\x18\x51\x8d\x49\x13\x3f\x5e\x71\xec\x58\x74\x77\x61\x7b\xb2\xc2\xd7\xaf\x8f\x99\x6f\x1d\x5a\x77\x54\xf8\xe3\x63\xed\x2c\xfb\x70\x28\xae\x9d\xe0\x24\xf1\x15\xd9\xda\xe7\x72\x87\x83\x4b\x3d\xa8\xe6\xd9\xc1\xe0\xf4\xec\xdd\xb7\x8f\xe9\xf1\x98\xd1\x3a\xd4\xef\x80\x3c\x4b\x33\xda\x61\xe2\x0c\x37\xe9\xd8\xb7\x60\x3c\xbe\xc0\x63\x42\x43\x80\xbb\x6e\xef\x72\x36\xc2\xfe\xcb\xd9\x28\xcd\xba\x67\xbe\x67\x80\xb6\x30\x77\x61\x2c\x56\x61\xa0\x97\x1d\x73\x4f\x5b\xf7\x5b\xb1\x9a\x30\xab\x02\x23\xa5\x5d\x86\x85\xe5\x29\x18\xe5\x19\x6d\x35\xbe\x91\x84\x66\xca\xbb\x3c\x19\xc0\xe2\x54\xf7\x70\xe8\x9e\xfd\x4f\x8c\x59\xfe\x99\x19\xab\xce\x70\xb0\x3a\xb1\x0a\x38\xdb\x12\x65\x6f\xf1\xac\x19\xdd\x50\x20\x98\x29\x1c\x5a\xae\x83\xdc\x17\x17\xf4\x16\x13\x5e\x7f\x5a\x3f\x0b\x83\x3e\x2c\x97\x92\x2a\xb8\x84\x8b\x97\x5d\x62\xca\xdd\xa3\x28\x48\xe7\x5d\x42\xcc\x46\xb3\xed\x2d\xa1\x02\x9e\x57\xbd\x17\x31\x95\x3e\x17\x31\x3d\xa5\xb1\xd4\x5a\x71\x98\x1b\xd4\x93\x0c\x95\x9e\x15\x55\x37\x5f\xa4\xaa\xf4\x17\xd2\x86\xf8\x4f\x79\xe1\xf5\xfd\x73\x38\xf1\xad\x80\x16\x57\x82\x28\xa3\x65\x46\x71\xdf\xce\xdd\x53\xdb\x7b\xbc\x12\x87\x39\x8c\x87\x90\x50\x3c\xa5\x42\x4d\x61\xb4\x66\x49\x5c\xa8\x2f\xaf\xc8\x19\xcc\x90\xa2\xf5\x72\xf3\xd4\xdb\xf0\x9d\x25\xfc\x96\xb9\x9b\xd5\xae\xe7\xd0\x7b\x8a\xe4\x34\x85\xd9\xd2\x3f\x1b\xe4\x13\xb0\x4c\x0d\x55\x41\x9a\xf6\xbe\x42\xb1\xce\xc7\x32\x19\x2b\x51\xbe\xae\xe9\x70\xc9\x78\xec\x7b\x38\x58\x7a\x41\xb8\xa2\xca\x1f\xdb\x57\xdf\xdd\xa1\x2c\xee\xa2\x95\x13\xbb\x17\x2f\x9e\x0a\x99\x29\xc7\x8b\xb9\x81\x74\xcb\xee\x74\xe2\xfe\x03\x77\x39\xa5\xa0\x93\x12\x30\xdf\x8d\x94\x59\xfd\x98\xa5\xeb\x39\xf3\x2b\x5d\x01\x9e\x92\x50\xae\xfc\x20\x8c\xa4\xf4\xbd\x05\x89\xee\x57\xfa\xb0\xf0\x22\x12\x89\xc8\xbc\x21\x78\x22\x23\x7c\x45\xdb\x16\x56\xee\xb3\xc8\x28\xb9\x9f\xf6\xbe\x2e\x5d\x0e\xbd\xe7\xb5\x1e\x1c\x20\x87\xc6\xe2\xc5\x3e\x24\x88\x04\x57\x99\x48\x12\x9a\x95\xa7\x04\x7f\x56\x2a\xc5\x23\x39\xaf\x65\x5c\x2f\x2e\xe9\x6d\x1f\x9a\x51\x52\xe5\x9c\xd3\xc4\x3d\xd9\xfb\x3b\xc3\xe3\x79\x1a\xde\x14\xbd\x53\x97\x4b\xd2\xec\x81\x66\x1d\x4c\xd7\xba\xb3\xc1\x63\x0e\x10\x0b\x24\x21\x49\x12\xbf\x3c\xfb\x2b\x83\xdd\xef\x1b\x71\xbf\xd3\xc5\xb5\x88\xee\x69\x63\x51\x88\x19\xbe\x45\xb0\x9c\x6e\xa1\xa2\xf2\xbd\xad\x9c\x8c\x46\x1e\xbc\x80\x44\x14\x57\xc9\xe1\x5a\x48\x85\xc7\x9c\xa3\x7f\x6e\xa5\x1b\xe1\xad\x0c\x05\x17\x29\xad\x9d\x6b\xbb\xaa\xf0\x8d\x04\x97\x22\xa1\x61\x22\x56\x58\x3a\x9c\xd3\x48\xd1\x18\xb6\x74\x21\xb5\x62\xbc\xf3\x84\x8c\x92\xe4\x42\xb1\x0d\x85\x3c\x8d\x89\xa2\x0d\x75\xe5\xb1\x7a\xf9\x68\xf5\x1b\x2a\x25\x59\xd5\x8e\x3a\x4d\x53\x1b\x10\xe3\xc2\x33\xfc\xab\x80\x7d\x6d\xf6\x6d\x90\x9a\x23\x43\xed\xe3\x38\x2e\x85\xea\x08\x05\xd3\xa7\x73\xae\x0d\x2b\x35\x7f\xf8\x50\xe9\xa5\x59\xf6\xa4\xbb\x7e\xa7\x0b\x30\x5e\xd2\xfc\x13\x2f\x38\xc9\x80\x32\x9f\x81\x25\x4a\x84\xac\x79\x2d\x4a\xe4\x97\x60\xd1\xfc\xf1\x04\x73\x05\x39\x1b\x1a\xab\xaf\xf5\xed\x02\xd6\xd6\x15\xfd\x9c\x53\xa9\xbe\xa8\xbc\xf4\x9e\xbe\xf6\x97\x2f\x2e\xd8\xb3\x90\x7c\x22\x3b\xbf\xde\x88\x1f\xb5\xc7\xfd\x86\xf7\xdb\x87\xeb\x9b\x96\x8d\x45\x9e\x25\x13\xf0\x46\x6b\xa5\xd2\x11\xe3\xa3\x42\x4f\x0b\x1d\xc6\x7d\x02\x8f\x88\x90\xc5\x93\x2a\x33\xf4\x96\xd1\x0f\xc2\x77\xb1\x35\xcc\xb8\x09\x71\xec\xc1\xba\x93\x54\x39\xb7\xb5\xae\x29\xe6\x9a\x00\xe6\xae\x9a\x69\xcf\x1d\xd7\xfb\x78\x92\x5b\x1e\x8e\x5e\xd5\x6f\x7d\x1d\x17\x16\x4a\x5d\xe0\x57\xf4\xf3\xb4\xf7\xac\x95\x4a\x4d\x0c\xcf\x93\xc4\xe1\xeb\xb5\x44\xff\xe9\xd5\xae\x83\x07\x17\xbc\x85\x83\x82\x69\x5b\xe6\xc8\x54\x70\x49\xdb\x52\xe7\xac\x3a\x1e\x68\x26\x51\xe5\xf6\xfa\x2d\xef\xb7\xf3\x7b\xfd\xb6\xd8\xb1\xde\xa8\x75\x64\xea\x0b\xc3\x2f\x75\xbd\x4c\xff\x1b\xbe\x97\xa9\xeb\xfc\x9b\x1d\x7f\x4f\x1e\xde\x29\xba\xf9\xa2\xaa\x2d\x8f\xfa\x1d\xa7\x3b\xfa\x4b\x2a\x23\x2f\xc4\xdb\x00\xfb\x48\xd3\x34\x6f\xc8\x3d\x6d\x15\xd7\x6b\x1b\xb2\x9b\x12\x5d\x1f\xd5\x32\xeb\x66\xc7\xbb\x2d\x1b\xc2\x19\xce\x4b\x22\x57\x2d\x49\xe5\x5c\xeb\xba\x78\x0c\x82\x9b\xd3\x69\x65\x0f\xd1\x86\xe3\xdd\xf5\x87\x1f\x5f\x8f\x5f\xda\xb2\x55\x63\x84\x36\xa9\xd8\x3a\x72\x1b\x27\xe3\x0c\xff\x93\xbe\x9e\x91\xe5\xf5\x6a\xa8\xc4\xbb\xeb\x0f\xe6\xf6\x37\x38\x91\x4a\x2e\xa6\x1b\xb6\xa1\xb8\x2b\x27\xea\x3f\x86\x75\x16\xa2\x4b\xc9\x4a\xf8\x75\x7b\x51\xda\x97\x40\xfa\xe2\xf4\xbe\xd1\xb9\x50\x89\x19\x8d\x40\xad\x99\x84\x88\xe4\x92\xca\xf2\x2f\xa0\x41\x09\xb3\x0a\x01\xb5\xa6\x80\x50\xa5\x22\x9b\x54\x56\x7c\x92\xaa\x77\x5c\xd1\xec\x81\x24\x75\xe5\xdd\x4b\x8c\x43\xa0\xf7\xf3\xdf\x8f\x8f\x57\xdc\x87\x61\xef\x10\x4c\x7b\xff\x1e\x00\x0b\xfb\x32\xee\x84\x2d\x00\x00"
 
 func assetsClientStaticJsNgrokJsBytes() ([]byte, error) {
 	return bindataRead(
@@ -298,7 +298,7 @@ func assetsClientStaticJsNgrokJs() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "assets/client/static/js/ngrok.js", size: 11600, mode: os.FileMode(436), modTime: time.Unix(1452132613, 0)}
+	info := bindataFileInfo{name: "assets/client/static/js/ngrok.js", size: 11652, mode: os.FileMode(436), modTime: time.Unix(1792349259, 0)}
 	a := &asset{bytes: bytes, info:  info}
 	return a, nil
 }
@@ -16,6 +16,7 @@ Examples:
 	ngrok 80
 	ngrok -subdomain=example 8080
 	ngrok -proto=tcp 22
+	ngrok -subdomain=docs file:///srv/docs
 	ngrok -hostname="example.com" -httpauth="user:password" 10.0.0.1
 
 
@@ -34,16 +35,19 @@ Examples:
 `
 
 type Options struct {
-	config    string
-	logto     string
-	loglevel  string
-	authtoken string
-	httpauth  string
-	hostname  string
-	protocol  string
-	subdomain string
-	command   string
-	args      []string
+	config     string
+	logto      string
+	loglevel   string
+	authtoken  string
+	httpauth   string
+	hostname   string
+	hostHeader string
+	output     string
+	outputHttp bool
+	protocol   string
+	subdomain  string
+	command    string
+	args       []string
 }
 
 func ParseArgs() (opts *Options, err error) {
@@ -88,6 +92,21 @@ func ParseArgs() (opts *Options, err error) {
 		"",
 		"Request a custom hostname from the ngrok server. (HTTP only) (requires CNAME of your DNS)")
 
+	hostHeader := flag.String(
+		"host-header",
+		"",
+		"Host header to send requests to the local server with: 'preserve', 'rewrite' to the local address, or a hostname (HTTP only)")
+
+	output := flag.String(
+		"output",
+		"term",
+		"How to show the tunnels: 'term' for the terminal interface, or 'json' for one JSON event per line on stdout")
+
+	outputHttp := flag.Bool(
+		"output-http",
+		false,
+		"With -output=json, write an event for each HTTP request as well")
+
 	protocol := flag.String(
 		"proto",
 		"http+https",
@@ -96,15 +115,31 @@ func ParseArgs() (opts *Options, err error) {
 	flag.Parse()
 
 	opts = &Options{
-		config:    *config,
-		logto:     *logto,
-		loglevel:  *loglevel,
-		httpauth:  *httpauth,
-		subdomain: *subdomain,
-		protocol:  *protocol,
-		authtoken: *authtoken,
-		hostname:  *hostname,
-		command:   flag.Arg(0),
+		config:     *config,
+		logto:      *logto,
+		loglevel:   *loglevel,
+		httpauth:   *httpauth,
+		subdomain:  *subdomain,
+		protocol:   *protocol,
+		authtoken:  *authtoken,
+		hostname:   *hostname,
+		hostHeader: *hostHeader,
+		output:     *output,
+		outputHttp: *outputHttp,
+		command:    flag.Arg(0),
+	}
+
+	switch opts.output {
+	case "term":
+	case "json":
+		// stdout is for the events
+		if opts.logto == "stdout" {
+			err = fmt.Errorf("-output=json writes to stdout, log to a file with -log instead")
+			return
+		}
+	default:
+		err = fmt.Errorf("Invalid -output: %s, must be 'term' or 'json'", opts.output)
+		return
 	}
 
 	switch opts.command {
@@ -1,11 +1,13 @@
 package client
 
 import (
+	"crypto/tls"
 	"fmt"
 	"gopkg.in/yaml.v1"
 	"io/ioutil"
 	"net"
 	"net/url"
+	"ngrok/errorpage"
 	"ngrok/log"
 	"os"
 	"os/user"
@@ -13,26 +15,112 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// local addresses with this scheme are dialed over TLS
+const upstreamTlsScheme = "https://"
+
 type Configuration struct {
 	HttpProxy          string                          `yaml:"http_proxy,omitempty"`
-	ServerAddr         string                          `yaml:"server_addr,omitempty"`
+	ServerAddr         ServerAddrs                     `yaml:"server_addr,omitempty"`
+	ServerPolicy       string                          `yaml:"server_policy,omitempty"`
 	InspectAddr        string                          `yaml:"inspect_addr,omitempty"`
+	ApiToken           string                          `yaml:"api_token,omitempty"`
 	TrustHostRootCerts bool                            `yaml:"trust_host_root_certs,omitempty"`
 	AuthToken          string                          `yaml:"auth_token,omitempty"`
 	Password           string                          `yaml:"password"`
 	Tunnels            map[string]*TunnelConfiguration `yaml:"tunnels,omitempty"`
+	Reconnect          *ReconnectConfiguration         `yaml:"reconnect,omitempty"`
+	Heartbeat          *HeartbeatConfiguration         `yaml:"heartbeat,omitempty"`
 	LogTo              string                          `yaml:"-"`
+	Output             string                          `yaml:"-"`
+	OutputHttp         bool                            `yaml:"-"`
 	Path               string                          `yaml:"-"`
+
+	// the options it was loaded with, to load it again with
+	opts *Options
+}
+
+// The address of the server, or a list of servers to fail over between
+type ServerAddrs []string
+
+func (a *ServerAddrs) SetYAML(tag string, value interface{}) bool {
+	switch v := value.(type) {
+	case []interface{}:
+		addrs := make(ServerAddrs, 0, len(v))
+		for _, addr := range v {
+			if addr == nil {
+				return false
+			}
+			addrs = append(addrs, fmt.Sprint(addr))
+		}
+		*a = addrs
+	case nil:
+		*a = nil
+	case map[interface{}]interface{}:
+		return false
+	default:
+		*a = ServerAddrs{fmt.Sprint(v)}
+	}
+	return true
+}
+
+func (a ServerAddrs) GetYAML() (tag string, value interface{}) {
+	if len(a) == 1 {
+		return "", a[0]
+	}
+	return "", []string(a)
 }
 
 type TunnelConfiguration struct {
-	Subdomain  string            `yaml:"subdomain,omitempty"`
-	Hostname   string            `yaml:"hostname,omitempty"`
-	Protocols  map[string]string `yaml:"proto,omitempty"`
-	HttpAuth   string            `yaml:"auth,omitempty"`
-	RemotePort uint16            `yaml:"remote_port,omitempty"`
+	Subdomain   string            `yaml:"subdomain,omitempty"`
+	Hostname    string            `yaml:"hostname,omitempty"`
+	Protocols   map[string]string `yaml:"proto,omitempty"`
+	HttpAuth    string            `yaml:"auth,omitempty"`
+	HttpAuths   []string          `yaml:"auths,omitempty"`
+	RemotePort  uint16            `yaml:"remote_port,omitempty"`
+	Balance     string            `yaml:"balance,omitempty"`
+	Priority    int               `yaml:"priority,omitempty"`
+	PathPrefix  string            `yaml:"path_prefix,omitempty"`
+	StripPrefix bool              `yaml:"strip_prefix,omitempty"`
+	ErrorPages  map[string]string `yaml:"error_pages,omitempty"`
+
+	// for local services at an https:// address: the name their certificate
+	// is verified against, whether to skip verification, and a CA file to
+	// verify it with instead of the host's root certificates
+	UpstreamServerName string `yaml:"upstream_server_name,omitempty"`
+	UpstreamInsecure   bool   `yaml:"upstream_insecure,omitempty"`
+	UpstreamCa         string `yaml:"upstream_ca,omitempty"`
+
+	// for a file:// address: list directories even if they have an index.html
+	NoIndex bool `yaml:"no_index,omitempty"`
+
+	// the Host header requests are sent to the local service with: preserve
+	// (the default), rewrite to the local address, or a literal host
+	HostHeader string `yaml:"host_header,omitempty"`
+
+	// require visitors to log in with the server's OpenID Connect provider,
+	// and let in only these emails and domains if any are given
+	Oidc        bool     `yaml:"oidc,omitempty"`
+	OidcEmails  []string `yaml:"oidc_emails,omitempty"`
+	OidcDomains []string `yaml:"oidc_domains,omitempty"`
+
+	// changes to the headers of requests before they reach the local service,
+	// and of its responses
+	RequestHeaders  *HeaderRules `yaml:"request_headers,omitempty"`
+	ResponseHeaders *HeaderRules `yaml:"response_headers,omitempty"`
+
+	// the templates of ErrorPages, read when the configuration is loaded
+	errorPageSources map[string]string
+	errorPages       *errorpage.Pages
+
+	// built when a local address is https://
+	upstreamTls *tls.Config
+
+	// serve file:// addresses, by address
+	fileServersMu sync.Mutex
+	fileServers   map[string]*fileServer
 }
 
 func LoadConfiguration(opts *Options) (config *Configuration, err error) {
@@ -69,8 +157,8 @@ func LoadConfiguration(opts *Options) (config *Configuration, err error) {
 	}
 
 	// set configuration defaults
-	if config.ServerAddr == "" {
-		config.ServerAddr = defaultServerAddr
+	if len(config.ServerAddr) == 0 {
+		config.ServerAddr = ServerAddrs{defaultServerAddr}
 	}
 
 	if config.InspectAddr == "" {
@@ -81,6 +169,14 @@ func LoadConfiguration(opts *Options) (config *Configuration, err error) {
 		config.HttpProxy = os.Getenv("http_proxy")
 	}
 
+	if config.Reconnect == nil {
+		config.Reconnect = new(ReconnectConfiguration)
+	}
+
+	if config.Heartbeat == nil {
+		config.Heartbeat = new(HeartbeatConfiguration)
+	}
+
 	// validate and normalize configuration
 	if config.InspectAddr != "disabled" {
 		if config.InspectAddr, err = normalizeAddress(config.InspectAddr, "inspect_addr"); err != nil {
@@ -88,7 +184,24 @@ func LoadConfiguration(opts *Options) (config *Configuration, err error) {
 		}
 	}
 
-	if config.ServerAddr, err = normalizeAddress(config.ServerAddr, "server_addr"); err != nil {
+	for i, addr := range config.ServerAddr {
+		if config.ServerAddr[i], err = normalizeAddress(addr, "server_addr"); err != nil {
+			return
+		}
+	}
+
+	switch config.ServerPolicy {
+	case "", "failover", "latency":
+	default:
+		err = fmt.Errorf("Invalid server_policy: %s, must be 'failover' or 'latency'", config.ServerPolicy)
+		return
+	}
+
+	if err = config.Reconnect.normalize(); err != nil {
+		return
+	}
+
+	if err = config.Heartbeat.normalize(); err != nil {
 		return
 	}
 
@@ -105,37 +218,17 @@ func LoadConfiguration(opts *Options) (config *Configuration, err error) {
 	}
 
 	for name, t := range config.Tunnels {
-		if t == nil || t.Protocols == nil || len(t.Protocols) == 0 {
-			err = fmt.Errorf("Tunnel %s does not specify any protocols to tunnel.", name)
+		if err = t.normalize(name); err != nil {
 			return
 		}
-
-		for k, addr := range t.Protocols {
-			tunnelName := fmt.Sprintf("for tunnel %s[%s]", name, k)
-			if t.Protocols[k], err = normalizeAddress(addr, tunnelName); err != nil {
-				return
-			}
-
-			if err = validateProtocol(k, tunnelName); err != nil {
-				return
-			}
-		}
-
-		// use the name of the tunnel as the subdomain if none is specified
-		if t.Hostname == "" && t.Subdomain == "" {
-			// XXX: a crude heuristic, really we should be checking if the last part
-			// is a TLD
-			if len(strings.Split(name, ".")) > 1 {
-				t.Hostname = name
-			} else {
-				t.Subdomain = name
-			}
-		}
 	}
 
 	// override configuration with command-line options
 	config.LogTo = opts.logto
+	config.Output = opts.output
+	config.OutputHttp = opts.outputHttp
 	config.Path = configPath
+	config.opts = opts
 	if opts.authtoken != "" {
 		config.AuthToken = opts.authtoken
 	}
@@ -145,10 +238,11 @@ func LoadConfiguration(opts *Options) (config *Configuration, err error) {
 	case "default":
 		config.Tunnels = make(map[string]*TunnelConfiguration)
 		config.Tunnels["default"] = &TunnelConfiguration{
-			Subdomain: opts.subdomain,
-			Hostname:  opts.hostname,
-			HttpAuth:  opts.httpauth,
-			Protocols: make(map[string]string),
+			Subdomain:  opts.subdomain,
+			Hostname:   opts.hostname,
+			HttpAuth:   opts.httpauth,
+			HostHeader: opts.hostHeader,
+			Protocols:  make(map[string]string),
 		}
 
 		for _, proto := range strings.Split(opts.protocol, "+") {
@@ -156,7 +250,7 @@ func LoadConfiguration(opts *Options) (config *Configuration, err error) {
 				return
 			}
 
-			if config.Tunnels["default"].Protocols[proto], err = normalizeAddress(opts.args[0], ""); err != nil {
+			if config.Tunnels["default"].Protocols[proto], err = config.Tunnels["default"].normalizeLocalAddress(proto, opts.args[0], ""); err != nil {
 				return
 			}
 		}
@@ -199,6 +293,86 @@ func LoadConfiguration(opts *Options) (config *Configuration, err error) {
 	return
 }
 
+// Validate and normalize the configuration of the tunnel called name
+func (t *TunnelConfiguration) normalize(name string) (err error) {
+	if t == nil || t.Protocols == nil || len(t.Protocols) == 0 {
+		err = fmt.Errorf("Tunnel %s does not specify any protocols to tunnel.", name)
+		return
+	}
+
+	for k, addr := range t.Protocols {
+		tunnelName := fmt.Sprintf("for tunnel %s[%s]", name, k)
+		if t.Protocols[k], err = t.normalizeLocalAddress(k, addr, tunnelName); err != nil {
+			return
+		}
+
+		if err = validateProtocol(k, tunnelName); err != nil {
+			return
+		}
+	}
+
+	switch t.Balance {
+	case "", "roundrobin", "leastconn", "failover":
+	default:
+		err = fmt.Errorf("Invalid balance mode for tunnel %s: %s", name, t.Balance)
+		return
+	}
+
+	if t.HostHeader != "" && t.Protocols["tcp"] != "" {
+		err = fmt.Errorf("Tunnel %s can't rewrite the host_header of tcp", name)
+		return
+	}
+
+	if (t.Oidc || len(t.OidcEmails)+len(t.OidcDomains) > 0) && t.Protocols["tcp"] != "" {
+		err = fmt.Errorf("Tunnel %s can't require a login for tcp", name)
+		return
+	}
+
+	// an allow list only makes sense behind the login
+	if len(t.OidcEmails)+len(t.OidcDomains) > 0 {
+		t.Oidc = true
+	}
+
+	if (!t.RequestHeaders.empty() || !t.ResponseHeaders.empty()) && t.Protocols["tcp"] != "" {
+		err = fmt.Errorf("Tunnel %s can't rewrite the headers of tcp", name)
+		return
+	}
+
+	// servers that know a single credential get the first one
+	if t.HttpAuth == "" && len(t.HttpAuths) > 0 {
+		t.HttpAuth, t.HttpAuths = t.HttpAuths[0], t.HttpAuths[1:]
+	}
+
+	if t.PathPrefix != "" && t.Protocols["tcp"] != "" {
+		err = fmt.Errorf("Tunnel %s can't route tcp by path_prefix", name)
+		return
+	}
+
+	if len(t.ErrorPages) > 0 {
+		if t.errorPageSources, err = errorpage.ReadFiles(t.ErrorPages); err != nil {
+			err = fmt.Errorf("Failed to read error pages for tunnel %s: %v", name, err)
+			return
+		}
+
+		if t.errorPages, err = errorpage.Parse(t.errorPageSources, nil); err != nil {
+			err = fmt.Errorf("Invalid error pages for tunnel %s: %v", name, err)
+			return
+		}
+	}
+
+	// use the name of the tunnel as the subdomain if none is specified
+	if t.Hostname == "" && t.Subdomain == "" {
+		// XXX: a crude heuristic, really we should be checking if the last part
+		// is a TLD
+		if len(strings.Split(name, ".")) > 1 {
+			t.Hostname = name
+		} else {
+			t.Subdomain = name
+		}
+	}
+	return
+}
+
 func defaultPath() string {
 	user, err := user.Current()
 
@@ -232,6 +406,52 @@ func normalizeAddress(addr string, propName string) (string, error) {
 	return fmt.Sprintf("%s:%s", host, port), nil
 }
 
+// Normalize the local address a tunnel forwards proto to. The client speaks
+// TLS to local services at an https:// address, and serves the directory
+// of a file:// address itself.
+func (t *TunnelConfiguration) normalizeLocalAddress(proto, addr, propName string) (string, error) {
+	var scheme string
+	switch {
+	case strings.HasPrefix(addr, upstreamTlsScheme):
+		scheme = upstreamTlsScheme
+	case strings.HasPrefix(addr, fileScheme):
+		scheme = fileScheme
+	default:
+		return normalizeAddress(addr, propName)
+	}
+
+	if proto == "tcp" {
+		return "", fmt.Errorf("Invalid address %s '%s': only http tunnels can have a %s address", propName, addr, scheme)
+	}
+
+	if scheme == fileScheme {
+		return normalizeFileAddress(addr, propName)
+	}
+
+	addr, err := normalizeTlsAddress(strings.TrimSuffix(strings.TrimPrefix(addr, upstreamTlsScheme), "/"), propName)
+	if err != nil {
+		return "", err
+	}
+
+	if t.upstreamTls == nil {
+		if t.upstreamTls, err = LoadUpstreamTLSConfig(t); err != nil {
+			return "", fmt.Errorf("Failed to configure TLS to the local service %s: %v", propName, err)
+		}
+	}
+
+	return upstreamTlsScheme + addr, nil
+}
+
+// Same as normalizeAddress, but the port defaults to 443
+func normalizeTlsAddress(addr string, propName string) (string, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		if _, err := strconv.Atoi(addr); err != nil {
+			addr = net.JoinHostPort(addr, "443")
+		}
+	}
+	return normalizeAddress(addr, propName)
+}
+
 func validateProtocol(proto, propName string) (err error) {
 	switch proto {
 	case "http", "https", "http+https", "tcp":
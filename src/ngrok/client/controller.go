@@ -3,11 +3,13 @@ package client
 import (
 	"fmt"
 	"ngrok/client/mvc"
+	"ngrok/client/views/events"
 	"ngrok/client/views/term"
 	"ngrok/client/views/web"
 	"ngrok/log"
 	"ngrok/proto"
 	"ngrok/util"
+	"os"
 	"sync"
 )
 
@@ -16,6 +18,9 @@ type command interface{}
 type cmdQuit struct {
 	// display this message after quit
 	message string
+
+	// exit with this status
+	status int
 }
 
 type cmdPlayRequest struct {
@@ -79,6 +84,10 @@ func (ctl *Controller) Shutdown(message string) {
 	ctl.cmds <- cmdQuit{message: message}
 }
 
+func (ctl *Controller) Fail(message string) {
+	ctl.cmds <- cmdQuit{message: message, status: 1}
+}
+
 func (ctl *Controller) PlayRequest(tunnel mvc.Tunnel, payload []byte) {
 	ctl.cmds <- cmdPlayRequest{tunnel: tunnel, payload: payload}
 }
@@ -140,7 +149,8 @@ func (ctl *Controller) GetModel() *ClientModel {
 	return ctl.model.(*ClientModel)
 }
 
-func (ctl *Controller) Run(config *Configuration) {
+// Run the client until it's told to quit, and return the status to exit with
+func (ctl *Controller) Run(config *Configuration) int {
 	// Save the configuration
 	ctl.config = config
 
@@ -162,13 +172,18 @@ func (ctl *Controller) Run(config *Configuration) {
 		ctl.AddView(webView)
 	}
 
-	// init term ui
+	// init term ui, or the events for scripts in its place
 	var termView *term.TermView
-	if config.LogTo != "stdout" {
+	var eventView *events.EventView
+	if config.Output == "json" {
+		eventView = events.NewEventView(ctl, os.Stdout)
+		ctl.AddView(eventView)
+	} else if config.LogTo != "stdout" {
 		termView = term.NewTermView(ctl)
 		ctl.AddView(termView)
 	}
 
+	var webHttpView *web.WebHttpView
 	for _, protocol := range model.GetProtocols() {
 		switch p := protocol.(type) {
 		case *proto.Http:
@@ -177,12 +192,22 @@ func (ctl *Controller) Run(config *Configuration) {
 			}
 
 			if webView != nil {
-				ctl.AddView(webView.NewHttpView(p))
+				webHttpView = webView.NewHttpView(p)
+				ctl.AddView(webHttpView)
+			}
+
+			if eventView != nil && config.OutputHttp {
+				ctl.AddView(eventView.NewHttpView(p))
 			}
 		default:
 		}
 	}
 
+	// the api is served along with the web interface, to those with its token
+	if webView != nil && config.ApiToken != "" {
+		ctl.serveApi(webHttpView)
+	}
+
 	ctl.Go(func() { autoUpdate(state, config.AuthToken) })
 	ctl.Go(ctl.model.Run)
 
@@ -190,15 +215,21 @@ func (ctl *Controller) Run(config *Configuration) {
 	defer ctl.updates.UnReg(updates)
 
 	done := make(chan int)
+	status := 0
 	for {
 		select {
 		case obj := <-ctl.cmds:
 			switch cmd := obj.(type) {
 			case cmdQuit:
 				msg := cmd.message
+				status = cmd.status
 				go func() {
 					ctl.doShutdown()
-					fmt.Println(msg)
+					if eventView != nil {
+						eventView.Quit(msg)
+					} else {
+						fmt.Println(msg)
+					}
 					done <- 1
 				}()
 
@@ -211,7 +242,7 @@ func (ctl *Controller) Run(config *Configuration) {
 
 		case ctl.state <- state:
 		case <-done:
-			return
+			return status
 		}
 	}
 }
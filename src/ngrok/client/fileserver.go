@@ -0,0 +1,150 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"ngrok/conn"
+	"ngrok/log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// local addresses with this scheme are directories the client serves itself
+const fileScheme = "file://"
+
+// Serves the files of a directory to the connections handed to it, as if
+// it were the local web server of a tunnel
+type fileServer struct {
+	log.Logger
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+	addr   fileAddr
+}
+
+func newFileServer(dir string, noIndex bool) *fileServer {
+	s := &fileServer{
+		Logger: log.NewPrefixLogger("files"),
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+		addr:   fileAddr(dir),
+	}
+
+	var fs http.FileSystem = http.Dir(dir)
+	if noIndex {
+		fs = noIndexFileSystem{fs}
+	}
+
+	s.Info("Serving files from %s", dir)
+	go (&http.Server{Handler: http.FileServer(fs)}).Serve(s)
+	return s
+}
+
+// Returns a connection to the file server
+func (s *fileServer) Dial() (conn.Conn, error) {
+	local, remote := conn.Pipe("prv")
+	select {
+	case s.conns <- remote:
+		return local, nil
+	case <-s.closed:
+		local.Close()
+		return nil, fmt.Errorf("File server for %s is closed", s.addr)
+	}
+}
+
+// net.Listener interface, for http.Server
+func (s *fileServer) Accept() (net.Conn, error) {
+	select {
+	case c := <-s.conns:
+		return c, nil
+	case <-s.closed:
+		return nil, fmt.Errorf("File server for %s is closed", s.addr)
+	}
+}
+
+func (s *fileServer) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+func (s *fileServer) Addr() net.Addr {
+	return s.addr
+}
+
+type fileAddr string
+
+func (a fileAddr) Network() string { return "file" }
+func (a fileAddr) String() string  { return string(a) }
+
+// Hides index.html files so that directories are always listed
+type noIndexFileSystem struct {
+	http.FileSystem
+}
+
+func (fs noIndexFileSystem) Open(name string) (http.File, error) {
+	if path.Base(name) == "index.html" {
+		return nil, os.ErrNotExist
+	}
+	return fs.FileSystem.Open(name)
+}
+
+// Normalize a file:// address to the absolute path of a directory
+func normalizeFileAddress(addr string, propName string) (string, error) {
+	dir := strings.TrimPrefix(addr, fileScheme)
+
+	// file:///C:/dir on windows
+	if len(dir) > 2 && dir[0] == '/' && dir[2] == ':' {
+		dir = dir[1:]
+	}
+
+	if dir == "" {
+		return "", fmt.Errorf("Invalid address %s '%s': no directory", propName, addr)
+	}
+
+	dir, err := filepath.Abs(filepath.FromSlash(dir))
+	if err != nil {
+		return "", fmt.Errorf("Invalid address %s '%s': %v", propName, addr, err)
+	}
+
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("Invalid address %s '%s': %v", propName, addr, err)
+	} else if !fi.IsDir() {
+		return "", fmt.Errorf("Invalid address %s '%s': not a directory", propName, addr)
+	}
+
+	return fileScheme + filepath.ToSlash(dir), nil
+}
+
+// The file server of a file:// address of the tunnel, started the first
+// time it's asked for
+func (t *TunnelConfiguration) fileServer(addr string) *fileServer {
+	t.fileServersMu.Lock()
+	defer t.fileServersMu.Unlock()
+
+	if t.fileServers == nil {
+		t.fileServers = make(map[string]*fileServer)
+	}
+
+	s, ok := t.fileServers[addr]
+	if !ok {
+		s = newFileServer(filepath.FromSlash(strings.TrimPrefix(addr, fileScheme)), t.NoIndex)
+		t.fileServers[addr] = s
+	}
+	return s
+}
+
+// Stop the file servers of the tunnel, once a reload has closed it
+func (t *TunnelConfiguration) closeFileServers() {
+	t.fileServersMu.Lock()
+	defer t.fileServersMu.Unlock()
+
+	for _, s := range t.fileServers {
+		s.Close()
+	}
+	t.fileServers = nil
+}
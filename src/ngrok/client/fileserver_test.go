@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A directory with a page, an index and a subdirectory without an index
+func fileTree(t *testing.T) string {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.txt":      "hello",
+		"index.html": "<p>index</p>",
+		"sub/b.txt":  "world",
+		"sub/c.json": "{}",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// Get path from s as the tunnel would, returning the status and body
+func getFile(t *testing.T, s *fileServer, path string) (int, string) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return s.Dial()
+			},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get("http://files.test" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestFileServer(t *testing.T) {
+	s := newFileServer(fileTree(t), false)
+	defer s.Close()
+
+	cases := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/a.txt", 200, "hello"},
+		{"/", 200, "<p>index</p>"},
+		{"/sub/b.txt", 200, "world"},
+		{"/missing.txt", 404, ""},
+		{"/../a.txt", 200, "hello"},
+	}
+	for _, c := range cases {
+		status, body := getFile(t, s, c.path)
+		if status != c.status || (c.body != "" && body != c.body) {
+			t.Errorf("%s: got %d %q, want %d %q", c.path, status, body, c.status, c.body)
+		}
+	}
+
+	// a directory without an index is listed
+	status, body := getFile(t, s, "/sub/")
+	if status != 200 || !strings.Contains(body, `<a href="b.txt">`) || !strings.Contains(body, `<a href="c.json">`) {
+		t.Errorf("/sub/: got %d %q, want a listing", status, body)
+	}
+}
+
+func TestFileServerNoIndex(t *testing.T) {
+	s := newFileServer(fileTree(t), true)
+	defer s.Close()
+
+	status, body := getFile(t, s, "/")
+	if status != 200 || strings.Contains(body, "<p>index</p>") || !strings.Contains(body, `<a href="a.txt">`) {
+		t.Errorf("/: got %d %q, want a listing", status, body)
+	}
+
+	if status, body := getFile(t, s, "/a.txt"); status != 200 || body != "hello" {
+		t.Errorf("/a.txt: got %d %q", status, body)
+	}
+}
+
+func TestFileServerClose(t *testing.T) {
+	s := newFileServer(t.TempDir(), false)
+	s.Close()
+	s.Close()
+
+	if _, err := s.Dial(); err == nil {
+		t.Error("dialed a closed file server")
+	}
+}
+
+func TestTunnelFileServers(t *testing.T) {
+	dir := fileTree(t)
+	config := &TunnelConfiguration{NoIndex: true}
+
+	addr := fileScheme + filepath.ToSlash(dir)
+	s := config.fileServer(addr)
+	if config.fileServer(addr) != s {
+		t.Error("the same address got a second file server")
+	}
+	if status, body := getFile(t, s, "/"); status != 200 || strings.Contains(body, "<p>index</p>") {
+		t.Errorf("the tunnel's no_index was ignored, got %d %q", status, body)
+	}
+
+	config.closeFileServers()
+	if _, err := s.Dial(); err == nil {
+		t.Error("a file server outlived its tunnel")
+	}
+}
+
+func TestNormalizeFileAddress(t *testing.T) {
+	dir := fileTree(t)
+
+	got, err := normalizeFileAddress(fileScheme+filepath.ToSlash(dir), "addr")
+	if err != nil || got != fileScheme+filepath.ToSlash(dir) {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = normalizeFileAddress(fileScheme+".", "addr")
+	if err != nil || got != fileScheme+filepath.ToSlash(wd) {
+		t.Errorf("a relative directory was normalized to %q, %v", got, err)
+	}
+
+	for _, bad := range []string{
+		fileScheme,
+		fileScheme + filepath.ToSlash(filepath.Join(dir, "a.txt")),
+		fileScheme + filepath.ToSlash(filepath.Join(dir, "missing")),
+	} {
+		if got, err := normalizeFileAddress(bad, "addr"); err == nil {
+			t.Errorf("%s was accepted as %s", bad, got)
+		}
+	}
+}
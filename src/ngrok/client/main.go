@@ -48,5 +48,5 @@ func Main() {
 	}
 	rand.Seed(seed)
 
-	NewController().Run(config)
+	os.Exit(NewController().Run(config))
 }
@@ -1,12 +1,14 @@
 package client
 
 import (
+	"bufio"
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
-	"math"
+	"net/http"
 	"ngrok/client/mvc"
 	"ngrok/conn"
+	"ngrok/errorpage"
 	"ngrok/log"
 	"ngrok/msg"
 	"ngrok/proto"
@@ -14,6 +16,7 @@ import (
 	"ngrok/version"
 	"runtime"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -22,15 +25,13 @@ import (
 
 const (
 	defaultServerAddr   = "ngrokd.ngrok.com:443"
-	pingInterval        = 20 * time.Second
-	maxPongLatency      = 15 * time.Second
 	updateCheckInterval = 6 * time.Hour
-	BadGateway          = `<html>
-<body style="background-color: #97a8b9">
-    <div style="margin:auto; width:400px;padding: 20px 60px; background-color: #D3D3D3; border: 5px solid maroon;">
-        <h2>Tunnel %s unavailable</h2>
-        <p>Unable to initiate connection to <strong>%s</strong>. A web server must be running on port <strong>%s</strong> to complete the tunnel.</p>
-`
+
+	// how long to wait for the request a 502 answers, to read its Accept header
+	badGatewayReadTimeout = 2 * time.Second
+
+	// how long the TLS handshake with an https:// local service may take
+	upstreamHandshakeTimeout = 10 * time.Second
 )
 
 type ClientModel struct {
@@ -38,6 +39,7 @@ type ClientModel struct {
 
 	id            string
 	tunnels       map[string]mvc.Tunnel
+	urlConfig     map[string]*TunnelConfiguration
 	serverVersion string
 	metrics       *ClientMetrics
 	updateStatus  mvc.UpdateStatus
@@ -45,13 +47,47 @@ type ClientModel struct {
 	protoMap      map[string]proto.Protocol
 	protocols     []proto.Protocol
 	ctl           mvc.Controller
+	serverAddrs   []string
+	serverPolicy  string
+	nextServer    int
 	serverAddr    string
 	proxyUrl      string
 	authToken     string
 	password      string
+	rootTls       *tls.Config
 	tlsConfig     *tls.Config
 	tunnelConfig  map[string]*TunnelConfiguration
 	configPath    string
+	opts          *Options
+	reconnect     *ReconnectConfiguration
+	heartbeats    *HeartbeatConfiguration
+
+	// guards the tunnels and their configuration, which a reload or the api
+	// changes while the control connection runs
+	mu         *sync.Mutex
+	ctlConn    conn.Conn
+	requests   map[string]*tunnelRequest
+	apiTunnels map[string]bool
+
+	// keeps the messages written to the control connection whole. They're
+	// written after releasing mu, so that a server slow to read them doesn't
+	// hold up the model.
+	writeMu *sync.Mutex
+
+	// why the server refused the tunnels it did, by name
+	tunnelErrors map[string]string
+}
+
+// A tunnel asked for over the control connection
+type tunnelRequest struct {
+	name   string
+	config *TunnelConfiguration
+
+	// asked for while running, failing closes only this tunnel
+	added bool
+
+	// gets the server's answers if anybody waits for them
+	opened chan *msg.NewTunnel
 }
 
 func newClientModel(config *Configuration, ctl mvc.Controller) *ClientModel {
@@ -64,8 +100,9 @@ func newClientModel(config *Configuration, ctl mvc.Controller) *ClientModel {
 	m := &ClientModel{
 		Logger: log.NewPrefixLogger("client"),
 
-		// server address
-		serverAddr: config.ServerAddr,
+		// server addresses, and how to pick one
+		serverAddrs:  config.ServerAddr,
+		serverPolicy: config.ServerPolicy,
 
 		// proxy address
 		proxyUrl: config.HttpProxy,
@@ -93,6 +130,9 @@ func newClientModel(config *Configuration, ctl mvc.Controller) *ClientModel {
 		// open tunnels
 		tunnels: make(map[string]mvc.Tunnel),
 
+		// configuration of the open tunnels by public url
+		urlConfig: make(map[string]*TunnelConfiguration),
+
 		// controller
 		ctl: ctl,
 
@@ -101,22 +141,46 @@ func newClientModel(config *Configuration, ctl mvc.Controller) *ClientModel {
 
 		// config path
 		configPath: config.Path,
+
+		// how to reconnect, and to tell a dead connection
+		reconnect:  config.Reconnect,
+		heartbeats: config.Heartbeat,
+
+		// to reload the configuration with
+		opts: config.opts,
+
+		mu:      new(sync.Mutex),
+		writeMu: new(sync.Mutex),
+
+		// tunnels started with the api, which reloads keep
+		apiTunnels: make(map[string]bool),
+
+		tunnelErrors: make(map[string]string),
+	}
+
+	// a client sharing a tunnel or a host with other clients of the same user needs
+	// an id of its own, otherwise the server treats each as a reconnect of the others
+	for _, t := range config.Tunnels {
+		if t.Balance != "" || t.PathPrefix != "" {
+			m.id = util.SecureRandIdOrPanic(16)
+			break
+		}
 	}
 
 	// configure TLS
 	if config.TrustHostRootCerts {
 		m.Info("Trusting host's root certificates")
-		m.tlsConfig = &tls.Config{}
+		m.rootTls = &tls.Config{}
 	} else {
 		m.Info("Trusting root CAs: %v", rootCrtPaths)
 		var err error
-		if m.tlsConfig, err = LoadTLSConfig(rootCrtPaths); err != nil {
+		if m.rootTls, err = LoadTLSConfig(rootCrtPaths); err != nil {
 			panic(err)
 		}
 	}
 
-	// configure TLS SNI
-	m.tlsConfig.ServerName = serverName(m.serverAddr)
+	// the first server until Run picks one
+	m.useServer(m.serverAddrs[0])
 
 	return m
 }
@@ -125,13 +189,30 @@ func newClientModel(config *Configuration, ctl mvc.Controller) *ClientModel {
 func (c ClientModel) GetProtocols() []proto.Protocol { return c.protocols }
 func (c ClientModel) GetClientVersion() string       { return version.MajorMinor() }
 func (c ClientModel) GetServerVersion() string       { return c.serverVersion }
+func (c ClientModel) GetServerAddr() string {
+	addr, _ := c.server()
+	return addr
+}
 func (c ClientModel) GetTunnels() []mvc.Tunnel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	tunnels := make([]mvc.Tunnel, 0)
 	for _, t := range c.tunnels {
 		tunnels = append(tunnels, t)
 	}
 	return tunnels
 }
+func (c ClientModel) GetTunnelErrors() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errors := make(map[string]string)
+	for name, err := range c.tunnelErrors {
+		errors[name] = err
+	}
+	return errors
+}
 func (c ClientModel) GetConnStatus() mvc.ConnStatus     { return c.connStatus }
 func (c ClientModel) GetUpdateStatus() mvc.UpdateStatus { return c.updateStatus }
 
@@ -153,8 +234,7 @@ func (c ClientModel) SetUpdateStatus(updateStatus mvc.UpdateStatus) {
 
 // mvc.Model interface
 func (c *ClientModel) PlayRequest(tunnel mvc.Tunnel, payload []byte) {
-	var localConn conn.Conn
-	localConn, err := conn.Dial(tunnel.LocalAddr, "prv", nil)
+	localConn, err := c.dialLocal(tunnel)
 	if err != nil {
 		c.Warn("Failed to open private leg to %s: %v", tunnel.LocalAddr, err)
 		return
@@ -173,32 +253,73 @@ func (c *ClientModel) update() {
 	c.ctl.Update(c)
 }
 
+// Shut down because of an error the client can't recover from
+func (c *ClientModel) fail(message string) {
+	if c.reconnect.ExitOnFailure {
+		c.ctl.Fail(message)
+	} else {
+		c.ctl.Shutdown(message)
+	}
+}
+
 func (c *ClientModel) Run() {
-	// how long we should wait before we reconnect
-	maxWait := 30 * time.Second
-	wait := 1 * time.Second
+	// failed attempts to connect since the last connection
+	retries := 0
+
+	// tunnels change without a restart when the configuration does
+	c.ctl.Go(c.watchConfig)
 
 	for {
-		// run the control channel
-		c.control()
+		// try the servers in turn until one of them works
+		online := false
+		for _, addr := range c.serverOrder() {
+			c.useServer(addr)
+			c.update()
+
+			// run the control channel
+			shutdown := c.control()
+
+			// a server that shuts down asks its clients to reconnect, most likely
+			// because it's restarting, so it stays first in line. Otherwise control
+			// only returns when a failure has occurred, so we're going to try to
+			// reconnect starting with the next server
+			if !shutdown {
+				c.failedServer(addr)
+			}
+			if shutdown || c.connStatus == mvc.ConnOnline {
+				online = true
+				break
+			}
 
-		// control only returns when a failure has occurred, so we're going to try to reconnect
-		if c.connStatus == mvc.ConnOnline {
-			wait = 1 * time.Second
+			if len(c.serverAddrs) > 1 {
+				c.Warn("Failed to connect to server %s", addr)
+			}
+		}
+
+		if online {
+			retries = 0
+		} else if c.reconnect.MaxRetries > 0 && retries >= c.reconnect.MaxRetries {
+			emsg := fmt.Sprintf("Failed to connect to the server, giving up after %d retries", retries)
+			c.Error(emsg)
+			c.fail(emsg)
+			return
 		}
 
-		log.Info("Waiting %d seconds before reconnecting", int(wait.Seconds()))
+		// wait exponentially longer after each failure
+		wait := c.reconnect.backoff(retries)
+		if !online {
+			retries++
+		}
+		log.Info("Waiting %v before reconnecting", wait-wait%time.Millisecond)
 		time.Sleep(wait)
-		// exponentially increase wait time
-		wait = 2 * wait
-		wait = time.Duration(math.Min(float64(wait), float64(maxWait)))
 		c.connStatus = mvc.ConnReconnecting
 		c.update()
 	}
 }
 
-// Establishes and manages a tunnel control connection with the server
-func (c *ClientModel) control() {
+// Establishes and manages a tunnel control connection with the server,
+// returns true if the server closed it because it's shutting down
+func (c *ClientModel) control() (shutdown bool) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Error("control recovering from failure %v", r)
@@ -244,7 +365,7 @@ func (c *ClientModel) control() {
 
 	if authResp.Error != "" {
 		emsg := fmt.Sprintf("Failed to authenticate to server: %s", authResp.Error)
-		c.ctl.Shutdown(emsg)
+		c.fail(emsg)
 		return
 	}
 
@@ -256,34 +377,30 @@ func (c *ClientModel) control() {
 		c.Error("Failed to save auth token: %v", err)
 	}
 
-	// request tunnels
-	reqIdToTunnelConfig := make(map[string]*TunnelConfiguration)
-	for _, config := range c.tunnelConfig {
-		// create the protocol list to ask for
-		var protocols []string
-		for proto, _ := range config.Protocols {
-			protocols = append(protocols, proto)
-		}
-
-		reqTunnel := &msg.ReqTunnel{
-			ReqId:      util.RandId(8),
-			Protocol:   strings.Join(protocols, "+"),
-			Hostname:   config.Hostname,
-			Subdomain:  config.Subdomain,
-			HttpAuth:   config.HttpAuth,
-			RemotePort: config.RemotePort,
-		}
-
-		// send the tunnel request
-		if err = msg.WriteMsg(ctlConn, reqTunnel); err != nil {
-			panic(err)
-		}
+	// request tunnels, the ones of a previous connection are gone with it
+	c.mu.Lock()
+	c.ctlConn = ctlConn
+	c.tunnels = make(map[string]mvc.Tunnel)
+	c.urlConfig = make(map[string]*TunnelConfiguration)
+	c.requests = make(map[string]*tunnelRequest)
+	var reqs []msg.Message
+	for name, config := range c.tunnelConfig {
+		_, reqTunnel := c.requestTunnel(name, config, false)
+		reqs = append(reqs, reqTunnel)
+	}
+	c.mu.Unlock()
 
-		// save request id association so we know which local address
-		// to proxy to later
-		reqIdToTunnelConfig[reqTunnel.ReqId] = config
+	if err = c.send(ctlConn, reqs...); err != nil {
+		panic(err)
 	}
 
+	// reloads can't use the connection once it's gone
+	defer func() {
+		c.mu.Lock()
+		c.ctlConn = nil
+		c.mu.Unlock()
+	}()
+
 	// start the heartbeat
 	lastPong := time.Now().UnixNano()
 	c.ctl.Go(func() { c.heartbeat(&lastPong, ctlConn) })
@@ -302,21 +419,67 @@ func (c *ClientModel) control() {
 		case *msg.Pong:
 			atomic.StoreInt64(&lastPong, time.Now().UnixNano())
 
+		case *msg.ServerShutdown:
+			// in flight proxy connections carry on, Run reconnects the control
+			c.Warn("Server is going away (%s), reconnecting", m.Reason)
+			return true
+
 		case *msg.NewTunnel:
+			c.mu.Lock()
+			req := c.requests[m.ReqId]
+			if req != nil && req.opened != nil {
+				select {
+				case req.opened <- m:
+				default:
+				}
+			}
+
 			if m.Error != "" {
+				if req != nil {
+					c.tunnelErrors[req.name] = m.Error
+				}
+
+				// a tunnel added while running is given up on, not the others with it
+				if req != nil && req.added {
+					if c.tunnelConfig[req.name] == req.config {
+						delete(c.tunnelConfig, req.name)
+						delete(c.apiTunnels, req.name)
+					}
+					c.mu.Unlock()
+					c.Error("Server failed to allocate tunnel %s: %s", req.name, m.Error)
+					c.update()
+					continue
+				}
+				c.mu.Unlock()
+				c.update()
+
 				emsg := fmt.Sprintf("Server failed to allocate tunnel: %s", m.Error)
 				c.Error(emsg)
-				c.ctl.Shutdown(emsg)
+				c.fail(emsg)
+				continue
+			}
+
+			// a reload removed the tunnel before the server opened it
+			if req == nil || !c.configured(req.config) {
+				c.mu.Unlock()
+				if err = c.send(ctlConn, &msg.CloseTunnel{Url: m.Url}); err != nil {
+					panic(err)
+				}
 				continue
 			}
 
 			tunnel := mvc.Tunnel{
+				Name:      req.name,
 				PublicUrl: m.Url,
-				LocalAddr: reqIdToTunnelConfig[m.ReqId].Protocols[m.Protocol],
+				LocalAddr: req.config.Protocols[m.Protocol],
 				Protocol:  c.protoMap[m.Protocol],
 			}
 
 			c.tunnels[tunnel.PublicUrl] = tunnel
+			c.urlConfig[tunnel.PublicUrl] = req.config
+			delete(c.tunnelErrors, req.name)
+			c.mu.Unlock()
+
 			c.connStatus = mvc.ConnOnline
 			c.Info("Tunnel established at %v", tunnel.PublicUrl)
 			c.update()
@@ -327,6 +490,73 @@ func (c *ClientModel) control() {
 	}
 }
 
+// Register a request for a tunnel and return the message asking the server
+// for it, to send once c.mu is released. c.mu must be held. added is true
+// for a tunnel asked for while running.
+func (c *ClientModel) requestTunnel(name string, config *TunnelConfiguration, added bool) (*tunnelRequest, *msg.ReqTunnel) {
+	// create the protocol list to ask for
+	var protocols []string
+	for proto, _ := range config.Protocols {
+		protocols = append(protocols, proto)
+	}
+
+	reqTunnel := &msg.ReqTunnel{
+		ReqId:       util.RandId(8),
+		Protocol:    strings.Join(protocols, "+"),
+		Hostname:    config.Hostname,
+		Subdomain:   config.Subdomain,
+		HttpAuth:    config.HttpAuth,
+		HttpAuths:   config.HttpAuths,
+		OidcAuth:    config.Oidc,
+		OidcEmails:  config.OidcEmails,
+		OidcDomains: config.OidcDomains,
+		RemotePort:  config.RemotePort,
+		Balance:     config.Balance,
+		Priority:    config.Priority,
+		PathPrefix:  config.PathPrefix,
+		StripPrefix: config.StripPrefix,
+		ErrorPages:  config.errorPageSources,
+	}
+
+	// save request id association so we know which local address
+	// to proxy to later
+	req := &tunnelRequest{name: name, config: config, added: added}
+	c.requests[reqTunnel.ReqId] = req
+	return req, reqTunnel
+}
+
+// Write messages to the control connection, c.mu must not be held
+func (c *ClientModel) send(ctlConn conn.Conn, msgs ...msg.Message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	for _, m := range msgs {
+		if err := msg.WriteMsg(ctlConn, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns true if config is the configuration of one of the tunnels to run,
+// c.mu must be held
+func (c *ClientModel) configured(config *TunnelConfiguration) bool {
+	for _, t := range c.tunnelConfig {
+		if t == config {
+			return true
+		}
+	}
+	return false
+}
+
+// The open tunnel at a public url and its configuration
+func (c *ClientModel) tunnel(url string) (mvc.Tunnel, *TunnelConfiguration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tunnel, ok := c.tunnels[url]
+	return tunnel, c.urlConfig[url], ok
+}
+
 // Establishes and manages a tunnel proxy connection with the server
 func (c *ClientModel) proxy() {
 	var (
@@ -334,10 +564,12 @@ func (c *ClientModel) proxy() {
 		err        error
 	)
 
+	// proxy connections go to the server of the control connection
+	serverAddr, tlsConfig := c.server()
 	if c.proxyUrl == "" {
-		remoteConn, err = conn.Dial(c.serverAddr, "pxy", c.tlsConfig)
+		remoteConn, err = conn.Dial(serverAddr, "pxy", tlsConfig)
 	} else {
-		remoteConn, err = conn.DialHttpProxy(c.proxyUrl, c.serverAddr, "pxy", c.tlsConfig)
+		remoteConn, err = conn.DialHttpProxy(c.proxyUrl, serverAddr, "pxy", tlsConfig)
 	}
 
 	if err != nil {
@@ -359,7 +591,7 @@ func (c *ClientModel) proxy() {
 		return
 	}
 
-	tunnel, ok := c.tunnels[startPxy.Url]
+	tunnel, config, ok := c.tunnel(startPxy.Url)
 	if !ok {
 		remoteConn.Error("Couldn't find tunnel for proxy: %s", startPxy.Url)
 		return
@@ -367,18 +599,13 @@ func (c *ClientModel) proxy() {
 
 	// start up the private connection
 	start := time.Now()
-	localConn, err := conn.Dial(tunnel.LocalAddr, "prv", nil)
+	localConn, err := c.dialLocal(tunnel)
 	if err != nil {
 		remoteConn.Warn("Failed to open private leg %s: %v", tunnel.LocalAddr, err)
 
 		if tunnel.Protocol.GetName() == "http" {
 			// try to be helpful when you're in HTTP mode and a human might see the output
-			badGatewayBody := fmt.Sprintf(BadGateway, tunnel.PublicUrl, tunnel.LocalAddr, tunnel.LocalAddr)
-			remoteConn.Write([]byte(fmt.Sprintf(`HTTP/1.0 502 Bad Gateway
-Content-Type: text/html
-Content-Length: %d
-
-%s`, len(badGatewayBody), badGatewayBody)))
+			remoteConn.Write(c.badGateway(remoteConn, tunnel))
 		}
 		return
 	}
@@ -388,8 +615,20 @@ Content-Length: %d
 	m.proxySetupTimer.Update(time.Since(start))
 	m.connMeter.Mark(1)
 	c.update()
+
+	// the tunnel may rewrite the Host and other headers of the traffic
+	var rw *httpRewrite
+	if config != nil && tunnel.Protocol.GetName() == "http" {
+		if rw = config.newHttpRewrite(tunnel.LocalAddr); rw != nil {
+			remoteConn = rw.requests(remoteConn)
+		}
+	}
+
 	m.connTimer.Time(func() {
 		localConn := tunnel.Protocol.WrapConn(localConn, mvc.ConnectionContext{Tunnel: tunnel, ClientAddr: startPxy.ClientAddr})
+		if rw != nil {
+			localConn = rw.responses(localConn)
+		}
 		bytesIn, bytesOut := conn.Join(localConn, remoteConn)
 		m.bytesIn.Update(bytesIn)
 		m.bytesOut.Update(bytesOut)
@@ -399,10 +638,72 @@ Content-Length: %d
 	c.update()
 }
 
+// Open a connection to the local service of a tunnel, over TLS if its
+// address is https://, or to the client's own file server if it's file://.
+// The TLS handshake is done before returning so that a service that can't
+// be reached that way gets a 502 like any other.
+func (c *ClientModel) dialLocal(tunnel mvc.Tunnel) (conn.Conn, error) {
+	_, config, _ := c.tunnel(tunnel.PublicUrl)
+	if strings.HasPrefix(tunnel.LocalAddr, fileScheme) && config != nil {
+		return config.fileServer(tunnel.LocalAddr).Dial()
+	}
+
+	if !strings.HasPrefix(tunnel.LocalAddr, upstreamTlsScheme) {
+		localConn, err := conn.Dial(tunnel.LocalAddr, "prv", nil)
+		if err != nil {
+			return nil, err
+		}
+		return localConn, nil
+	}
+
+	var tlsConfig *tls.Config
+	if config != nil {
+		tlsConfig = config.upstreamTls
+	}
+
+	addr := strings.TrimPrefix(tunnel.LocalAddr, upstreamTlsScheme)
+	localConn, err := conn.Dial(addr, "prv", upstreamTLSConfigFor(tlsConfig, addr))
+	if err != nil {
+		return nil, err
+	}
+
+	localConn.SetDeadline(time.Now().Add(upstreamHandshakeTimeout))
+	if err = localConn.Handshake(); err != nil {
+		localConn.Close()
+		return nil, fmt.Errorf("TLS handshake with %s failed: %v", addr, err)
+	}
+	localConn.SetDeadline(time.Time{})
+
+	return localConn, nil
+}
+
+// The response to a request the local server couldn't be reached for,
+// a page unless the request asks for JSON
+func (c *ClientModel) badGateway(remoteConn conn.Conn, tunnel mvc.Tunnel) []byte {
+	var accept string
+	remoteConn.SetReadDeadline(time.Now().Add(badGatewayReadTimeout))
+	if req, err := http.ReadRequest(bufio.NewReader(remoteConn)); err == nil {
+		accept = req.Header.Get("Accept")
+	}
+
+	pages := errorpage.Default()
+	if _, config, _ := c.tunnel(tunnel.PublicUrl); config != nil && config.errorPages != nil {
+		pages = config.errorPages
+	}
+
+	return pages.Render(&errorpage.Data{
+		Status:    http.StatusBadGateway,
+		Title:     fmt.Sprintf("Tunnel %s unavailable", tunnel.PublicUrl),
+		Message:   fmt.Sprintf("Unable to initiate connection to %s. A web server must be running on %s to complete the tunnel.", tunnel.LocalAddr, tunnel.LocalAddr),
+		Url:       tunnel.PublicUrl,
+		LocalAddr: tunnel.LocalAddr,
+	}, accept)
+}
+
 // Hearbeating to ensure our connection ngrokd is still live
 func (c *ClientModel) heartbeat(lastPongAddr *int64, conn conn.Conn) {
 	lastPing := time.Unix(atomic.LoadInt64(lastPongAddr)-1, 0)
-	ping := time.NewTicker(pingInterval)
+	ping := time.NewTicker(c.heartbeats.pingInterval)
 	pongCheck := time.NewTicker(time.Second)
 
 	defer func() {
@@ -418,15 +719,14 @@ func (c *ClientModel) heartbeat(lastPongAddr *int64, conn conn.Conn) {
 			needPong := lastPong.Sub(lastPing) < 0
 			pongLatency := time.Since(lastPing)
 
-			if needPong && pongLatency > maxPongLatency {
+			if needPong && pongLatency > c.heartbeats.maxPongLatency {
 				c.Info("Last ping: %v, Last pong: %v", lastPing, lastPong)
 				c.Info("Connection stale, haven't gotten PongMsg in %d seconds", int(pongLatency.Seconds()))
 				return
 			}
 
 		case <-ping.C:
-			err := msg.WriteMsg(conn, &msg.Ping{})
-			if err != nil {
+			if err := c.send(conn, &msg.Ping{}); err != nil {
 				conn.Debug("Got error %v when writing PingMsg", err)
 				return
 			}
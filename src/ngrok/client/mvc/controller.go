@@ -11,6 +11,9 @@ type Controller interface {
 	// instructs the controller to shut the app down
 	Shutdown(message string)
 
+	// like Shutdown, but the app exits with a non-zero status
+	Fail(message string)
+
 	// PlayRequest instructs the model to play requests
 	PlayRequest(tunnel Tunnel, payload []byte)
 
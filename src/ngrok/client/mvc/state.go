@@ -23,6 +23,7 @@ const (
 )
 
 type Tunnel struct {
+	Name      string
 	PublicUrl string
 	Protocol  proto.Protocol
 	LocalAddr string
@@ -36,7 +37,9 @@ type ConnectionContext struct {
 type State interface {
 	GetClientVersion() string
 	GetServerVersion() string
+	GetServerAddr() string
 	GetTunnels() []Tunnel
+	GetTunnelErrors() map[string]string
 	GetProtocols() []proto.Protocol
 	GetUpdateStatus() UpdateStatus
 	GetConnStatus() ConnStatus
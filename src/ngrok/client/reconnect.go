@@ -0,0 +1,112 @@
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultMinBackoff     = 1 * time.Second
+	defaultMaxBackoff     = 30 * time.Second
+	defaultPingInterval   = 20 * time.Second
+	defaultMaxPongLatency = 15 * time.Second
+)
+
+// How the client waits between attempts to reconnect to the server. The wait
+// doubles from MinBackoff up to MaxBackoff, and with "full" jitter, the
+// default, a random time up to it is waited instead so that the clients of a
+// restarted server don't all come back at once. After MaxRetries failed
+// attempts in a row the client gives up, never when it's 0.
+type ReconnectConfiguration struct {
+	MinBackoff string `yaml:"min_backoff,omitempty"`
+	MaxBackoff string `yaml:"max_backoff,omitempty"`
+	Jitter     string `yaml:"jitter,omitempty"`
+	MaxRetries int    `yaml:"max_retries,omitempty"`
+
+	// exit with a non-zero status when the client gives up, for a
+	// supervisor to restart it
+	ExitOnFailure bool `yaml:"exit_on_failure,omitempty"`
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// How often the client pings the server, and how long it waits for the
+// answer before it drops the connection as dead
+type HeartbeatConfiguration struct {
+	PingInterval   string `yaml:"ping_interval,omitempty"`
+	MaxPongLatency string `yaml:"max_pong_latency,omitempty"`
+
+	pingInterval   time.Duration
+	maxPongLatency time.Duration
+}
+
+// Validate the reconnect settings and fill in the defaults
+func (r *ReconnectConfiguration) normalize() (err error) {
+	if r.minBackoff, err = parseDuration(r.MinBackoff, defaultMinBackoff, "reconnect.min_backoff"); err != nil {
+		return
+	}
+
+	if r.maxBackoff, err = parseDuration(r.MaxBackoff, defaultMaxBackoff, "reconnect.max_backoff"); err != nil {
+		return
+	}
+
+	if r.maxBackoff < r.minBackoff {
+		err = fmt.Errorf("reconnect.max_backoff %v is shorter than reconnect.min_backoff %v", r.maxBackoff, r.minBackoff)
+		return
+	}
+
+	switch r.Jitter {
+	case "", "full", "none":
+	default:
+		err = fmt.Errorf("Invalid reconnect.jitter: %s, must be 'full' or 'none'", r.Jitter)
+		return
+	}
+
+	if r.MaxRetries < 0 {
+		err = fmt.Errorf("Invalid reconnect.max_retries: %d, must be 0 or more", r.MaxRetries)
+		return
+	}
+
+	return
+}
+
+// Validate the heartbeat settings and fill in the defaults
+func (h *HeartbeatConfiguration) normalize() (err error) {
+	if h.pingInterval, err = parseDuration(h.PingInterval, defaultPingInterval, "heartbeat.ping_interval"); err != nil {
+		return
+	}
+
+	h.maxPongLatency, err = parseDuration(h.MaxPongLatency, defaultMaxPongLatency, "heartbeat.max_pong_latency")
+	return
+}
+
+// Parse a positive duration like 500ms or 1m30s, or default to def when it's empty
+func parseDuration(value string, def time.Duration, propName string) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("Invalid %s: %s, must be a positive duration like 10s", propName, value)
+	}
+	return d, nil
+}
+
+// How long to wait before the next attempt after retries failed ones
+func (r *ReconnectConfiguration) backoff(retries int) time.Duration {
+	wait := r.minBackoff
+	for i := 0; i < retries && wait < r.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > r.maxBackoff {
+		wait = r.maxBackoff
+	}
+
+	if r.Jitter == "none" {
+		return wait
+	}
+	return time.Duration(rand.Int63n(int64(wait) + 1))
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackoffWithoutJitter(t *testing.T) {
+	r := &ReconnectConfiguration{MinBackoff: "1s", MaxBackoff: "10s", Jitter: "none"}
+	if err := r.normalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the wait doubles after each failure until it reaches the limit
+	want := []time.Duration{1, 2, 4, 8, 10, 10}
+	for retries, w := range want {
+		if got := r.backoff(retries); got != w*time.Second {
+			t.Errorf("after %d retries: got %v, want %v", retries, got, w*time.Second)
+		}
+	}
+
+	// nor does it overflow after many
+	if got := r.backoff(1000); got != 10*time.Second {
+		t.Errorf("after 1000 retries: got %v", got)
+	}
+}
+
+func TestBackoffWithJitter(t *testing.T) {
+	for _, jitter := range []string{"", "full"} {
+		r := &ReconnectConfiguration{MinBackoff: "1s", MaxBackoff: "4s", Jitter: jitter}
+		if err := r.normalize(); err != nil {
+			t.Fatal(err)
+		}
+
+		// a random time up to the wait, so the clients spread out
+		waits := make(map[time.Duration]bool)
+		for i := 0; i < 100; i++ {
+			got := r.backoff(3)
+			if got < 0 || got > 4*time.Second {
+				t.Fatalf("jitter %q: got %v, want at most 4s", jitter, got)
+			}
+			waits[got] = true
+		}
+		if len(waits) < 2 {
+			t.Errorf("jitter %q: every wait was the same", jitter)
+		}
+	}
+}
+
+func TestReconnectDefaults(t *testing.T) {
+	r := &ReconnectConfiguration{}
+	if err := r.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if r.minBackoff != defaultMinBackoff || r.maxBackoff != defaultMaxBackoff {
+		t.Errorf("got backoff from %v to %v", r.minBackoff, r.maxBackoff)
+	}
+
+	h := &HeartbeatConfiguration{}
+	if err := h.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if h.pingInterval != defaultPingInterval || h.maxPongLatency != defaultMaxPongLatency {
+		t.Errorf("got ping interval %v and pong latency %v", h.pingInterval, h.maxPongLatency)
+	}
+}
+
+func TestReconnectInvalid(t *testing.T) {
+	cases := []struct {
+		r    ReconnectConfiguration
+		prop string
+	}{
+		{ReconnectConfiguration{MinBackoff: "soon"}, "reconnect.min_backoff"},
+		{ReconnectConfiguration{MinBackoff: "0s"}, "reconnect.min_backoff"},
+		{ReconnectConfiguration{MaxBackoff: "-1s"}, "reconnect.max_backoff"},
+		{ReconnectConfiguration{MinBackoff: "10s", MaxBackoff: "5s"}, "reconnect.max_backoff"},
+		{ReconnectConfiguration{MaxBackoff: "500ms"}, "reconnect.max_backoff"},
+		{ReconnectConfiguration{Jitter: "half"}, "reconnect.jitter"},
+		{ReconnectConfiguration{MaxRetries: -1}, "reconnect.max_retries"},
+	}
+
+	for _, c := range cases {
+		err := c.r.normalize()
+		if err == nil || !strings.Contains(err.Error(), c.prop) {
+			t.Errorf("%+v: got error %v, want one about %s", c.r, err, c.prop)
+		}
+	}
+
+	for _, h := range []HeartbeatConfiguration{{PingInterval: "often"}, {MaxPongLatency: "0"}} {
+		if err := h.normalize(); err == nil {
+			t.Errorf("%+v was accepted", h)
+		}
+	}
+}
+
+func TestRunGivesUp(t *testing.T) {
+	attempts := make(chan string, 100)
+	c, stopped := runModel(t, &ReconnectConfiguration{MaxRetries: 2}, hangingUpServer(t, "server", attempts))
+
+	// without exit_on_failure the client shuts down cleanly
+	why := runUntilStopped(t, c, stopped)
+	if why != "shutdown: Failed to connect to the server, giving up after 2 retries" {
+		t.Errorf("the client stopped with %q", why)
+	}
+
+	// the first attempt isn't a retry
+	if got := tried(attempts); len(got) != 3 {
+		t.Errorf("the client tried %d times, want 3", len(got))
+	}
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"bytes"
+	"gopkg.in/yaml.v1"
+	"ngrok/conn"
+	"ngrok/msg"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// how often the configuration file is checked for changes
+const configWatchInterval = 2 * time.Second
+
+// Reloads the configuration when its file changes or on SIGHUP, and opens
+// and closes the tunnels that changed without touching the others
+func (c *ClientModel) watchConfig() {
+	if c.opts == nil {
+		return
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	modTime := configModTime(c.configPath)
+	for {
+		select {
+		case <-hup:
+			c.Info("Reloading configuration on SIGHUP")
+
+		case <-ticker.C:
+			if configModTime(c.configPath).Equal(modTime) {
+				continue
+			}
+			c.Info("Configuration file %s changed, reloading", c.configPath)
+		}
+
+		modTime = configModTime(c.configPath)
+		config, err := LoadConfiguration(c.opts)
+		if err != nil {
+			c.Error("Ignoring the changed configuration: %v", err)
+			continue
+		}
+		c.reload(config)
+	}
+}
+
+// The modification time of the configuration file, zero if there's none
+func configModTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// Switch to the tunnels of a newly loaded configuration
+func (c *ClientModel) reload(config *Configuration) {
+	if !reflect.DeepEqual([]string(config.ServerAddr), c.serverAddrs) || config.ServerPolicy != c.serverPolicy || config.HttpProxy != c.proxyUrl || config.AuthToken != c.authToken ||
+		!reflect.DeepEqual(config.Reconnect, c.reconnect) || !reflect.DeepEqual(config.Heartbeat, c.heartbeats) {
+		c.Warn("Only changes to tunnels apply without restarting ngrok")
+	}
+
+	c.mu.Lock()
+	if config.Tunnels == nil {
+		config.Tunnels = make(map[string]*TunnelConfiguration)
+	}
+
+	var closed, opened []string
+	var msgs []msg.Message
+	for name, old := range c.tunnelConfig {
+		// tunnels started with the api stay unless the file takes their name
+		if c.apiTunnels[name] {
+			if _, ok := config.Tunnels[name]; !ok {
+				config.Tunnels[name] = old
+				continue
+			}
+			delete(c.apiTunnels, name)
+		}
+
+		if t, ok := config.Tunnels[name]; !ok || !t.sameAs(old) {
+			msgs = append(msgs, c.closeTunnels(old)...)
+			closed = append(closed, name)
+		}
+	}
+
+	for name, t := range config.Tunnels {
+		// unchanged tunnels keep the configuration their urls point at
+		if old, ok := c.tunnelConfig[name]; ok && old.sameAs(t) {
+			config.Tunnels[name] = old
+		} else {
+			opened = append(opened, name)
+		}
+	}
+	c.tunnelConfig = config.Tunnels
+
+	if len(closed)+len(opened) == 0 {
+		c.mu.Unlock()
+		c.Info("No tunnels changed")
+		return
+	}
+	sort.Strings(closed)
+	sort.Strings(opened)
+	c.Info("Closing tunnels %v, opening tunnels %v", closed, opened)
+
+	// without a control connection the tunnels are requested on reconnect
+	ctlConn := c.ctlConn
+	if ctlConn != nil {
+		for _, name := range opened {
+			_, reqTunnel := c.requestTunnel(name, config.Tunnels[name], true)
+			msgs = append(msgs, reqTunnel)
+		}
+	}
+	c.mu.Unlock()
+
+	c.sendChanges(ctlConn, msgs)
+	c.update()
+}
+
+// Close the open tunnels of a configuration, c.mu must be held. Returns the
+// messages telling the server, to send once c.mu is released.
+func (c *ClientModel) closeTunnels(config *TunnelConfiguration) (msgs []msg.Message) {
+	for url, t := range c.urlConfig {
+		if t != config {
+			continue
+		}
+
+		msgs = append(msgs, &msg.CloseTunnel{Url: url})
+		delete(c.tunnels, url)
+		delete(c.urlConfig, url)
+		c.Info("Closed tunnel %s", url)
+	}
+	config.closeFileServers()
+	return
+}
+
+// Tell the server about tunnels opened or closed while running, if the
+// client is connected to it. A failure leaves it to the reconnect.
+func (c *ClientModel) sendChanges(ctlConn conn.Conn, msgs []msg.Message) {
+	if ctlConn == nil || len(msgs) == 0 {
+		return
+	}
+
+	if err := c.send(ctlConn, msgs...); err != nil {
+		c.Warn("Failed to update the server's tunnels: %v", err)
+	}
+}
+
+// Returns true if two tunnel configurations ask for the same tunnel
+func (t *TunnelConfiguration) sameAs(o *TunnelConfiguration) bool {
+	a, errA := yaml.Marshal(t)
+	b, errB := yaml.Marshal(o)
+	return errA == nil && errB == nil && bytes.Equal(a, b) &&
+		reflect.DeepEqual(t.errorPageSources, o.errorPageSources)
+}
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"net"
+	"ngrok/client/mvc"
+	"ngrok/conn"
+	"ngrok/log"
+	"ngrok/msg"
+	"ngrok/proto"
+	"ngrok/util"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A controller that ignores what the model tells it
+type nopController struct{}
+
+func (nopController) Update(mvc.State)               {}
+func (nopController) Shutdown(string)                {}
+func (nopController) Fail(string)                    {}
+func (nopController) PlayRequest(mvc.Tunnel, []byte) {}
+func (nopController) Updates() *util.Broadcast       { return nil }
+func (nopController) State() mvc.State               { return nil }
+func (nopController) Go(fn func())                   { go fn() }
+func (nopController) GetWebInspectAddr() string      { return "" }
+
+// A model connected to a server that only records the messages it gets
+func testModel(t *testing.T, tunnels map[string]*TunnelConfiguration) (*ClientModel, chan msg.Message) {
+	http := proto.NewHttp()
+	c := &ClientModel{
+		Logger:       log.NewPrefixLogger("client"),
+		tunnels:      make(map[string]mvc.Tunnel),
+		urlConfig:    make(map[string]*TunnelConfiguration),
+		protoMap:     map[string]proto.Protocol{"http": http, "https": http, "tcp": proto.NewTcp()},
+		ctl:          nopController{},
+		tunnelConfig: tunnels,
+		reconnect:    &ReconnectConfiguration{},
+		mu:           new(sync.Mutex),
+		writeMu:      new(sync.Mutex),
+		requests:     make(map[string]*tunnelRequest),
+		apiTunnels:   make(map[string]bool),
+		tunnelErrors: make(map[string]string),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ctlConn, err := conn.Dial(l.Addr().String(), "ctl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawServer, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := conn.Wrap(rawServer, "srv")
+	t.Cleanup(func() {
+		ctlConn.Close()
+		server.Close()
+	})
+	c.ctlConn = ctlConn
+
+	received := make(chan msg.Message, 100)
+	go func() {
+		for {
+			m, err := msg.ReadMsg(server)
+			if err != nil {
+				return
+			}
+			received <- m
+		}
+	}()
+	return c, received
+}
+
+// Open the tunnel of a configuration at url, as if the server had
+func openTunnel(c *ClientModel, name, url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	config := c.tunnelConfig[name]
+	c.tunnels[url] = mvc.Tunnel{Name: name, PublicUrl: url, LocalAddr: config.Protocols["http"]}
+	c.urlConfig[url] = config
+}
+
+// The next n messages the server gets, and no more
+func serverMessages(t *testing.T, received chan msg.Message, n int) []msg.Message {
+	var msgs []msg.Message
+	for len(msgs) < n {
+		select {
+		case m := <-received:
+			msgs = append(msgs, m)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the server got %d of %d messages", len(msgs), n)
+		}
+	}
+
+	select {
+	case m := <-received:
+		t.Errorf("the server got another message %#v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return msgs
+}
+
+func httpTunnel(addr string) *TunnelConfiguration {
+	return &TunnelConfiguration{Protocols: map[string]string{"http": addr}}
+}
+
+func TestReloadKeepsUnchangedTunnels(t *testing.T) {
+	c, received := testModel(t, map[string]*TunnelConfiguration{
+		"same":    httpTunnel("127.0.0.1:8000"),
+		"changed": httpTunnel("127.0.0.1:8001"),
+		"removed": httpTunnel("127.0.0.1:8002"),
+	})
+	same := c.tunnelConfig["same"]
+	openTunnel(c, "same", "http://same.ngrok.me")
+	openTunnel(c, "changed", "http://changed.ngrok.me")
+	openTunnel(c, "removed", "http://removed.ngrok.me")
+
+	c.reload(&Configuration{Tunnels: map[string]*TunnelConfiguration{
+		"same":    httpTunnel("127.0.0.1:8000"),
+		"changed": httpTunnel("127.0.0.1:9001"),
+		"added":   httpTunnel("127.0.0.1:8003"),
+	}})
+
+	closed := make(map[string]bool)
+	requested := make(map[string]bool)
+	for _, m := range serverMessages(t, received, 4) {
+		switch m := m.(type) {
+		case *msg.CloseTunnel:
+			closed[m.Url] = true
+		case *msg.ReqTunnel:
+			requested[c.requests[m.ReqId].name] = true
+		}
+	}
+	if len(closed) != 2 || !closed["http://changed.ngrok.me"] || !closed["http://removed.ngrok.me"] {
+		t.Errorf("closed %v", closed)
+	}
+	if len(requested) != 2 || !requested["changed"] || !requested["added"] {
+		t.Errorf("requested %v", requested)
+	}
+
+	// the unchanged tunnel keeps its url and the configuration it points at
+	if c.tunnelConfig["same"] != same || c.urlConfig["http://same.ngrok.me"] != same {
+		t.Error("the unchanged tunnel got a new configuration")
+	}
+	if _, ok := c.tunnels["http://same.ngrok.me"]; !ok || len(c.tunnels) != 1 {
+		t.Errorf("got tunnels %v", c.tunnels)
+	}
+}
+
+func TestReloadWithoutChanges(t *testing.T) {
+	c, received := testModel(t, map[string]*TunnelConfiguration{"a": httpTunnel("127.0.0.1:8000")})
+	openTunnel(c, "a", "http://a.ngrok.me")
+
+	c.reload(&Configuration{Tunnels: map[string]*TunnelConfiguration{"a": httpTunnel("127.0.0.1:8000")}})
+	serverMessages(t, received, 0)
+
+	// nor does a configuration without tunnels leave a nil map
+	c.reload(&Configuration{})
+	serverMessages(t, received, 1)
+	if c.tunnelConfig == nil || len(c.tunnelConfig) != 0 || len(c.tunnels) != 0 {
+		t.Errorf("got tunnels %v and configuration %v", c.tunnels, c.tunnelConfig)
+	}
+}
+
+func TestReloadKeepsApiTunnels(t *testing.T) {
+	c, received := testModel(t, map[string]*TunnelConfiguration{
+		"api":   httpTunnel("127.0.0.1:8000"),
+		"taken": httpTunnel("127.0.0.1:8001"),
+	})
+	api := c.tunnelConfig["api"]
+	c.apiTunnels["api"] = true
+	c.apiTunnels["taken"] = true
+	openTunnel(c, "api", "http://api.ngrok.me")
+	openTunnel(c, "taken", "http://taken.ngrok.me")
+
+	// the file doesn't have the first, and takes the name of the second
+	c.reload(&Configuration{Tunnels: map[string]*TunnelConfiguration{
+		"taken": httpTunnel("127.0.0.1:9001"),
+	}})
+	serverMessages(t, received, 2)
+
+	if c.tunnelConfig["api"] != api || !c.apiTunnels["api"] {
+		t.Error("a reload dropped a tunnel started with the api")
+	}
+	if _, ok := c.tunnels["http://api.ngrok.me"]; !ok {
+		t.Error("a reload closed a tunnel started with the api")
+	}
+	if c.apiTunnels["taken"] || c.tunnelConfig["taken"].Protocols["http"] != "127.0.0.1:9001" {
+		t.Error("the file didn't take over the api tunnel's name")
+	}
+
+	// it's the file's tunnel now, so the next reload without it closes it
+	openTunnel(c, "taken", "http://taken.ngrok.me")
+	c.reload(&Configuration{})
+	serverMessages(t, received, 1)
+	if _, ok := c.tunnelConfig["taken"]; ok {
+		t.Error("the file's tunnel outlived the file")
+	}
+	if _, ok := c.tunnelConfig["api"]; !ok {
+		t.Error("a second reload dropped a tunnel started with the api")
+	}
+}
+
+func TestReloadWhileDisconnected(t *testing.T) {
+	c, received := testModel(t, map[string]*TunnelConfiguration{"a": httpTunnel("127.0.0.1:8000")})
+	c.ctlConn = nil
+
+	c.reload(&Configuration{Tunnels: map[string]*TunnelConfiguration{"b": httpTunnel("127.0.0.1:8001")}})
+	serverMessages(t, received, 0)
+
+	// the tunnels are requested on reconnect
+	if _, ok := c.tunnelConfig["b"]; !ok || len(c.tunnelConfig) != 1 || len(c.requests) != 0 {
+		t.Errorf("got configuration %v and requests %v", c.tunnelConfig, c.requests)
+	}
+}
+
+func TestReloadClosesFileServers(t *testing.T) {
+	addr := fileScheme + "/srv/www"
+	c, received := testModel(t, map[string]*TunnelConfiguration{"files": httpTunnel(addr)})
+	s := c.tunnelConfig["files"].fileServer(addr)
+
+	c.reload(&Configuration{})
+	serverMessages(t, received, 0)
+	if _, err := s.Dial(); err == nil {
+		t.Error("the file server of a closed tunnel is still serving")
+	}
+}
+
+func TestSameAs(t *testing.T) {
+	base := func() *TunnelConfiguration {
+		return &TunnelConfiguration{
+			Protocols:      map[string]string{"http": "127.0.0.1:8000", "https": "127.0.0.1:8000"},
+			Subdomain:      "a",
+			HttpAuths:      []string{"u:p"},
+			RequestHeaders: &HeaderRules{Set: map[string]string{"X-A": "1"}},
+		}
+	}
+
+	if !base().sameAs(base()) {
+		t.Error("equal configurations differ")
+	}
+
+	// what a reload doesn't set doesn't count
+	served := base()
+	served.fileServer(fileScheme + "/srv/www")
+	served.upstreamTls = upstreamTLSConfigFor(nil, "a:443")
+	if !served.sameAs(base()) {
+		t.Error("a tunnel that is running differs from its configuration")
+	}
+
+	for name, change := range map[string]func(*TunnelConfiguration){
+		"address":     func(t *TunnelConfiguration) { t.Protocols["https"] = "127.0.0.1:8001" },
+		"protocol":    func(t *TunnelConfiguration) { delete(t.Protocols, "https") },
+		"subdomain":   func(t *TunnelConfiguration) { t.Subdomain = "b" },
+		"auths":       func(t *TunnelConfiguration) { t.HttpAuths = append(t.HttpAuths, "v:q") },
+		"headers":     func(t *TunnelConfiguration) { t.RequestHeaders.Set["X-A"] = "2" },
+		"host header": func(t *TunnelConfiguration) { t.HostHeader = "rewrite" },
+		"error pages": func(t *TunnelConfiguration) { t.errorPageSources = map[string]string{"502": "changed"} },
+	} {
+		changed := base()
+		change(changed)
+		if changed.sameAs(base()) || base().sameAs(changed) {
+			t.Errorf("a change of %s went unnoticed", name)
+		}
+	}
+}
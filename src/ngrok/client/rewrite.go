@@ -0,0 +1,403 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"ngrok/conn"
+	"strconv"
+	"strings"
+)
+
+// host_header values other than a literal Host
+const (
+	hostHeaderPreserve = "preserve"
+	hostHeaderRewrite  = "rewrite"
+)
+
+// Changes to the headers of requests or responses: Add appends a header,
+// Set replaces every header of its name and Remove drops them
+type HeaderRules struct {
+	Add    map[string]string `yaml:"add,omitempty"`
+	Set    map[string]string `yaml:"set,omitempty"`
+	Remove []string          `yaml:"remove,omitempty"`
+}
+
+func (r *HeaderRules) empty() bool {
+	return r == nil || len(r.Add)+len(r.Set)+len(r.Remove) == 0
+}
+
+// Apply the rules to the header lines of a head
+func (r *HeaderRules) apply(lines []string) []string {
+	if r.empty() {
+		return lines
+	}
+
+	drop := make(map[string]bool)
+	for _, name := range r.Remove {
+		drop[http.CanonicalHeaderKey(name)] = true
+	}
+	for name := range r.Set {
+		drop[http.CanonicalHeaderKey(name)] = true
+	}
+
+	var kept []string
+	for _, line := range lines {
+		if !drop[headerName(line)] {
+			kept = append(kept, line)
+		}
+	}
+
+	for name, value := range r.Set {
+		kept = append(kept, headerLine(name, value))
+	}
+	for name, value := range r.Add {
+		kept = append(kept, headerLine(name, value))
+	}
+	return kept
+}
+
+func headerLine(name, value string) string {
+	return http.CanonicalHeaderKey(name) + ": " + value + "\r\n"
+}
+
+func headerName(line string) string {
+	return http.CanonicalHeaderKey(strings.TrimSpace(strings.SplitN(line, ":", 2)[0]))
+}
+
+func headerValue(line string) string {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// The Host header requests to a tunnel are sent to its local service with,
+// or the empty string to send them as they came in
+func (t *TunnelConfiguration) localHost(localAddr string) string {
+	switch t.HostHeader {
+	case "", hostHeaderPreserve:
+		return ""
+
+	case hostHeaderRewrite:
+		if strings.HasPrefix(localAddr, fileScheme) {
+			return ""
+		}
+
+		// drop the default port of the scheme
+		defaultPort := "80"
+		if strings.HasPrefix(localAddr, upstreamTlsScheme) {
+			localAddr = strings.TrimPrefix(localAddr, upstreamTlsScheme)
+			defaultPort = "443"
+		}
+
+		host, port, err := net.SplitHostPort(localAddr)
+		if err != nil || port != defaultPort {
+			return localAddr
+		}
+
+		// an IPv6 host keeps its brackets
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+		return host
+
+	default:
+		return t.HostHeader
+	}
+}
+
+// How the client rewrites the HTTP traffic of one proxied connection
+type httpRewrite struct {
+	// Host header for requests, empty to keep theirs
+	host string
+
+	request  *HeaderRules
+	response *HeaderRules
+
+	// the methods of the requests in order, so that the responses to HEAD
+	// requests are known to have no body
+	methods chan string
+}
+
+// How to rewrite a connection to the local service at localAddr, nil if the
+// tunnel leaves its traffic alone
+func (t *TunnelConfiguration) newHttpRewrite(localAddr string) *httpRewrite {
+	rw := &httpRewrite{
+		host:     t.localHost(localAddr),
+		request:  t.RequestHeaders,
+		response: t.ResponseHeaders,
+	}
+
+	if rw.host == "" && rw.request.empty() && rw.response.empty() {
+		return nil
+	}
+
+	if !rw.response.empty() {
+		rw.methods = make(chan string, 16)
+	}
+	return rw
+}
+
+// A connection whose reads are rewritten
+type rewrittenConn struct {
+	conn.Conn
+	pr *io.PipeReader
+}
+
+func (c *rewrittenConn) Read(p []byte) (int, error) {
+	return c.pr.Read(p)
+}
+
+func (c *rewrittenConn) Close() error {
+	c.pr.Close()
+	return c.Conn.Close()
+}
+
+func rewriteReads(c conn.Conn, copyFn func(io.Writer, *bufio.Reader) error) conn.Conn {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(copyFn(pw, bufio.NewReader(c)))
+	}()
+	return &rewrittenConn{Conn: c, pr: pr}
+}
+
+// Rewrite the requests read from the public connection c. A request whose
+// Host is rewritten carries the original in the X-Forwarded-Host header.
+func (rw *httpRewrite) requests(c conn.Conn) conn.Conn {
+	return rewriteReads(c, func(w io.Writer, rd *bufio.Reader) error {
+		if rw.methods != nil {
+			defer close(rw.methods)
+		}
+		return rw.copyRequests(w, rd)
+	})
+}
+
+// Rewrite the responses read from the local connection c
+func (rw *httpRewrite) responses(c conn.Conn) conn.Conn {
+	if rw.methods == nil {
+		return c
+	}
+	return rewriteReads(c, rw.copyResponses)
+}
+
+// Reads the start line and header lines of a head
+func readHead(rd *bufio.Reader) (start string, lines []string, err error) {
+	if start, err = rd.ReadString('\n'); err != nil {
+		return
+	}
+
+	for {
+		var line string
+		if line, err = rd.ReadString('\n'); err != nil {
+			return
+		}
+
+		if line == "\r\n" || line == "\n" {
+			return
+		}
+		lines = append(lines, line)
+	}
+}
+
+func writeHead(w io.Writer, start string, lines []string) error {
+	_, err := io.WriteString(w, start+strings.Join(lines, "")+"\r\n")
+	return err
+}
+
+// How the body after a head is framed
+type bodyFraming struct {
+	contentLength int64
+	hasLength     bool
+	chunked       bool
+	upgrade       bool
+}
+
+func framingOf(lines []string) (f bodyFraming, err error) {
+	for _, line := range lines {
+		switch headerName(line) {
+		case "Content-Length":
+			if f.contentLength, err = strconv.ParseInt(headerValue(line), 10, 64); err != nil {
+				err = fmt.Errorf("Malformed Content-Length %q", headerValue(line))
+				return
+			}
+			f.hasLength = true
+		case "Transfer-Encoding":
+			f.chunked = strings.Contains(strings.ToLower(headerValue(line)), "chunked")
+		case "Upgrade":
+			f.upgrade = true
+		}
+	}
+	return
+}
+
+// Copies the requests of an HTTP/1.x stream, rewriting their heads and
+// passing bodies through as they are. Once a request upgrades the
+// connection, e.g. to a websocket, the rest of the stream is copied as is.
+func (rw *httpRewrite) copyRequests(w io.Writer, rd *bufio.Reader) error {
+	for {
+		requestLine, lines, err := readHead(rd)
+		if err != nil {
+			return err
+		}
+
+		framing, err := framingOf(lines)
+		if err != nil {
+			return err
+		}
+
+		if rw.host != "" {
+			lines = rewriteHostLines(lines, rw.host)
+		}
+		lines = rw.request.apply(lines)
+
+		method := strings.SplitN(requestLine, " ", 2)[0]
+		if rw.methods != nil {
+			rw.methods <- method
+		}
+
+		if err = writeHead(w, requestLine, lines); err != nil {
+			return err
+		}
+
+		switch {
+		// CONNECT tunnels whatever follows it
+		case framing.upgrade || method == "CONNECT":
+			_, err = io.Copy(w, rd)
+			return err
+
+		case framing.chunked:
+			if err = copyChunkedBody(w, rd); err != nil {
+				return err
+			}
+
+		case framing.contentLength > 0:
+			if _, err = io.CopyN(w, rd, framing.contentLength); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func rewriteHostLines(lines []string, host string) []string {
+	var rewritten []string
+	sawHost := false
+	for _, line := range lines {
+		switch headerName(line) {
+		case "Host":
+			rewritten = append(rewritten, headerLine("Host", host), headerLine("X-Forwarded-Host", headerValue(line)))
+			sawHost = true
+		case "X-Forwarded-Host":
+			// replaced by the Host the request came in with
+		default:
+			rewritten = append(rewritten, line)
+		}
+	}
+
+	if !sawHost {
+		rewritten = append(rewritten, headerLine("Host", host))
+	}
+	return rewritten
+}
+
+// Copies the responses of an HTTP/1.x stream, rewriting their heads
+func (rw *httpRewrite) copyResponses(w io.Writer, rd *bufio.Reader) error {
+	for {
+		statusLine, lines, err := readHead(rd)
+		if err != nil {
+			return err
+		}
+
+		// HTTP/1.1 200 OK
+		parts := strings.SplitN(statusLine, " ", 3)
+		if len(parts) < 2 {
+			return fmt.Errorf("Malformed status line %q", statusLine)
+		}
+		status, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return fmt.Errorf("Malformed status line %q", statusLine)
+		}
+
+		framing, err := framingOf(lines)
+		if err != nil {
+			return err
+		}
+
+		// an informational response comes before the final one of its request
+		informational := status >= 100 && status < 200 && status != http.StatusSwitchingProtocols
+		method := ""
+		if !informational {
+			method = <-rw.methods
+			lines = rw.response.apply(lines)
+		}
+
+		if err = writeHead(w, statusLine, lines); err != nil {
+			return err
+		}
+
+		switch {
+		case status == http.StatusSwitchingProtocols || (method == "CONNECT" && status/100 == 2):
+			_, err = io.Copy(w, rd)
+			return err
+
+		case informational || method == "HEAD" || status == http.StatusNoContent || status == http.StatusNotModified:
+
+		case framing.chunked:
+			if err = copyChunkedBody(w, rd); err != nil {
+				return err
+			}
+
+		case framing.hasLength:
+			if _, err = io.CopyN(w, rd, framing.contentLength); err != nil {
+				return err
+			}
+
+		default:
+			// the body runs until the connection closes
+			_, err = io.Copy(w, rd)
+			return err
+		}
+	}
+}
+
+// Copies a chunked body as it is, up to the end of its trailer
+func copyChunkedBody(w io.Writer, rd *bufio.Reader) error {
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(w, line); err != nil {
+			return err
+		}
+
+		sizeField := strings.TrimSpace(strings.SplitN(line, ";", 2)[0])
+		size, err := strconv.ParseInt(sizeField, 16, 64)
+		if err != nil {
+			return fmt.Errorf("Malformed chunk size %q", sizeField)
+		}
+
+		if size == 0 {
+			// trailer, up to the empty line
+			for {
+				if line, err = rd.ReadString('\n'); err != nil {
+					return err
+				}
+				if _, err = io.WriteString(w, line); err != nil {
+					return err
+				}
+				if line == "\r\n" || line == "\n" {
+					return nil
+				}
+			}
+		}
+
+		// the chunk and its CRLF
+		if _, err = io.CopyN(w, rd, size+2); err != nil {
+			return err
+		}
+	}
+}
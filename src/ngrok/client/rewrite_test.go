@@ -0,0 +1,247 @@
+package client
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"ngrok/conn"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestHeaderRules(t *testing.T) {
+	head := []string{
+		"Host: a.example.com\r\n",
+		"X-Debug: 1\r\n",
+		"Cookie: a=1\r\n",
+		"cookie: b=2\r\n",
+		"Accept: */*\r\n",
+	}
+
+	cases := []struct {
+		name  string
+		rules *HeaderRules
+		want  []string
+	}{
+		{"none", nil, head},
+		{"empty", &HeaderRules{}, head},
+		{
+			// names match whatever their case
+			"remove",
+			&HeaderRules{Remove: []string{"x-debug", "COOKIE"}},
+			[]string{"Host: a.example.com\r\n", "Accept: */*\r\n"},
+		},
+		{
+			"set replaces every header of its name",
+			&HeaderRules{Set: map[string]string{"cookie": "c=3"}},
+			[]string{"Host: a.example.com\r\n", "X-Debug: 1\r\n", "Accept: */*\r\n", "Cookie: c=3\r\n"},
+		},
+		{
+			"add keeps the others",
+			&HeaderRules{Add: map[string]string{"cookie": "c=3"}},
+			append(append([]string(nil), head...), "Cookie: c=3\r\n"),
+		},
+		{
+			// a header both removed and added is replaced
+			"remove then add",
+			&HeaderRules{Remove: []string{"Cookie"}, Add: map[string]string{"Cookie": "c=3"}},
+			[]string{"Host: a.example.com\r\n", "X-Debug: 1\r\n", "Accept: */*\r\n", "Cookie: c=3\r\n"},
+		},
+		{
+			"remove then set",
+			&HeaderRules{Remove: []string{"x-debug"}, Set: map[string]string{"X-Debug": "0"}},
+			[]string{"Host: a.example.com\r\n", "Cookie: a=1\r\n", "cookie: b=2\r\n", "Accept: */*\r\n", "X-Debug: 0\r\n"},
+		},
+		{
+			// set comes before add, so both values are sent
+			"set then add",
+			&HeaderRules{Set: map[string]string{"Accept": "text/html"}, Add: map[string]string{"accept": "application/json"}},
+			[]string{"Host: a.example.com\r\n", "X-Debug: 1\r\n", "Cookie: a=1\r\n", "cookie: b=2\r\n", "Accept: text/html\r\n", "Accept: application/json\r\n"},
+		},
+		{
+			"set a new header",
+			&HeaderRules{Set: map[string]string{"x-tunnel": "a"}},
+			append(append([]string(nil), head...), "X-Tunnel: a\r\n"),
+		},
+	}
+
+	for _, c := range cases {
+		lines := append([]string(nil), head...)
+		if got := c.rules.apply(lines); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestHeaderRulesAddSeveral(t *testing.T) {
+	rules := &HeaderRules{Add: map[string]string{"X-A": "1", "X-B": "2", "X-C": "3"}}
+	got := rules.apply([]string{"Host: a\r\n"})
+
+	// the added headers come after the others, in no given order
+	if len(got) != 4 || got[0] != "Host: a\r\n" {
+		t.Fatalf("got %q", got)
+	}
+	added := append([]string(nil), got[1:]...)
+	sort.Strings(added)
+	if want := []string{"X-A: 1\r\n", "X-B: 2\r\n", "X-C: 3\r\n"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added %q, want %q", added, want)
+	}
+}
+
+// The raw requests read from the other end of a connection written raw to
+// and rewritten by rw
+func rewrittenRequests(t *testing.T, rw *httpRewrite, raw string) string {
+	c, other := conn.Pipe("pub")
+	go func() {
+		other.Write([]byte(raw))
+		other.Close()
+	}()
+
+	got, _ := ioutil.ReadAll(rw.requests(c))
+	return string(got)
+}
+
+func TestRequestHeaderRulesOnEveryRequest(t *testing.T) {
+	config := &TunnelConfiguration{RequestHeaders: &HeaderRules{
+		Remove: []string{"Authorization"},
+		Set:    map[string]string{"X-Env": "dev"},
+	}}
+	rw := config.newHttpRewrite("127.0.0.1:8080")
+
+	got := rewrittenRequests(t, rw,
+		"POST /a HTTP/1.1\r\nHost: a\r\nAuthorization: x\r\nX-Env: prod\r\nContent-Length: 19\r\n\r\nAuthorization: body"+
+			"GET /b HTTP/1.1\r\nHost: a\r\nAuthorization: y\r\n\r\n")
+
+	// the body is left alone
+	want := "POST /a HTTP/1.1\r\nHost: a\r\nContent-Length: 19\r\nX-Env: dev\r\n\r\nAuthorization: body" +
+		"GET /b HTTP/1.1\r\nHost: a\r\nX-Env: dev\r\n\r\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	br := bufio.NewReader(strings.NewReader(got))
+	for i := 0; i < 2; i++ {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(req.Body)
+		if req.Header.Get("Authorization") != "" || req.Header.Get("X-Env") != "dev" {
+			t.Errorf("request %d has headers %v", i+1, req.Header)
+		}
+	}
+}
+
+func TestLocalHost(t *testing.T) {
+	cases := []struct {
+		hostHeader, localAddr string
+		want                  string
+	}{
+		{"", "127.0.0.1:8080", ""},
+		{"preserve", "127.0.0.1:8080", ""},
+		{"rewrite", "127.0.0.1:8080", "127.0.0.1:8080"},
+		{"rewrite", "localhost:80", "localhost"},
+		{"rewrite", "https://localhost:443", "localhost"},
+		{"rewrite", "https://localhost:8443", "localhost:8443"},
+		{"rewrite", "https://localhost:80", "localhost:80"},
+		{"rewrite", "[::1]:80", "[::1]"},
+		{"rewrite", "[::1]:8080", "[::1]:8080"},
+		{"rewrite", "file:///srv/www", ""},
+		{"app.test", "127.0.0.1:8080", "app.test"},
+		{"app.test:3000", "file:///srv/www", "app.test:3000"},
+	}
+
+	for _, c := range cases {
+		config := &TunnelConfiguration{HostHeader: c.hostHeader}
+		if got := config.localHost(c.localAddr); got != c.want {
+			t.Errorf("host_header %q for %s: got %q, want %q", c.hostHeader, c.localAddr, got, c.want)
+		}
+	}
+}
+
+func TestNewHttpRewrite(t *testing.T) {
+	if rw := (&TunnelConfiguration{}).newHttpRewrite("127.0.0.1:8080"); rw != nil {
+		t.Errorf("a tunnel without rewrites got %+v", rw)
+	}
+	if rw := (&TunnelConfiguration{HostHeader: "preserve", RequestHeaders: &HeaderRules{}}).newHttpRewrite("127.0.0.1:8080"); rw != nil {
+		t.Errorf("a tunnel preserving the host with empty rules got %+v", rw)
+	}
+
+	// responses are only followed when they're rewritten
+	rw := (&TunnelConfiguration{HostHeader: "rewrite"}).newHttpRewrite("127.0.0.1:8080")
+	if rw == nil || rw.host != "127.0.0.1:8080" || rw.methods != nil {
+		t.Errorf("got %+v for a rewritten host", rw)
+	}
+	rw = (&TunnelConfiguration{ResponseHeaders: &HeaderRules{Remove: []string{"Server"}}}).newHttpRewrite("127.0.0.1:8080")
+	if rw == nil || rw.host != "" || rw.methods == nil {
+		t.Errorf("got %+v for rewritten responses", rw)
+	}
+}
+
+func TestRewriteHost(t *testing.T) {
+	rw := (&TunnelConfiguration{HostHeader: "app.test"}).newHttpRewrite("127.0.0.1:8080")
+
+	got := rewrittenRequests(t, rw,
+		"GET / HTTP/1.1\r\nHost: a.ngrok.me\r\nX-Forwarded-Host: spoofed\r\n\r\n"+
+			"GET / HTTP/1.0\r\n\r\n")
+
+	want := "GET / HTTP/1.1\r\nHost: app.test\r\nX-Forwarded-Host: a.ngrok.me\r\n\r\n" +
+		"GET / HTTP/1.0\r\nHost: app.test\r\n\r\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// The raw responses read from the local end of a connection, rewritten by
+// rw after it rewrote the requests they answer
+func rewrittenResponses(t *testing.T, rw *httpRewrite, requests, responses string) string {
+	rewrittenRequests(t, rw, requests)
+
+	c, other := conn.Pipe("prv")
+	go func() {
+		other.Write([]byte(responses))
+		other.Close()
+	}()
+
+	got, _ := ioutil.ReadAll(rw.responses(c))
+	return string(got)
+}
+
+func TestRewriteResponsesToHead(t *testing.T) {
+	rw := (&TunnelConfiguration{ResponseHeaders: &HeaderRules{Set: map[string]string{"Server": "tunnel"}}}).newHttpRewrite("127.0.0.1:8080")
+
+	got := rewrittenResponses(t, rw,
+		"HEAD / HTTP/1.1\r\nHost: a\r\n\r\n"+
+			"GET / HTTP/1.1\r\nHost: a\r\n\r\n",
+		// the response to HEAD announces a body it doesn't have
+		"HTTP/1.1 200 OK\r\nServer: local\r\nContent-Length: 100\r\n\r\n"+
+			"HTTP/1.1 200 OK\r\nServer: local\r\nContent-Length: 2\r\n\r\nok")
+
+	want := "HTTP/1.1 200 OK\r\nContent-Length: 100\r\nServer: tunnel\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nContent-Length: 2\r\nServer: tunnel\r\n\r\nok"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRewriteResponsesInformational(t *testing.T) {
+	rw := (&TunnelConfiguration{ResponseHeaders: &HeaderRules{Add: map[string]string{"X-Tunnel": "a"}}}).newHttpRewrite("127.0.0.1:8080")
+
+	got := rewrittenResponses(t, rw,
+		"POST / HTTP/1.1\r\nHost: a\r\nExpect: 100-continue\r\nContent-Length: 2\r\n\r\nhi"+
+			"GET / HTTP/1.1\r\nHost: a\r\n\r\n",
+		"HTTP/1.1 100 Continue\r\n\r\n"+
+			"HTTP/1.1 204 No Content\r\n\r\n"+
+			"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nok\r\n0\r\n\r\n")
+
+	// the informational response is passed on as it is, and doesn't
+	// answer the request
+	want := "HTTP/1.1 100 Continue\r\n\r\n" +
+		"HTTP/1.1 204 No Content\r\nX-Tunnel: a\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nX-Tunnel: a\r\n\r\n2\r\nok\r\n0\r\n\r\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"sort"
+	"time"
+)
+
+// how long the latency probe of a server may take
+const serverProbeTimeout = 5 * time.Second
+
+// Switch to a server, for the control connection and the proxy connections
+// that follow it
+func (c *ClientModel) useServer(addr string) {
+	tlsConfig := c.rootTls.Clone()
+	tlsConfig.ServerName = serverName(addr)
+
+	c.mu.Lock()
+	c.serverAddr, c.tlsConfig = addr, tlsConfig
+	c.mu.Unlock()
+}
+
+// The server in use and the TLS configuration to connect to it with
+func (c *ClientModel) server() (string, *tls.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.serverAddr, c.tlsConfig
+}
+
+// The servers to try, in order. The failover policy starts with the server
+// after the last one that failed and goes around the list; the latency
+// policy starts with the one that answered fastest.
+func (c *ClientModel) serverOrder() []string {
+	if len(c.serverAddrs) == 1 {
+		return c.serverAddrs
+	}
+
+	if c.serverPolicy == "latency" {
+		if c.proxyUrl != "" {
+			c.Warn("Can't measure the latency of servers through a proxy, trying them in order")
+			return c.serverAddrs
+		}
+		return c.probeServers()
+	}
+
+	order := make([]string, 0, len(c.serverAddrs))
+	for i := range c.serverAddrs {
+		order = append(order, c.serverAddrs[(c.nextServer+i)%len(c.serverAddrs)])
+	}
+	return order
+}
+
+// Remember a server failed, or lost its connection, so that the failover
+// policy moves on to the next one
+func (c *ClientModel) failedServer(addr string) {
+	for i, a := range c.serverAddrs {
+		if a == addr {
+			c.nextServer = (i + 1) % len(c.serverAddrs)
+		}
+	}
+}
+
+// Order the servers by how long they take to accept a connection, the
+// servers that don't go last
+func (c *ClientModel) probeServers() []string {
+	latencies := make([]time.Duration, len(c.serverAddrs))
+	done := make(chan int)
+	for i, addr := range c.serverAddrs {
+		go func(i int, addr string) {
+			start := time.Now()
+			if conn, err := net.DialTimeout("tcp", addr, serverProbeTimeout); err != nil {
+				c.Info("Server %s is unreachable: %v", addr, err)
+				latencies[i] = -1
+			} else {
+				latencies[i] = time.Since(start)
+				conn.Close()
+				c.Info("Server %s answered in %v", addr, latencies[i])
+			}
+			done <- 1
+		}(i, addr)
+	}
+	for _ = range c.serverAddrs {
+		<-done
+	}
+
+	order := make([]int, len(c.serverAddrs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Stable(byLatency{order, latencies})
+
+	addrs := make([]string, 0, len(order))
+	for _, i := range order {
+		addrs = append(addrs, c.serverAddrs[i])
+	}
+	return addrs
+}
+
+type byLatency struct {
+	order     []int
+	latencies []time.Duration
+}
+
+func (b byLatency) Len() int      { return len(b.order) }
+func (b byLatency) Swap(i, j int) { b.order[i], b.order[j] = b.order[j], b.order[i] }
+func (b byLatency) Less(i, j int) bool {
+	li, lj := b.latencies[b.order[i]], b.latencies[b.order[j]]
+	if li < 0 || lj < 0 {
+		return lj < 0 && li >= 0
+	}
+	return li < lj
+}
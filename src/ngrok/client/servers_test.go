@@ -0,0 +1,244 @@
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"ngrok/conn"
+	"ngrok/log"
+	"ngrok/msg"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func serversModel(policy string, addrs ...string) *ClientModel {
+	return &ClientModel{
+		Logger:       log.NewPrefixLogger("client"),
+		serverAddrs:  addrs,
+		serverPolicy: policy,
+	}
+}
+
+func TestServerOrderFailover(t *testing.T) {
+	c := serversModel("failover", "a:4443", "b:4443", "c:4443")
+
+	if got, want := c.serverOrder(), []string{"a:4443", "b:4443", "c:4443"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// after a server fails the next one is tried first, and the failed one last
+	c.failedServer("a:4443")
+	if got, want := c.serverOrder(), []string{"b:4443", "c:4443", "a:4443"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after a failed: got %q, want %q", got, want)
+	}
+	c.failedServer("c:4443")
+	if got, want := c.serverOrder(), []string{"a:4443", "b:4443", "c:4443"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after c failed: got %q, want %q", got, want)
+	}
+
+	// a server that's no longer listed changes nothing
+	c.failedServer("d:4443")
+	if got := c.serverOrder(); got[0] != "a:4443" {
+		t.Errorf("an unknown server moved the order to %q", got)
+	}
+}
+
+func TestServerOrderSingle(t *testing.T) {
+	c := serversModel("latency", "a:4443")
+	c.failedServer("a:4443")
+	if got := c.serverOrder(); !reflect.DeepEqual(got, []string{"a:4443"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+// An address nothing listens on
+func closedAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestServerOrderLatency(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the unreachable servers go last, in the order they're listed
+	down, alsoDown, up := closedAddr(t), closedAddr(t), l.Addr().String()
+	c := serversModel("latency", down, up, alsoDown)
+	if got, want := c.serverOrder(), []string{up, down, alsoDown}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// through a proxy only the proxy would be measured
+	c.proxyUrl = "http://proxy.example.com:3128"
+	if got, want := c.serverOrder(), []string{down, up, alsoDown}; !reflect.DeepEqual(got, want) {
+		t.Errorf("through a proxy: got %q, want %q", got, want)
+	}
+}
+
+func TestByLatency(t *testing.T) {
+	order := []int{0, 1, 2, 3, 4}
+	latencies := []time.Duration{30, -1, 10, -1, 20}
+	sort.Stable(byLatency{order, latencies})
+
+	if want := []int{2, 4, 0, 1, 3}; !reflect.DeepEqual(order, want) {
+		t.Errorf("got %v, want %v", order, want)
+	}
+}
+
+// A controller that tells why the model stopped
+type stopController struct {
+	nopController
+	stopped chan string
+}
+
+func (c stopController) Shutdown(message string) { c.stopped <- "shutdown: " + message }
+func (c stopController) Fail(message string)     { c.stopped <- "fail: " + message }
+
+// A model that runs against the given servers, retrying right away
+func runModel(t *testing.T, reconnect *ReconnectConfiguration, addrs ...string) (*ClientModel, chan string) {
+	reconnect.MinBackoff, reconnect.MaxBackoff = "1ms", "2ms"
+	if err := reconnect.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	heartbeats := &HeartbeatConfiguration{PingInterval: "10ms", MaxPongLatency: "1h"}
+	if err := heartbeats.normalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := testModel(t, map[string]*TunnelConfiguration{"a": httpTunnel("127.0.0.1:8000")})
+	stopped := make(chan string, 1)
+	c.ctl = stopController{stopped: stopped}
+	c.ctlConn = nil
+	c.serverAddrs = addrs
+	c.serverPolicy = "failover"
+	c.rootTls = &tls.Config{InsecureSkipVerify: true}
+	c.reconnect = reconnect
+	c.heartbeats = heartbeats
+	return c, stopped
+}
+
+// Run the model until it gives up, returning why
+func runUntilStopped(t *testing.T, c *ClientModel, stopped chan string) string {
+	done := make(chan bool)
+	go func() {
+		c.Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the client never gave up")
+	}
+	select {
+	case why := <-stopped:
+		return why
+	default:
+		t.Fatal("the client gave up without stopping")
+		return ""
+	}
+}
+
+// A server at an address that hangs up on every client, sending name to
+// attempts when one tries it
+func hangingUpServer(t *testing.T, name string, attempts chan string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			attempts <- name
+			c.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// A server that lets the first client in and opens its tunnels, then hangs
+// up and goes away
+func onceServer(t *testing.T, name string, attempts chan string) string {
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	cert := srv.TLS.Certificates[0]
+	srv.Close()
+
+	l, err := conn.Listen("127.0.0.1:0", "srv", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		c, ok := <-l.Conns
+		l.Close()
+		if !ok {
+			return
+		}
+		attempts <- name
+
+		defer c.Close()
+		for {
+			m, err := msg.ReadMsg(c)
+			if err != nil {
+				return
+			}
+
+			switch m := m.(type) {
+			case *msg.Auth:
+				msg.WriteMsg(c, &msg.AuthResp{ClientId: "client"})
+			case *msg.ReqTunnel:
+				msg.WriteMsg(c, &msg.NewTunnel{ReqId: m.ReqId, Url: "http://a.ngrok.me", Protocol: m.Protocol})
+				return
+			}
+		}
+	}()
+	return l.Addr.String()
+}
+
+func TestRunFailsOver(t *testing.T) {
+	attempts := make(chan string, 100)
+	c, stopped := runModel(t, &ReconnectConfiguration{MaxRetries: 1, ExitOnFailure: true},
+		hangingUpServer(t, "first", attempts),
+		onceServer(t, "second", attempts))
+
+	why := runUntilStopped(t, c, stopped)
+	if why != "fail: Failed to connect to the server, giving up after 1 retries" {
+		t.Errorf("the client stopped with %q", why)
+	}
+
+	// the second server takes over from the first, and once it goes away
+	// the first one is tried again, before and after the client waits
+	if got, want := tried(attempts), []string{"first", "second", "first", "first"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("the client tried %q, want %q", got, want)
+	}
+}
+
+// The servers tried so far, in order. A server tells of an attempt before
+// the client can see it fail.
+func tried(attempts chan string) (names []string) {
+	for {
+		select {
+		case name := <-attempts:
+			names = append(names, name)
+		default:
+			return
+		}
+	}
+}
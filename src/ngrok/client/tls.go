@@ -6,6 +6,8 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"ngrok/client/assets"
 )
 
@@ -35,3 +37,42 @@ func LoadTLSConfig(rootCertPaths []string) (*tls.Config, error) {
 	//log.Info("MinVersion:", tls.VersionSSL30)
 	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionSSL30, InsecureSkipVerify: true}, nil
 }
+
+// TLS configuration for dialing the local service of a tunnel whose address
+// is https://. Without a CA file the host's root certificates are trusted.
+func LoadUpstreamTLSConfig(t *TunnelConfiguration) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         t.UpstreamServerName,
+		InsecureSkipVerify: t.UpstreamInsecure,
+	}
+
+	if t.UpstreamCa != "" {
+		pemBytes, err := ioutil.ReadFile(t.UpstreamCa)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pemBytes) {
+			return nil, fmt.Errorf("No certificates found in %s", t.UpstreamCa)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Returns the configuration to dial addr with, verifying the certificate
+// against the host of addr unless a server name is configured
+func upstreamTLSConfigFor(cfg *tls.Config, addr string) *tls.Config {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+
+	if cfg.ServerName != "" {
+		return cfg
+	}
+
+	cfg = cfg.Clone()
+	cfg.ServerName, _, _ = net.SplitHostPort(addr)
+	return cfg
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"ngrok/conn"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A TLS server whose certificate is for example.com and 127.0.0.1, and a
+// file with that certificate to trust it by
+func upstreamServer(t *testing.T) (addr, caFile string) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// the handshakes meant to fail would be logged
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	caFile = filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return srv.Listener.Addr().String(), caFile
+}
+
+// Dial addr over TLS the way dialLocal does for a tunnel configured as t
+func upstreamHandshake(t *testing.T, config *TunnelConfiguration, addr string) error {
+	cfg, err := LoadUpstreamTLSConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := conn.Dial(addr, "prv", upstreamTLSConfigFor(cfg, addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	return c.Handshake()
+}
+
+func TestUpstreamTLSVerification(t *testing.T) {
+	addr, caFile := upstreamServer(t)
+
+	cases := []struct {
+		name   string
+		config *TunnelConfiguration
+		ok     bool
+	}{
+		// the test certificate isn't among the host's roots
+		{"default", &TunnelConfiguration{}, false},
+		{"ca", &TunnelConfiguration{UpstreamCa: caFile}, true},
+		{"server name", &TunnelConfiguration{UpstreamCa: caFile, UpstreamServerName: "example.com"}, true},
+		{"wrong server name", &TunnelConfiguration{UpstreamCa: caFile, UpstreamServerName: "other.test"}, false},
+		{"insecure", &TunnelConfiguration{UpstreamInsecure: true}, true},
+		{"insecure wrong server name", &TunnelConfiguration{UpstreamInsecure: true, UpstreamServerName: "other.test"}, true},
+	}
+
+	for _, c := range cases {
+		err := upstreamHandshake(t, c.config, addr)
+		if c.ok && err != nil {
+			t.Errorf("%s: handshake failed: %v", c.name, err)
+		} else if !c.ok && err == nil {
+			t.Errorf("%s: an untrusted server was accepted", c.name)
+		}
+	}
+}
+
+func TestUpstreamTLSBadCa(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadUpstreamTLSConfig(&TunnelConfiguration{UpstreamCa: filepath.Join(dir, "missing.pem")}); err == nil {
+		t.Error("a missing CA file was accepted")
+	}
+
+	notPem := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(notPem, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadUpstreamTLSConfig(&TunnelConfiguration{UpstreamCa: notPem})
+	if err == nil || !strings.Contains(err.Error(), notPem) {
+		t.Errorf("got error %v for a CA file without certificates", err)
+	}
+}
+
+func TestUpstreamTLSConfigFor(t *testing.T) {
+	cfg, err := LoadUpstreamTLSConfig(&TunnelConfiguration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the host of the address is verified, without changing the tunnel's config
+	if got := upstreamTLSConfigFor(cfg, "app.test:8443"); got.ServerName != "app.test" {
+		t.Errorf("got server name %q, want app.test", got.ServerName)
+	}
+	if cfg.ServerName != "" {
+		t.Errorf("the tunnel's config got server name %q", cfg.ServerName)
+	}
+	if got := upstreamTLSConfigFor(nil, "app.test:8443"); got.ServerName != "app.test" {
+		t.Errorf("got server name %q without a config", got.ServerName)
+	}
+
+	cfg.ServerName = "example.com"
+	if got := upstreamTLSConfigFor(cfg, "app.test:8443"); got.ServerName != "example.com" {
+		t.Errorf("got server name %q, want the configured one", got.ServerName)
+	}
+}
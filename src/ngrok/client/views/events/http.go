@@ -0,0 +1,73 @@
+package events
+
+import (
+	"ngrok/client/mvc"
+	"ngrok/log"
+	"ngrok/proto"
+	"time"
+)
+
+// requests still waiting for their response are forgotten after this long
+const pendingTimeout = 10 * time.Minute
+
+// Writes an event for each HTTP request once its response is in
+type HttpView struct {
+	log.Logger
+
+	eventView *EventView
+	httpProto *proto.Http
+}
+
+func newHttpView(ctl mvc.Controller, eventView *EventView, p *proto.Http) *HttpView {
+	v := &HttpView{
+		Logger:    log.NewPrefixLogger("view", "events", "http"),
+		eventView: eventView,
+		httpProto: p,
+	}
+	ctl.Go(v.run)
+	return v
+}
+
+func (v *HttpView) run() {
+	updates := v.httpProto.Txns.Reg()
+
+	// each transaction comes once for its request and once more for its
+	// response, which may already be set the first time
+	pending := make(map[*proto.HttpTxn]bool)
+	for obj := range updates {
+		txn := obj.(*proto.HttpTxn)
+		if !pending[txn] {
+			pending[txn] = true
+			continue
+		}
+		delete(pending, txn)
+
+		// a request whose response couldn't be read never comes again
+		for t := range pending {
+			if time.Since(t.Start) > pendingTimeout {
+				delete(pending, t)
+			}
+		}
+
+		if txn.Resp == nil {
+			continue
+		}
+
+		e := &Event{
+			Event:      "http",
+			Method:     txn.Req.Method,
+			Path:       txn.Req.URL.RequestURI(),
+			Status:     txn.Resp.Status,
+			DurationMs: float64(txn.Duration) / float64(time.Millisecond),
+		}
+		if connCtx, ok := txn.ConnUserCtx.(mvc.ConnectionContext); ok {
+			e.Name = connCtx.Tunnel.Name
+			e.Url = connCtx.Tunnel.PublicUrl
+			e.ClientAddr = connCtx.ClientAddr
+		}
+		v.eventView.write(e)
+	}
+}
+
+func (v *HttpView) Shutdown() {
+}
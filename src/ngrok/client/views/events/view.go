@@ -0,0 +1,156 @@
+// machine readable output for scripts: one JSON event per line
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"ngrok/client/mvc"
+	"ngrok/log"
+	"ngrok/proto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// An event the view writes. Fields that don't apply to it are left out.
+type Event struct {
+	Event      string  `json:"event"`
+	Time       string  `json:"time"`
+	Status     string  `json:"status,omitempty"`
+	Server     string  `json:"server,omitempty"`
+	Name       string  `json:"name,omitempty"`
+	Url        string  `json:"url,omitempty"`
+	Proto      string  `json:"proto,omitempty"`
+	LocalAddr  string  `json:"local_addr,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	Message    string  `json:"message,omitempty"`
+	Method     string  `json:"method,omitempty"`
+	Path       string  `json:"path,omitempty"`
+	ClientAddr string  `json:"client_addr,omitempty"`
+	DurationMs float64 `json:"duration_ms,omitempty"`
+}
+
+type EventView struct {
+	log.Logger
+
+	ctl      mvc.Controller
+	updates  chan interface{}
+	shutdown chan int
+
+	// guards out, which the http views write to as well
+	mu  sync.Mutex
+	out *json.Encoder
+
+	// what the last events told about
+	status  string
+	server  string
+	tunnels map[string]mvc.Tunnel
+	errors  map[string]string
+}
+
+func NewEventView(ctl mvc.Controller, w io.Writer) *EventView {
+	v := &EventView{
+		Logger:   log.NewPrefixLogger("view", "events"),
+		ctl:      ctl,
+		updates:  ctl.Updates().Reg(),
+		shutdown: make(chan int),
+		out:      json.NewEncoder(w),
+		tunnels:  make(map[string]mvc.Tunnel),
+		errors:   make(map[string]string),
+	}
+
+	ctl.Go(v.run)
+	return v
+}
+
+func connStatusRepr(status mvc.ConnStatus) string {
+	switch status {
+	case mvc.ConnConnecting:
+		return "connecting"
+	case mvc.ConnReconnecting:
+		return "reconnecting"
+	case mvc.ConnOnline:
+		return "online"
+	}
+	return "unknown"
+}
+
+func newTunnelEvent(event string, t mvc.Tunnel) *Event {
+	return &Event{
+		Event:     event,
+		Name:      t.Name,
+		Url:       t.PublicUrl,
+		Proto:     strings.SplitN(t.PublicUrl, "://", 2)[0],
+		LocalAddr: t.LocalAddr,
+	}
+}
+
+func (v *EventView) write(e *Event) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	e.Time = time.Now().Format(time.RFC3339Nano)
+	if err := v.out.Encode(e); err != nil {
+		v.Warn("Failed to write event: %v", err)
+	}
+}
+
+// Write the events for what changed since the last state
+func (v *EventView) diff(state mvc.State) {
+	status, server := connStatusRepr(state.GetConnStatus()), state.GetServerAddr()
+	if status != v.status || server != v.server {
+		v.status, v.server = status, server
+		v.write(&Event{Event: "status", Status: status, Server: server})
+	}
+
+	tunnels := make(map[string]mvc.Tunnel)
+	for _, t := range state.GetTunnels() {
+		tunnels[t.PublicUrl] = t
+		if old, ok := v.tunnels[t.PublicUrl]; !ok || old.LocalAddr != t.LocalAddr {
+			v.write(newTunnelEvent("tunnel_established", t))
+		}
+	}
+	for url, t := range v.tunnels {
+		if _, ok := tunnels[url]; !ok {
+			v.write(newTunnelEvent("tunnel_closed", t))
+		}
+	}
+	v.tunnels = tunnels
+
+	errors := state.GetTunnelErrors()
+	for name, err := range errors {
+		if v.errors[name] != err {
+			v.write(&Event{Event: "tunnel_error", Name: name, Error: err})
+		}
+	}
+	v.errors = errors
+}
+
+func (v *EventView) run() {
+	defer close(v.shutdown)
+
+	v.diff(v.ctl.State())
+	for {
+		select {
+		case obj := <-v.updates:
+			v.diff(obj.(mvc.State))
+
+		case <-v.shutdown:
+			return
+		}
+	}
+}
+
+// Write the last event, why the client is exiting
+func (v *EventView) Quit(message string) {
+	v.write(&Event{Event: "exit", Message: message})
+}
+
+func (v *EventView) Shutdown() {
+	v.shutdown <- 1
+	<-v.shutdown
+}
+
+func (v *EventView) NewHttpView(p *proto.Http) *HttpView {
+	return newHttpView(v.ctl, v, p)
+}
@@ -0,0 +1,198 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"ngrok/client/mvc"
+	"ngrok/log"
+	"ngrok/proto"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The parts of the model's state the view looks at
+type testState struct {
+	mvc.State
+	status  mvc.ConnStatus
+	server  string
+	tunnels []mvc.Tunnel
+	errors  map[string]string
+}
+
+func (s *testState) GetConnStatus() mvc.ConnStatus      { return s.status }
+func (s *testState) GetServerAddr() string              { return s.server }
+func (s *testState) GetTunnels() []mvc.Tunnel           { return s.tunnels }
+func (s *testState) GetTunnelErrors() map[string]string { return s.errors }
+
+func testView() (*EventView, *bytes.Buffer) {
+	out := new(bytes.Buffer)
+	return &EventView{
+		Logger:  log.NewPrefixLogger("view", "events"),
+		out:     json.NewEncoder(out),
+		tunnels: make(map[string]mvc.Tunnel),
+		errors:  make(map[string]string),
+	}, out
+}
+
+// The events written since the last call, without their times, which are
+// checked instead
+func events(t *testing.T, v *EventView, out *bytes.Buffer) []Event {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var written []Event
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("event %q isn't JSON: %v", line, err)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, e.Time); err != nil {
+			t.Errorf("event %q has a bad time: %v", line, err)
+		}
+		e.Time = ""
+		written = append(written, e)
+	}
+	out.Reset()
+	return written
+}
+
+func expectEvents(t *testing.T, got []Event, want ...Event) {
+	if len(got)+len(want) != 0 && !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %+v, want %+v", got, want)
+	}
+}
+
+func TestEventViewDiff(t *testing.T) {
+	v, out := testView()
+	web := mvc.Tunnel{Name: "web", PublicUrl: "https://web.ngrok.me", LocalAddr: "127.0.0.1:8080"}
+	ssh := mvc.Tunnel{Name: "ssh", PublicUrl: "tcp://ngrok.me:5000", LocalAddr: "127.0.0.1:22"}
+
+	state := &testState{status: mvc.ConnConnecting, server: "ngrok.me:4443", errors: map[string]string{}}
+	v.diff(state)
+	expectEvents(t, events(t, v, out), Event{Event: "status", Status: "connecting", Server: "ngrok.me:4443"})
+
+	state.status = mvc.ConnOnline
+	state.tunnels = []mvc.Tunnel{web, ssh}
+	state.errors = map[string]string{"api": "The tunnel is already registered."}
+	v.diff(state)
+	got := events(t, v, out)
+	if len(got) != 4 {
+		t.Fatalf("got events %+v", got)
+	}
+	expectEvents(t, got[:1], Event{Event: "status", Status: "online", Server: "ngrok.me:4443"})
+	established := map[string]Event{got[1].Name: got[1], got[2].Name: got[2]}
+	if want := (Event{Event: "tunnel_established", Name: "web", Url: "https://web.ngrok.me", Proto: "https", LocalAddr: "127.0.0.1:8080"}); established["web"] != want {
+		t.Errorf("got %+v, want %+v", established["web"], want)
+	}
+	if want := (Event{Event: "tunnel_established", Name: "ssh", Url: "tcp://ngrok.me:5000", Proto: "tcp", LocalAddr: "127.0.0.1:22"}); established["ssh"] != want {
+		t.Errorf("got %+v, want %+v", established["ssh"], want)
+	}
+	expectEvents(t, got[3:], Event{Event: "tunnel_error", Name: "api", Error: "The tunnel is already registered."})
+
+	// nothing changed, nothing to tell
+	v.diff(state)
+	expectEvents(t, events(t, v, out))
+
+	// a reload points a tunnel elsewhere and closes another
+	moved := web
+	moved.LocalAddr = "127.0.0.1:9090"
+	state.tunnels = []mvc.Tunnel{moved}
+	v.diff(state)
+	expectEvents(t, events(t, v, out),
+		Event{Event: "tunnel_established", Name: "web", Url: "https://web.ngrok.me", Proto: "https", LocalAddr: "127.0.0.1:9090"},
+		Event{Event: "tunnel_closed", Name: "ssh", Url: "tcp://ngrok.me:5000", Proto: "tcp", LocalAddr: "127.0.0.1:22"},
+	)
+
+	// the connection drops, taking the tunnels with it, and the error changes
+	state.status = mvc.ConnReconnecting
+	state.tunnels = nil
+	state.errors = map[string]string{"api": "Server is full."}
+	v.diff(state)
+	expectEvents(t, events(t, v, out),
+		Event{Event: "status", Status: "reconnecting", Server: "ngrok.me:4443"},
+		Event{Event: "tunnel_closed", Name: "web", Url: "https://web.ngrok.me", Proto: "https", LocalAddr: "127.0.0.1:9090"},
+		Event{Event: "tunnel_error", Name: "api", Error: "Server is full."},
+	)
+
+	// failing over to another server is a status change too
+	state.server = "backup.ngrok.me:4443"
+	v.diff(state)
+	expectEvents(t, events(t, v, out), Event{Event: "status", Status: "reconnecting", Server: "backup.ngrok.me:4443"})
+}
+
+func TestEventViewQuit(t *testing.T) {
+	v, out := testView()
+	v.Quit("Server failed to allocate tunnel")
+	expectEvents(t, events(t, v, out), Event{Event: "exit", Message: "Server failed to allocate tunnel"})
+}
+
+func TestEventOmitsEmptyFields(t *testing.T) {
+	v, out := testView()
+	v.write(&Event{Event: "status", Status: "online"})
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 3 || fields["event"] != "status" || fields["status"] != "online" || fields["time"] == nil {
+		t.Errorf("got %s", out.Bytes())
+	}
+}
+
+func TestHttpViewEvents(t *testing.T) {
+	v, out := testView()
+	p := proto.NewHttp()
+	hv := &HttpView{Logger: log.NewPrefixLogger("view", "events", "http"), eventView: v, httpProto: p}
+	go hv.run()
+	// let the view register for the transactions
+	time.Sleep(50 * time.Millisecond)
+
+	newTxn := func(path string, status string) *proto.HttpTxn {
+		req, _ := http.NewRequest("GET", "http://web.ngrok.me"+path, nil)
+		txn := &proto.HttpTxn{
+			Req:      &proto.HttpRequest{Request: req},
+			Start:    time.Now(),
+			Duration: 1500 * time.Microsecond,
+			ConnUserCtx: mvc.ConnectionContext{
+				Tunnel:     mvc.Tunnel{Name: "web", PublicUrl: "https://web.ngrok.me"},
+				ClientAddr: "1.2.3.4:5678",
+			},
+		}
+		if status != "" {
+			txn.Resp = &proto.HttpResponse{Response: &http.Response{Status: status}}
+		}
+		return txn
+	}
+
+	// each transaction comes once with its request and again with its response
+	noResponse := newTxn("/gone", "")
+	p.Txns.In() <- noResponse
+	p.Txns.In() <- noResponse
+	txn := newTxn("/a?b=c", "200 OK")
+	p.Txns.In() <- txn
+	p.Txns.In() <- txn
+
+	var got []Event
+	deadline := time.Now().Add(5 * time.Second)
+	for len(got) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		got = events(t, v, out)
+	}
+	expectEvents(t, got, Event{
+		Event:      "http",
+		Name:       "web",
+		Url:        "https://web.ngrok.me",
+		ClientAddr: "1.2.3.4:5678",
+		Method:     "GET",
+		Path:       "/a?b=c",
+		Status:     "200 OK",
+		DurationMs: 1.5,
+	})
+}
@@ -102,7 +102,8 @@ func (v *TermView) draw() {
 	v.APrintf(statusColor, 0, 2, "%-30s%s", "Tunnel Status", statusStr)
 
 	v.Printf(0, 3, "%-30s%s/%s", "Version", state.GetClientVersion(), state.GetServerVersion())
-	var i int = 4
+	v.Printf(0, 4, "%-30s%s", "Server", state.GetServerAddr())
+	var i int = 5
 	for _, t := range state.GetTunnels() {
 		v.Printf(0, i, "%-30s%s -> %s", "Forwarding", t.PublicUrl, t.LocalAddr)
 		i++
@@ -68,6 +68,7 @@ type WebHttpView struct {
 
 type SerializedUiState struct {
 	Tunnels []mvc.Tunnel
+	Server  string
 }
 
 type SerializedPayload struct {
@@ -248,9 +249,10 @@ func (whv *WebHttpView) register() {
 
 		tmpl := template.Must(template.New("page.html").Delims("{%", "%}").Parse(string(pageTmpl)))
 
+		state := whv.ctl.State()
 		payloadData := SerializedPayload{
 			Txns:    whv.HttpRequests.Slice(),
-			UiState: SerializedUiState{Tunnels: whv.ctl.State().GetTunnels()},
+			UiState: SerializedUiState{Tunnels: state.GetTunnels(), Server: state.GetServerAddr()},
 		}
 
 		payload, err := json.Marshal(payloadData)
@@ -13,6 +13,7 @@ import (
 	"net/url"
 	"ngrok/log"
 	"sync"
+	"sync/atomic"
 )
 
 type Conn interface {
@@ -34,6 +35,9 @@ type loggedConn struct {
 type Listener struct {
 	net.Addr
 	Conns chan *loggedConn
+
+	listener net.Listener
+	closed   int32
 }
 
 func wrapConn(conn net.Conn, typ string) *loggedConn {
@@ -59,15 +63,27 @@ func Listen(addr, typ string, tlsCfg *tls.Config) (l *Listener, err error) {
 		return
 	}
 
+	return NewListener(listener, typ, tlsCfg), nil
+}
+
+// Accept connections from an already bound listener, e.g. one inherited
+// from another process
+func NewListener(listener net.Listener, typ string, tlsCfg *tls.Config) (l *Listener) {
 	l = &Listener{
-		Addr:  listener.Addr(),
-		Conns: make(chan *loggedConn),
+		Addr:     listener.Addr(),
+		Conns:    make(chan *loggedConn),
+		listener: listener,
 	}
 
 	go func() {
 		for {
 			rawConn, err := listener.Accept()
 			if err != nil {
+				if atomic.LoadInt32(&l.closed) == 1 {
+					close(l.Conns)
+					return
+				}
+
 				log.Error("Failed to accept new TCP connection of type %s: %v", typ, err)
 				continue
 			}
@@ -83,6 +99,17 @@ func Listen(addr, typ string, tlsCfg *tls.Config) (l *Listener, err error) {
 	return
 }
 
+// Stop accepting connections. Conns is closed once the accept loop exits.
+func (l *Listener) Close() error {
+	atomic.StoreInt32(&l.closed, 1)
+	return l.listener.Close()
+}
+
+// The underlying listener, to hand its socket to another process
+func (l *Listener) Listener() net.Listener {
+	return l.listener
+}
+
 func Wrap(conn net.Conn, typ string) *loggedConn {
 	return wrapConn(conn, typ)
 }
@@ -103,6 +130,14 @@ func Dial(addr, typ string, tlsCfg *tls.Config) (conn *loggedConn, err error) {
 	return
 }
 
+// Both ends of an in-memory connection, the first one logged as typ
+func Pipe(typ string) (Conn, net.Conn) {
+	local, remote := net.Pipe()
+	wrapped := &loggedConn{nil, local, log.NewPrefixLogger(), rand.Int31(), typ}
+	wrapped.AddLogPrefix(wrapped.Id())
+	return wrapped, remote
+}
+
 func DialHttpProxy(proxyUrl, addr, typ string, tlsCfg *tls.Config) (conn *loggedConn, err error) {
 	// parse the proxy address
 	var parsedUrl *url.URL
@@ -165,6 +200,15 @@ func (c *loggedConn) StartTLS(tlsCfg *tls.Config) {
 	c.Conn = tls.Client(c.Conn, tlsCfg)
 }
 
+// Completes the TLS handshake started by StartTLS now instead of on the
+// first read or write
+func (c *loggedConn) Handshake() error {
+	if tlsConn, ok := c.Conn.(*tls.Conn); ok {
+		return tlsConn.Handshake()
+	}
+	return nil
+}
+
 func (c *loggedConn) Close() (err error) {
 	if err := c.Conn.Close(); err == nil {
 		c.Debug("Closing")
@@ -189,6 +233,9 @@ func (c *loggedConn) CloseRead() error {
 	// connection termination. Unfortunately, when I've tried that, I've observed
 	// failures where the connection was closed *before* flushing its write buffer,
 	// set with SetLinger() set properly (which it is by default).
+	if c.tcp == nil {
+		return fmt.Errorf("Can't half close connection %s", c.Id())
+	}
 	return c.tcp.CloseRead()
 }
 
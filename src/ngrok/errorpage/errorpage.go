@@ -0,0 +1,281 @@
+// Package errorpage renders the error responses ngrokd and ngrok send on
+// behalf of a tunnel from templates, or as JSON to clients that ask for it.
+package errorpage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+const (
+	// templates sent along with a tunnel request may not be larger than this
+	MaxTemplateSize = 64 * 1024
+
+	// nor more than this many
+	MaxTemplates = 16
+
+	// a page rendering larger than this is replaced by the built-in one
+	MaxPageSize = 256 * 1024
+)
+
+// Templates come from anybody with a tunnel and run on ngrokd, so they may
+// only use functions that can't blow up their output
+var allowedFuncs = map[string]bool{
+	"and": true, "or": true, "not": true, "len": true, "index": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"html": true, "js": true, "urlquery": true, "print": true,
+}
+
+var errPageTooLarge = errors.New("error page is too large")
+
+// What a template can show. Fields that don't apply are empty.
+type Data struct {
+	Status    int    `json:"status"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	Host      string `json:"host,omitempty"`
+	Url       string `json:"url,omitempty"`
+	LocalAddr string `json:"localAddr,omitempty"`
+	RequestId string `json:"requestId,omitempty"`
+	Support   string `json:"support,omitempty"`
+}
+
+var defaultTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Status}} {{.Title}}</title></head>
+<body style="background-color: #97a8b9">
+    <div style="margin:auto; width:400px;padding: 20px 60px; background-color: #D3D3D3; border: 5px solid maroon;">
+        <h2>{{.Title}}</h2>
+        <p>{{.Message}}</p>
+        {{if .Support}}<p>Need help? Contact {{.Support}}.</p>{{end}}
+        {{if .RequestId}}<p><small>Request id {{.RequestId}}</small></p>{{end}}
+    </div>
+</body>
+</html>
+`))
+
+// A set of error page templates by status. A status without a template of
+// its own uses the set's "error" template, then the parent set's.
+type Pages struct {
+	parent    *Pages
+	templates map[string]*template.Template
+}
+
+// The built-in pages
+func Default() *Pages {
+	return &Pages{templates: map[string]*template.Template{"error": defaultTemplate}}
+}
+
+// Parse templates by status, e.g. "404", or "error" for any status. Statuses
+// without a template fall back to parent.
+func Parse(sources map[string]string, parent *Pages) (*Pages, error) {
+	if len(sources) > MaxTemplates {
+		return nil, fmt.Errorf("No more than %d error pages are allowed", MaxTemplates)
+	}
+
+	p := &Pages{parent: parent, templates: make(map[string]*template.Template)}
+	for name, src := range sources {
+		if name != "error" {
+			if _, err := strconv.Atoi(name); err != nil {
+				return nil, fmt.Errorf("Error page %s must be named by its status code or 'error'", name)
+			}
+		}
+
+		if len(src) > MaxTemplateSize {
+			return nil, fmt.Errorf("Error page %s is larger than %d bytes", name, MaxTemplateSize)
+		}
+
+		tmpl, err := template.New(name).Parse(src)
+		if err == nil {
+			err = checkNode(tmpl.Tree.Root)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse error page %s: %v", name, err)
+		}
+		p.templates[name] = tmpl
+	}
+	return p, nil
+}
+
+// Reject the parts of a template that can loop or call other templates, and
+// functions outside allowedFuncs
+func checkNode(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkNode(child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkNode(n.Pipe)
+	case *parse.IfNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkNode(cmd); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkNode(arg); err != nil {
+				return err
+			}
+		}
+	case *parse.ChainNode:
+		return checkNode(n.Node)
+	case *parse.IdentifierNode:
+		if !allowedFuncs[n.Ident] {
+			return fmt.Errorf("function %s is not allowed", n.Ident)
+		}
+	case *parse.RangeNode:
+		return fmt.Errorf("range is not allowed")
+	case *parse.TemplateNode:
+		return fmt.Errorf("template and block are not allowed")
+	}
+	return nil
+}
+
+func checkBranch(n *parse.BranchNode) error {
+	for _, child := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+		if err := checkNode(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stops a template once it has written max bytes
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, errPageTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// Load the templates in a directory: 404.html, 502.html, ... and error.html
+// for any other status
+func Load(dir string, parent *Pages) (*Pages, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]string)
+	for _, path := range paths {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		sources[strings.TrimSuffix(filepath.Base(path), ".html")] = string(buf)
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("No error pages found in %s", dir)
+	}
+	return Parse(sources, parent)
+}
+
+// Read the files of a map from status to template path
+func ReadFiles(paths map[string]string) (map[string]string, error) {
+	sources := make(map[string]string)
+	for status, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if fi.Size() > MaxTemplateSize {
+			return nil, fmt.Errorf("Error page %s is larger than %d bytes", path, MaxTemplateSize)
+		}
+
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		sources[status] = string(buf)
+	}
+	return sources, nil
+}
+
+func (p *Pages) lookup(status int) *template.Template {
+	for ; p != nil; p = p.parent {
+		if tmpl, ok := p.templates[strconv.Itoa(status)]; ok {
+			return tmpl
+		}
+		if tmpl, ok := p.templates["error"]; ok {
+			return tmpl
+		}
+	}
+	return defaultTemplate
+}
+
+// Returns true if the client prefers JSON, going by its Accept header
+func WantsJSON(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			return true
+		case mediaType == "text/html":
+			return false
+		}
+	}
+	return false
+}
+
+// Render a complete HTTP response for an error. The body is JSON if the
+// Accept header asks for it, otherwise the page for the status.
+func (p *Pages) Render(d *Data, accept string) []byte {
+	if d.Title == "" {
+		d.Title = http.StatusText(d.Status)
+	}
+
+	body := limitedBuffer{max: MaxPageSize}
+	contentType := "text/html; charset=utf-8"
+	if WantsJSON(accept) {
+		contentType = "application/json"
+		json.NewEncoder(&body).Encode(map[string]*Data{"error": d})
+	} else if err := p.lookup(d.Status).Execute(&body, d); err != nil {
+		// a broken custom page shouldn't hide the error
+		body.Reset()
+		defaultTemplate.Execute(&body, d)
+	}
+
+	var resp bytes.Buffer
+	fmt.Fprintf(&resp, "HTTP/1.0 %d %s\r\n", d.Status, http.StatusText(d.Status))
+	fmt.Fprintf(&resp, "Content-Type: %s\r\n", contentType)
+	fmt.Fprintf(&resp, "Content-Length: %d\r\n", body.Len())
+	if d.Status == http.StatusUnauthorized {
+		resp.WriteString("WWW-Authenticate: Basic realm=\"ngrok\"\r\n")
+	}
+	if d.RequestId != "" {
+		fmt.Fprintf(&resp, "X-Ngrok-Request-Id: %s\r\n", d.RequestId)
+	}
+	resp.WriteString("\r\n")
+	resp.Write(body.Bytes())
+	return resp.Bytes()
+}
@@ -15,11 +15,13 @@ func init() {
 	TypeMap["AuthResp"] = t((*AuthResp)(nil))
 	TypeMap["ReqTunnel"] = t((*ReqTunnel)(nil))
 	TypeMap["NewTunnel"] = t((*NewTunnel)(nil))
+	TypeMap["CloseTunnel"] = t((*CloseTunnel)(nil))
 	TypeMap["RegProxy"] = t((*RegProxy)(nil))
 	TypeMap["ReqProxy"] = t((*ReqProxy)(nil))
 	TypeMap["StartProxy"] = t((*StartProxy)(nil))
 	TypeMap["Ping"] = t((*Ping)(nil))
 	TypeMap["Pong"] = t((*Pong)(nil))
+	TypeMap["ServerShutdown"] = t((*ServerShutdown)(nil))
 }
 
 type Message interface{}
@@ -66,13 +68,40 @@ type ReqTunnel struct {
 	ReqId    string
 	Protocol string
 
-	// http only
+	// http only. Hostname and Subdomain may start with a wildcard label,
+	// *.team, to serve every name under it that has no tunnel of its own
 	Hostname  string
 	Subdomain string
 	HttpAuth  string
 
+	// http only. More user:password credentials accepted besides HttpAuth
+	HttpAuths []string
+
+	// http only. Serve only requests whose path is under PathPrefix, so that
+	// other tunnels can serve other paths of the same host. StripPrefix
+	// removes the prefix from the path before the request is forwarded.
+	PathPrefix  string
+	StripPrefix bool
+
+	// http only. Require visitors to log in with the server's OpenID Connect
+	// provider, and let in only these emails and the emails of these domains,
+	// or anybody who logs in if both are empty
+	OidcAuth    bool
+	OidcEmails  []string
+	OidcDomains []string
+
+	// http only. Templates of the pages the server answers with on the
+	// tunnel's behalf, by status code or "error" for any status
+	ErrorPages map[string]string
+
 	// tcp only
 	RemotePort uint16
+
+	// empty for a tunnel of its own. "roundrobin" or "leastconn" to share
+	// the url with other clients of the same user that ask for the same mode,
+	// "failover" to serve it only while no live client has a higher Priority
+	Balance  string
+	Priority int
 }
 
 // When the server opens a new tunnel on behalf of
@@ -89,6 +118,12 @@ type NewTunnel struct {
 	Error    string
 }
 
+// A client sends this message over the control channel to close one of
+// its tunnels, by the Url of a NewTunnel, without closing the others.
+type CloseTunnel struct {
+	Url string
+}
+
 // When the server wants to initiate a new tunneled connection, it sends
 // this message over the control channel to the client. When a client receives
 // this message, it must initiate a new proxy connection to the server.
@@ -118,3 +153,11 @@ type Ping struct {
 // it received a Ping.
 type Pong struct {
 }
+
+// Sent by the server over the control channel when it is shutting down or
+// restarting. It stops accepting new connections but finishes the ones in
+// flight, so the client should open a new control connection without closing
+// its proxy connections.
+type ServerShutdown struct {
+	Reason string
+}
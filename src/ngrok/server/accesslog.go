@@ -0,0 +1,407 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"ngrok/conn"
+	"ngrok/util"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	accessLogJSON     = "json"
+	accessLogCombined = "combined"
+)
+
+// nil when access logging is disabled
+var accessLog *AccessLog
+
+// One record per public connection, or for http(s) tunnels one per request,
+// which is the only case the HTTP fields are known.
+type AccessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RequestId  string    `json:"requestId,omitempty"`
+	AuthId     string    `json:"authId,omitempty"`
+	ClientId   string    `json:"clientId,omitempty"`
+	Tunnel     string    `json:"tunnel,omitempty"`
+	RemoteAddr string    `json:"remoteAddr"`
+	Host       string    `json:"host,omitempty"`
+	Method     string    `json:"method,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Proto      string    `json:"proto,omitempty"`
+	Status     int       `json:"status,omitempty"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	BytesIn    int64     `json:"bytesIn"`
+	BytesOut   int64     `json:"bytesOut"`
+	ProxyWait  float64   `json:"proxyWait"`
+	Duration   float64   `json:"duration"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func newAccessLogEntry(c conn.Conn) *AccessLogEntry {
+	return &AccessLogEntry{
+		Time:       time.Now(),
+		RequestId:  util.RandId(8),
+		RemoteAddr: c.RemoteAddr().String(),
+	}
+}
+
+func (e *AccessLogEntry) setTunnel(t *Tunnel) {
+	e.Tunnel = t.url
+	e.ClientId = t.ctl.id
+	e.AuthId = userLabel(t.ctl)
+}
+
+type AccessLog struct {
+	sync.Mutex
+	w      io.Writer
+	format string
+}
+
+func NewAccessLog(target, format string) (*AccessLog, error) {
+	switch format {
+	case accessLogJSON, accessLogCombined:
+	default:
+		return nil, fmt.Errorf("Unknown access log format %s, must be one of: %s, %s", format, accessLogJSON, accessLogCombined)
+	}
+
+	var w io.Writer
+	if target == "stdout" {
+		w = os.Stdout
+	} else {
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+
+	return &AccessLog{w: w, format: format}, nil
+}
+
+// Write an entry, completing its duration. Safe to call on a nil *AccessLog.
+func (l *AccessLog) Log(e *AccessLogEntry) {
+	if l == nil {
+		return
+	}
+
+	e.Duration = time.Since(e.Time).Seconds()
+
+	var line []byte
+	if l.format == accessLogCombined {
+		line = e.combined()
+	} else {
+		var err error
+		if line, err = json.Marshal(e); err != nil {
+			return
+		}
+		line = append(line, '\n')
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	l.w.Write(line)
+}
+
+// Apache combined log format
+func (e *AccessLogEntry) combined() []byte {
+	dash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+
+	host := e.RemoteAddr
+	if h, _, err := net.SplitHostPort(e.RemoteAddr); err == nil {
+		host = h
+	}
+
+	request := "-"
+	if e.Method != "" {
+		request = fmt.Sprintf("%s %s %s", e.Method, e.Path, e.Proto)
+	}
+
+	status := "-"
+	if e.Status != 0 {
+		status = strconv.Itoa(e.Status)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s - %s [%s] %s %s %d %s %s\n",
+		host,
+		dash(e.AuthId),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(request),
+		status,
+		e.BytesIn,
+		strconv.Quote(dash(e.Referer)),
+		strconv.Quote(dash(e.UserAgent)))
+	return buf.Bytes()
+}
+
+const (
+	// how far the parsers may fall behind the connection before they give up
+	maxTapBuffer = 1024 * 1024
+
+	// the largest request head that is parsed for the log
+	maxRequestHead = 64 * 1024
+
+	// requests that may wait for their response
+	maxPendingRequests = 64
+)
+
+// A copy of one direction of a connection, read by a parser on the side.
+// Writes never block the connection: if the parser falls behind by more
+// than maxTapBuffer or gives up, the copy stops.
+type tap struct {
+	mu      sync.Mutex
+	ready   *sync.Cond
+	buf     bytes.Buffer
+	closed  bool
+	stopped bool
+
+	// bytes handed to the parser
+	read int64
+}
+
+var errTapStopped = errors.New("tap stopped")
+
+func newTap() *tap {
+	t := &tap{}
+	t.ready = sync.NewCond(&t.mu)
+	return t
+}
+
+func (t *tap) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.stopped && !t.closed {
+		if t.buf.Len()+len(p) > maxTapBuffer {
+			t.stopped = true
+			t.buf.Reset()
+		} else {
+			t.buf.Write(p)
+		}
+		t.ready.Broadcast()
+	}
+	return len(p), nil
+}
+
+func (t *tap) Read(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for t.buf.Len() == 0 && !t.closed && !t.stopped {
+		t.ready.Wait()
+	}
+
+	if t.stopped {
+		return 0, errTapStopped
+	}
+	if t.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	n, _ := t.buf.Read(p)
+	t.read += int64(n)
+	return n, nil
+}
+
+// The parser reads what is left and then sees EOF
+func (t *tap) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	t.ready.Broadcast()
+}
+
+func (t *tap) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	t.buf.Reset()
+	t.ready.Broadcast()
+}
+
+// Bytes the parser has consumed through br
+func (t *tap) consumed(br *bufio.Reader) int64 {
+	return t.read - int64(br.Buffered())
+}
+
+// Copies whatever is read from the connection into a tap
+type tapConn struct {
+	conn.Conn
+	tap *tap
+}
+
+func (c *tapConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	if n > 0 {
+		c.tap.Write(p[:n])
+	}
+	return
+}
+
+// Logs each request on an http connection that is kept alive. Both
+// directions are parsed from copies, so a slow or confused parser never
+// holds up the connection; it stops logging instead.
+type requestLogger struct {
+	log   *AccessLog
+	first *AccessLogEntry
+
+	requests  *tap
+	responses *tap
+
+	// requests waiting for their response, in order
+	pending  chan *AccessLogEntry
+	stopped  chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	// only touched by the response parser until finish returns
+	logged int
+}
+
+// The first request is logged to first, whose head was already parsed
+func newRequestLogger(log *AccessLog, first *AccessLogEntry) *requestLogger {
+	return &requestLogger{
+		log:       log,
+		first:     first,
+		requests:  newTap(),
+		responses: newTap(),
+		pending:   make(chan *AccessLogEntry, maxPendingRequests),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// Start parsing what passes through the public and proxy connections,
+// returns the connections to join instead
+func (l *requestLogger) watch(public, proxy conn.Conn) (conn.Conn, conn.Conn) {
+	l.wg.Add(2)
+	go l.readRequests()
+	go l.readResponses()
+	return &tapConn{Conn: public, tap: l.requests}, &tapConn{Conn: proxy, tap: l.responses}
+}
+
+// Wait for the requests of a closed connection to be logged, returns how
+// many were
+func (l *requestLogger) finish() int {
+	l.requests.close()
+	l.responses.close()
+	l.wg.Wait()
+	return l.logged
+}
+
+func (l *requestLogger) stop() {
+	l.stopOnce.Do(func() {
+		close(l.stopped)
+		l.requests.stop()
+		l.responses.stop()
+	})
+}
+
+func (l *requestLogger) readRequests() {
+	defer l.wg.Done()
+	defer close(l.pending)
+
+	// the head is limited, the body isn't
+	limited := &io.LimitedReader{R: l.requests}
+	br := bufio.NewReader(limited)
+
+	for e := l.first; ; e = nil {
+		start := l.requests.consumed(br)
+		limited.N = maxRequestHead
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		limited.N = math.MaxInt64
+
+		if e == nil {
+			e = l.next(req)
+		}
+		_, err = io.Copy(ioutil.Discard, req.Body)
+		e.BytesOut = l.requests.consumed(br) - start
+
+		select {
+		case l.pending <- e:
+		case <-l.stopped:
+			return
+		}
+
+		// what follows an upgrade isn't http
+		if err != nil || req.Header.Get("Upgrade") != "" {
+			return
+		}
+	}
+}
+
+func (l *requestLogger) readResponses() {
+	defer l.wg.Done()
+
+	br := bufio.NewReader(l.responses)
+	for e := range l.pending {
+		start := l.responses.consumed(br)
+		req := &http.Request{Method: e.Method}
+		resp, err := http.ReadResponse(br, req)
+
+		// skip interim responses such as 100 Continue
+		for err == nil && resp.StatusCode < 200 && resp.StatusCode != http.StatusSwitchingProtocols {
+			resp, err = http.ReadResponse(br, req)
+		}
+
+		if err == nil {
+			e.Status = resp.StatusCode
+			_, err = io.Copy(ioutil.Discard, resp.Body)
+		} else {
+			e.Error = "The connection closed before a response"
+		}
+		e.BytesIn = l.responses.consumed(br) - start
+		l.log.Log(e)
+		l.logged++
+
+		if err != nil || e.Status == http.StatusSwitchingProtocols {
+			break
+		}
+	}
+
+	// log the requests that are left without a response
+	l.stop()
+	for e := range l.pending {
+		e.Error = "The connection closed before a response"
+		l.log.Log(e)
+		l.logged++
+	}
+}
+
+// An entry for a later request on the same connection
+func (l *requestLogger) next(req *http.Request) *AccessLogEntry {
+	return &AccessLogEntry{
+		Time:       time.Now(),
+		RequestId:  util.RandId(8),
+		AuthId:     l.first.AuthId,
+		ClientId:   l.first.ClientId,
+		Tunnel:     l.first.Tunnel,
+		RemoteAddr: l.first.RemoteAddr,
+		Host:       req.Host,
+		Method:     req.Method,
+		Path:       req.RequestURI,
+		Proto:      req.Proto,
+		Referer:    req.Referer(),
+		UserAgent:  req.UserAgent(),
+	}
+}
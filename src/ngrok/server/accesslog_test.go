@@ -0,0 +1,239 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"ngrok/conn"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Join a visitor and a tunnel client through a requestLogger, as
+// HandlePublicConnection does, and return the entries it logged
+func logRequests(t *testing.T, first *AccessLogEntry, visitor func(net.Conn), client func(net.Conn)) []AccessLogEntry {
+	visitorConn, public := tcpPipe(t)
+	proxy, clientConn := tcpPipe(t)
+
+	var out bytes.Buffer
+	log := &AccessLog{w: &out, format: accessLogJSON}
+	requests := newRequestLogger(log, first)
+	joinedPublic, joinedProxy := requests.watch(conn.Wrap(public, "pub"), conn.Wrap(proxy, "pxy"))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer clientConn.Close()
+		client(clientConn)
+	}()
+	go func() {
+		defer wg.Done()
+		defer visitorConn.Close()
+		visitor(visitorConn)
+	}()
+
+	conn.Join(joinedPublic, joinedProxy)
+	n := requests.finish()
+	wg.Wait()
+
+	var entries []AccessLogEntry
+	dec := json.NewDecoder(&out)
+	for {
+		var e AccessLogEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if n != len(entries) {
+		t.Errorf("finish says %d requests were logged, the log has %d", n, len(entries))
+	}
+	return entries
+}
+
+// Answer each request with the given responses, in order
+func answer(responses ...string) func(net.Conn) {
+	return func(c net.Conn) {
+		br := bufio.NewReader(c)
+		for _, resp := range responses {
+			req, err := http.ReadRequest(br)
+			if err != nil {
+				return
+			}
+			io.Copy(ioutil.Discard, req.Body)
+			io.WriteString(c, resp)
+		}
+	}
+}
+
+// Send requests one after the other on the same connection, reading each
+// response before the next request
+func visit(t *testing.T, requests ...string) func(net.Conn) {
+	return func(c net.Conn) {
+		br := bufio.NewReader(c)
+		for _, raw := range requests {
+			io.WriteString(c, raw)
+			req, _ := http.ReadRequest(bufio.NewReader(strings.NewReader(raw)))
+			resp, err := http.ReadResponse(br, req)
+			for err == nil && resp.StatusCode == http.StatusContinue {
+				resp, err = http.ReadResponse(br, req)
+			}
+			if err != nil {
+				t.Errorf("reading response to %q: %v", raw, err)
+				return
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+		}
+	}
+}
+
+func firstEntry() *AccessLogEntry {
+	return &AccessLogEntry{RequestId: "first", Tunnel: "http://a.ngrok.me", RemoteAddr: "1.2.3.4:5", Method: "GET", Path: "/", Proto: "HTTP/1.1"}
+}
+
+func TestRequestLoggerKeepAlive(t *testing.T) {
+	entries := logRequests(t, firstEntry(),
+		visit(t,
+			"GET / HTTP/1.1\r\nHost: a.ngrok.me\r\n\r\n",
+			"HEAD /head HTTP/1.1\r\nHost: a.ngrok.me\r\n\r\n",
+			"POST /upload HTTP/1.1\r\nHost: a.ngrok.me\r\nTransfer-Encoding: chunked\r\nUser-Agent: test\r\n\r\n5\r\nhello\r\n0\r\n\r\n",
+		),
+		answer(
+			"HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello",
+			// a HEAD response announces a body it doesn't have
+			"HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\n",
+			"HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 201 Created\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nok\r\n0\r\n\r\n",
+		))
+
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3: %+v", len(entries), entries)
+	}
+
+	want := []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", "/", 200},
+		{"HEAD", "/head", 200},
+		{"POST", "/upload", 201},
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Method != w.method || e.Path != w.path || e.Status != w.status {
+			t.Errorf("entry %d is %s %s %d, want %s %s %d", i, e.Method, e.Path, e.Status, w.method, w.path, w.status)
+		}
+		if e.Tunnel != "http://a.ngrok.me" || e.RemoteAddr != "1.2.3.4:5" {
+			t.Errorf("entry %d lost the connection's fields: %+v", i, e)
+		}
+		if e.BytesIn == 0 || e.BytesOut == 0 {
+			t.Errorf("entry %d has no bytes: %+v", i, e)
+		}
+	}
+
+	if entries[0].RequestId != "first" || entries[1].RequestId == "first" || entries[1].RequestId == "" {
+		t.Errorf("later requests must get their own ids, got %q and %q", entries[0].RequestId, entries[1].RequestId)
+	}
+	if entries[2].UserAgent != "test" || entries[2].Host != "a.ngrok.me" {
+		t.Errorf("third entry has user agent %q and host %q", entries[2].UserAgent, entries[2].Host)
+	}
+	if entries[0].BytesIn != int64(len("HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello")) {
+		t.Errorf("first response counted as %d bytes", entries[0].BytesIn)
+	}
+}
+
+func TestRequestLoggerPipelined(t *testing.T) {
+	entries := logRequests(t, firstEntry(),
+		func(c net.Conn) {
+			io.WriteString(c, "GET / HTTP/1.1\r\nHost: a\r\n\r\nGET /two HTTP/1.1\r\nHost: a\r\n\r\n")
+			ioutil.ReadAll(c)
+		},
+		answer(
+			"HTTP/1.1 204 No Content\r\n\r\n",
+			"HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n",
+		))
+
+	if len(entries) != 2 || entries[0].Status != 204 || entries[1].Status != 404 || entries[1].Path != "/two" {
+		t.Errorf("got %+v", entries)
+	}
+}
+
+func TestRequestLoggerNoResponse(t *testing.T) {
+	entries := logRequests(t, firstEntry(),
+		func(c net.Conn) {
+			io.WriteString(c, "GET / HTTP/1.1\r\nHost: a\r\n\r\n")
+			ioutil.ReadAll(c)
+		},
+		func(c net.Conn) {
+			http.ReadRequest(bufio.NewReader(c))
+		})
+
+	if len(entries) != 1 || entries[0].Status != 0 || entries[0].Error == "" {
+		t.Errorf("got %+v", entries)
+	}
+}
+
+func TestRequestLoggerUpgrade(t *testing.T) {
+	entries := logRequests(t, firstEntry(),
+		func(c net.Conn) {
+			io.WriteString(c, "GET /ws HTTP/1.1\r\nHost: a\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+			br := bufio.NewReader(c)
+			if _, err := http.ReadResponse(br, nil); err != nil {
+				t.Error(err)
+				return
+			}
+			// frames that aren't http
+			io.WriteString(c, "GET not http\r\n\r\n")
+			br.ReadString('!')
+		},
+		func(c net.Conn) {
+			br := bufio.NewReader(c)
+			http.ReadRequest(br)
+			io.WriteString(c, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+			br.ReadString('\n')
+			br.ReadString('\n')
+			io.WriteString(c, "HTTP/1.1 500 not http either!")
+		})
+
+	if len(entries) != 1 || entries[0].Status != 101 {
+		t.Errorf("got %+v", entries)
+	}
+}
+
+func TestRequestLoggerUnparseable(t *testing.T) {
+	entries := logRequests(t, firstEntry(),
+		func(c net.Conn) {
+			io.WriteString(c, "not http at all\r\n\r\n")
+			ioutil.ReadAll(c)
+		},
+		func(c net.Conn) {
+			io.WriteString(c, "whatever\r\n")
+		})
+
+	// nothing was logged, the caller logs the connection instead
+	if len(entries) != 0 {
+		t.Errorf("got %+v", entries)
+	}
+}
+
+func TestTapDoesNotBlock(t *testing.T) {
+	tp := newTap()
+	chunk := make([]byte, 64*1024)
+	for i := 0; i < 2*maxTapBuffer/len(chunk); i++ {
+		if n, err := tp.Write(chunk); n != len(chunk) || err != nil {
+			t.Fatalf("write returned %d, %v", n, err)
+		}
+	}
+
+	// nobody read, so the copy gave up
+	if _, err := tp.Read(chunk); err != errTapStopped {
+		t.Errorf("got %v, want errTapStopped", err)
+	}
+}
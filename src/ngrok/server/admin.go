@@ -0,0 +1,258 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type ControlInfo struct {
+	ClientId   string
+	AuthId     string
+	OS         string
+	Arch       string
+	Version    string
+	RemoteAddr string
+	Start      time.Time
+	LastPing   time.Time
+	Tunnels    []*TunnelInfo
+}
+
+type TunnelInfo struct {
+	Url         string
+	Protocol    string
+	Balance     string
+	Priority    int
+	ClientId    string
+	Start       time.Time
+	ActiveConns int64
+	BytesIn     int64
+	BytesOut    int64
+}
+
+func newTunnelInfo(t *Tunnel) *TunnelInfo {
+	return &TunnelInfo{
+		Url:         t.url,
+		Protocol:    t.req.Protocol,
+		Balance:     t.req.Balance,
+		Priority:    t.req.Priority,
+		ClientId:    t.ctl.id,
+		Start:       t.start,
+		ActiveConns: atomic.LoadInt64(&t.activeConns),
+		BytesIn:     atomic.LoadInt64(&t.bytesIn),
+		BytesOut:    atomic.LoadInt64(&t.bytesOut),
+	}
+}
+
+// Snapshot of every tunnel, sorted by url and then by client for pooled tunnels
+func listTunnels() []*TunnelInfo {
+	tunnels := tunnelRegistry.All()
+	infos := make([]*TunnelInfo, 0, len(tunnels))
+	for _, t := range tunnels {
+		infos = append(infos, newTunnelInfo(t))
+	}
+	sort.Sort(tunnelsByUrl(infos))
+	return infos
+}
+
+// Snapshot of every control with its tunnels, sorted by client id.
+// Tunnels are taken from the registry because Control.tunnels is owned
+// by the control's manager goroutine.
+func listControls() []*ControlInfo {
+	byClient := make(map[string][]*TunnelInfo)
+	for _, t := range listTunnels() {
+		byClient[t.ClientId] = append(byClient[t.ClientId], t)
+	}
+
+	controls := controlRegistry.All()
+	infos := make([]*ControlInfo, 0, len(controls))
+	for _, c := range controls {
+		infos = append(infos, &ControlInfo{
+			ClientId:   c.id,
+			AuthId:     userLabel(c),
+			OS:         c.auth.OS,
+			Arch:       c.auth.Arch,
+			Version:    c.auth.MmVersion,
+			RemoteAddr: c.conn.RemoteAddr().String(),
+			Start:      c.start,
+			LastPing:   c.LastPing(),
+			Tunnels:    byClient[c.id],
+		})
+	}
+	sort.Sort(controlsById(infos))
+	return infos
+}
+
+type tunnelsByUrl []*TunnelInfo
+
+func (s tunnelsByUrl) Len() int      { return len(s) }
+func (s tunnelsByUrl) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s tunnelsByUrl) Less(i, j int) bool {
+	if s[i].Url == s[j].Url {
+		return s[i].ClientId < s[j].ClientId
+	}
+	return s[i].Url < s[j].Url
+}
+
+type controlsById []*ControlInfo
+
+func (s controlsById) Len() int           { return len(s) }
+func (s controlsById) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s controlsById) Less(i, j int) bool { return s[i].ClientId < s[j].ClientId }
+
+func killControl(clientId string) error {
+	ctl := controlRegistry.Get(clientId)
+	if ctl == nil {
+		return errors.New("no such control")
+	}
+
+	ctl.conn.Info("Disconnected by admin")
+	ctl.shutdown.Begin()
+	return nil
+}
+
+// Close the tunnels at url. When several clients share the url, clientId
+// picks one of them; empty closes them all.
+func killTunnel(url, clientId string) error {
+	closed := 0
+	for _, t := range tunnelRegistry.Pool(url) {
+		if clientId != "" && t.ctl.id != clientId {
+			continue
+		}
+
+		t.Info("Closed by admin")
+		if err := t.ctl.CloseTunnel(url); err != nil {
+			return err
+		}
+		closed++
+	}
+
+	if closed == 0 {
+		return errors.New("no such tunnel")
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) (int, error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return 500, err
+	}
+	return 200, nil
+}
+
+func apiControls(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
+	return writeJSON(w, listControls())
+}
+
+func apiKillControl(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
+	if err := killControl(mux.Vars(r)["id"]); err != nil {
+		return 404, err
+	}
+	return writeJSON(w, map[string]string{"code": "ok"})
+}
+
+func apiTunnels(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
+	return writeJSON(w, listTunnels())
+}
+
+// the tunnel url has slashes in it, so it's passed as ?url= rather than in the path
+func apiKillTunnel(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
+	q := r.URL.Query()
+	if err := killTunnel(q.Get("url"), q.Get("clientId")); err != nil {
+		return 404, err
+	}
+	return writeJSON(w, map[string]string{"code": "ok"})
+}
+
+func showDashboard(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
+	// the buttons must not be clickable through another site's frame
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTmpl.Execute(w, listControls()); err != nil {
+		return 500, err
+	}
+	return 200, nil
+}
+
+// the dashboard's buttons are plain html forms
+func dashboardKill(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
+	var err error
+	if url := r.FormValue("tunnel"); url != "" {
+		err = killTunnel(url, r.FormValue("control"))
+	} else {
+		err = killControl(r.FormValue("control"))
+	}
+	if err != nil {
+		return 404, err
+	}
+
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	return 303, nil
+}
+
+// read and write wrap a handler so that it requires a token with that scope
+func registerAdminRoutes(router *mux.Router, mgr *ConfigMgr, read, write func(http.Handler) http.Handler) {
+	router.Handle("/api/controls", read(appHandler{mgr, apiControls})).Methods("GET")
+	router.Handle("/api/controls/{id}", write(appHandler{mgr, apiKillControl})).Methods("DELETE")
+	router.Handle("/api/tunnels", read(appHandler{mgr, apiTunnels})).Methods("GET")
+	router.Handle("/api/tunnels", write(appHandler{mgr, apiKillTunnel})).Methods("DELETE")
+	router.Handle("/api/users/{id}/domains", read(appHandler{mgr, apiDomains})).Methods("GET")
+	router.Handle("/api/users/{id}/domains", write(appHandler{mgr, apiAddDomain})).Methods("POST")
+	router.Handle("/api/users/{id}/domains/{domain}/verify", write(appHandler{mgr, apiVerifyDomain})).Methods("POST")
+	router.Handle("/api/users/{id}/domains/{domain}", write(appHandler{mgr, apiRemoveDomain})).Methods("DELETE")
+	router.Handle("/dashboard", read(appHandler{mgr, showDashboard})).Methods("GET")
+	router.Handle("/dashboard/kill", write(appHandler{mgr, dashboardKill})).Methods("POST")
+}
+
+var dashboardTmpl = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"since": func(t time.Time) string { return (time.Since(t) / time.Second * time.Second).String() },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <title>ngrokd</title>
+    <style>
+        body { font-family: sans-serif; margin: 20px; }
+        table { border-collapse: collapse; margin-bottom: 10px; }
+        th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+        form { display: inline; }
+    </style>
+</head>
+<body>
+<h2>ngrokd: {{len .}} connected clients</h2>
+{{range .}}
+<h3>{{.ClientId}} {{if .AuthId}}({{.AuthId}}){{end}}
+    <form method="POST" action="/dashboard/kill">
+        <input type="hidden" name="control" value="{{.ClientId}}">
+        <button>Disconnect</button>
+    </form>
+</h3>
+<p>{{.RemoteAddr}} &middot; {{.OS}}/{{.Arch}} &middot; version {{.Version}} &middot;
+   connected {{since .Start}} ago &middot; last ping {{since .LastPing}} ago</p>
+<table>
+    <tr><th>Url</th><th>Protocol</th><th>Open for</th><th>Active conns</th><th>Bytes in</th><th>Bytes out</th><th></th></tr>
+    {{range .Tunnels}}
+    <tr>
+        <td>{{.Url}}</td><td>{{.Protocol}}</td><td>{{since .Start}}</td>
+        <td>{{.ActiveConns}}</td><td>{{.BytesIn}}</td><td>{{.BytesOut}}</td>
+        <td>
+            <form method="POST" action="/dashboard/kill">
+                <input type="hidden" name="tunnel" value="{{.Url}}">
+                <input type="hidden" name="control" value="{{.ClientId}}">
+                <button>Close</button>
+            </form>
+        </td>
+    </tr>
+    {{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
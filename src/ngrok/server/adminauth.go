@@ -0,0 +1,248 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"ngrok/log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	adminScopeRead  = "read"
+	adminScopeWrite = "write"
+)
+
+var adminAuth *AdminAuth
+
+// An API token for the admin server. Tokens with the write scope
+// may also do everything a read token can.
+type AdminToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Scope string `json:"scope"`
+}
+
+func (t *AdminToken) Allows(scope string) bool {
+	return t.Scope == adminScopeWrite || t.Scope == scope
+}
+
+type AdminAuth struct {
+	log.Logger
+	tokens []*AdminToken
+
+	// audit log, one JSON object per line
+	auditMu sync.Mutex
+	audit   io.Writer
+}
+
+// Load admin tokens from a JSON file containing a list of AdminToken.
+// Without a token file, the -pass password, which tunnel clients know as
+// well, is accepted as a read token so that monitoring keeps working, and
+// nothing can be changed. The well known default password isn't accepted.
+func NewAdminAuth(tokenPath, auditPath string) (a *AdminAuth, err error) {
+	a = &AdminAuth{Logger: log.NewPrefixLogger("admin")}
+
+	if tokenPath == "" {
+		switch opts.pass {
+		case "", defaultPass:
+			a.Warn("No admin tokens configured, the admin server accepts nobody until -adminTokens is given")
+		default:
+			a.Warn("No admin tokens configured, the admin server accepts the -pass password for reading only")
+			a.tokens = []*AdminToken{{Name: "pass", Token: opts.pass, Scope: adminScopeRead}}
+		}
+	} else {
+		var buf []byte
+		if buf, err = ioutil.ReadFile(tokenPath); err != nil {
+			return
+		}
+
+		if err = json.Unmarshal(buf, &a.tokens); err != nil {
+			err = fmt.Errorf("Failed to parse admin tokens %s: %v", tokenPath, err)
+			return
+		}
+
+		for _, t := range a.tokens {
+			if t.Token == "" {
+				err = fmt.Errorf("Admin token %s has no token value", t.Name)
+				return
+			}
+
+			if t.Scope != adminScopeRead && t.Scope != adminScopeWrite {
+				err = fmt.Errorf("Admin token %s has invalid scope '%s', must be %s or %s", t.Name, t.Scope, adminScopeRead, adminScopeWrite)
+				return
+			}
+		}
+		a.Info("Loaded %d admin tokens", len(a.tokens))
+	}
+
+	switch auditPath {
+	case "":
+	case "stdout":
+		a.audit = os.Stdout
+	default:
+		if a.audit, err = os.OpenFile(auditPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Pull the credential out of a request. API clients send a bearer token,
+// browsers use the token as the basic auth password, and older scripts
+// still send it in the Auth header.
+func requestCredential(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(h[len("Bearer "):])
+	}
+
+	if _, pass, ok := r.BasicAuth(); ok {
+		return pass
+	}
+
+	return r.Header.Get("Auth")
+}
+
+// Browsers send basic auth credentials along with requests that any site
+// makes them send, so a change made with them has to come from a page of
+// the admin server itself: its Origin, or Referer if there's none, must be
+// the admin server.
+func forgeable(r *http.Request) bool {
+	if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		return false
+	}
+	if _, _, ok := r.BasicAuth(); !ok {
+		return false
+	}
+
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	u, err := url.Parse(source)
+	return source == "" || err != nil || u.Scheme != scheme || !strings.EqualFold(u.Host, r.Host)
+}
+
+// Returns the token matching the request's credential, or nil. Every
+// token is compared in constant time so timing doesn't leak which
+// prefix matched.
+func (a *AdminAuth) Authenticate(r *http.Request) (match *AdminToken) {
+	cred := []byte(requestCredential(r))
+	if len(cred) == 0 {
+		return nil
+	}
+
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(cred, []byte(t.Token)) == 1 {
+			match = t
+		}
+	}
+	return
+}
+
+type adminAuditEntry struct {
+	Time       time.Time  `json:"time"`
+	Token      string     `json:"token"`
+	RemoteAddr string     `json:"remoteAddr"`
+	Method     string     `json:"method"`
+	Path       string     `json:"path"`
+	Form       url.Values `json:"form,omitempty"`
+	Status     int        `json:"status"`
+}
+
+func (a *AdminAuth) Audit(e *adminAuditEntry) {
+	if a.audit == nil {
+		a.Info("%s %s %s by %s from %s: %d", e.Method, e.Path, e.Form, e.Token, e.RemoteAddr, e.Status)
+		return
+	}
+
+	buf, err := json.Marshal(e)
+	if err != nil {
+		a.Error("Failed to serialize audit entry: %v", err)
+		return
+	}
+
+	a.auditMu.Lock()
+	defer a.auditMu.Unlock()
+	a.audit.Write(append(buf, '\n'))
+}
+
+// records the status code written by a handler for the audit log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Wrap an admin handler so that it requires a token with the given scope.
+// Requests that change state and all failed attempts are audited.
+func (a *AdminAuth) Require(scope string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := &adminAuditEntry{
+			Time:       time.Now(),
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Path:       r.URL.RequestURI(),
+		}
+
+		token := a.Authenticate(r)
+		switch {
+		case token == nil:
+			w.Header().Set("WWW-Authenticate", `Basic realm="ngrokd admin"`)
+			http.Error(w, http.StatusText(401), 401)
+			entry.Status = 401
+			a.Audit(entry)
+			return
+
+		case !token.Allows(scope):
+			http.Error(w, http.StatusText(403), 403)
+			entry.Token = token.Name
+			entry.Status = 403
+			a.Audit(entry)
+			return
+
+		case scope == adminScopeWrite && forgeable(r):
+			http.Error(w, "Changes made from a browser must come from the admin server's own pages", 403)
+			entry.Token = token.Name
+			entry.Status = 403
+			a.Audit(entry)
+			return
+		}
+
+		if scope != adminScopeWrite {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// capture the form so the audit log shows what was acted on
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			r.ParseForm()
+			entry.Form = r.PostForm
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: 200}
+		h.ServeHTTP(rec, r)
+
+		entry.Token = token.Name
+		entry.Status = rec.status
+		a.Audit(entry)
+	})
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"ngrok/log"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func withPass(t *testing.T, pass string) {
+	oldOpts := opts
+	t.Cleanup(func() { opts = oldOpts })
+	opts = &Options{pass: pass}
+}
+
+func TestAdminTokenScopes(t *testing.T) {
+	a := &AdminAuth{
+		Logger: log.NewPrefixLogger("admin"),
+		tokens: []*AdminToken{
+			{Name: "monitoring", Token: "reader", Scope: adminScopeRead},
+			{Name: "ops", Token: "writer", Scope: adminScopeWrite},
+		},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handlers := map[string]http.Handler{
+		adminScopeRead:  a.Require(adminScopeRead, ok),
+		adminScopeWrite: a.Require(adminScopeWrite, ok),
+	}
+
+	cases := []struct {
+		scope  string
+		token  string
+		status int
+	}{
+		{adminScopeRead, "", 401},
+		{adminScopeRead, "wrong", 401},
+		{adminScopeRead, "reader", 200},
+		{adminScopeRead, "writer", 200},
+		{adminScopeWrite, "", 401},
+		{adminScopeWrite, "reader", 403},
+		{adminScopeWrite, "writer", 200},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("POST", "http://admin.example.com:4446/api/users", nil)
+		if c.token != "" {
+			r.Header.Set("Authorization", "Bearer "+c.token)
+		}
+
+		w := httptest.NewRecorder()
+		handlers[c.scope].ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s with token %q: got status %d, want %d", c.scope, c.token, w.Code, c.status)
+		}
+	}
+}
+
+func TestAdminCredentials(t *testing.T) {
+	a := &AdminAuth{tokens: []*AdminToken{{Name: "ops", Token: "secret", Scope: adminScopeRead}}}
+
+	bearer := httptest.NewRequest("GET", "/", nil)
+	bearer.Header.Set("Authorization", "Bearer secret")
+	basic := httptest.NewRequest("GET", "/", nil)
+	basic.SetBasicAuth("anyone", "secret")
+	legacy := httptest.NewRequest("GET", "/", nil)
+	legacy.Header.Set("Auth", "secret")
+	prefix := httptest.NewRequest("GET", "/", nil)
+	prefix.Header.Set("Authorization", "Bearer secre")
+
+	for name, r := range map[string]*http.Request{"bearer": bearer, "basic": basic, "auth header": legacy} {
+		if token := a.Authenticate(r); token == nil || token.Name != "ops" {
+			t.Errorf("%s credential didn't authenticate", name)
+		}
+	}
+	if a.Authenticate(prefix) != nil {
+		t.Error("a prefix of the token authenticated")
+	}
+}
+
+func TestNewAdminAuthTokenFile(t *testing.T) {
+	withPass(t, "")
+	dir := t.TempDir()
+
+	load := func(content string) (*AdminAuth, error) {
+		path := filepath.Join(dir, "tokens.json")
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return NewAdminAuth(path, "")
+	}
+
+	a, err := load(`[{"name": "monitoring", "token": "r", "scope": "read"}, {"name": "ops", "token": "w", "scope": "write"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.tokens) != 2 || a.tokens[0].Name != "monitoring" || a.tokens[1].Scope != adminScopeWrite {
+		t.Errorf("got tokens %+v", a.tokens)
+	}
+
+	bad := map[string]string{
+		"not json":      `{"name": "ops"`,
+		"not a list":    `{"name": "ops", "token": "w", "scope": "write"}`,
+		"empty token":   `[{"name": "ops", "token": "", "scope": "write"}]`,
+		"unknown scope": `[{"name": "ops", "token": "w", "scope": "admin"}]`,
+		"missing scope": `[{"name": "ops", "token": "w"}]`,
+	}
+	for name, content := range bad {
+		if _, err := load(content); err == nil {
+			t.Errorf("%s: loaded without an error", name)
+		}
+	}
+
+	if _, err := NewAdminAuth(filepath.Join(dir, "missing.json"), ""); err == nil {
+		t.Error("a missing token file loaded without an error")
+	}
+}
+
+func TestNewAdminAuthWithoutTokens(t *testing.T) {
+	for _, pass := range []string{"", defaultPass} {
+		withPass(t, pass)
+		a, err := NewAdminAuth("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(a.tokens) != 0 {
+			t.Errorf("-pass=%q was accepted as a token", pass)
+		}
+	}
+
+	// a password of one's own reads, but can't change anything
+	withPass(t, "hunter2")
+	a, err := NewAdminAuth("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.tokens) != 1 || a.tokens[0].Token != "hunter2" || a.tokens[0].Allows(adminScopeWrite) {
+		t.Errorf("got tokens %+v", a.tokens)
+	}
+}
+
+func TestAdminPassword(t *testing.T) {
+	for _, pass := range []string{"", defaultPass} {
+		withPass(t, pass)
+		if adminPassword(pass) {
+			t.Errorf("-pass=%q gives clients admin rights", pass)
+		}
+	}
+
+	withPass(t, "hunter2")
+	if !adminPassword("hunter2") || adminPassword("hunter") || adminPassword("") {
+		t.Error("only the -pass password gives admin rights")
+	}
+}
+
+func TestAdminWriteFromBrowser(t *testing.T) {
+	a := &AdminAuth{
+		Logger: log.NewPrefixLogger("admin"),
+		tokens: []*AdminToken{{Name: "ops", Token: "secret", Scope: adminScopeWrite}},
+	}
+
+	changed := 0
+	h := a.Require(adminScopeWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		changed++
+	}))
+
+	cases := []struct {
+		name    string
+		bearer  bool
+		headers map[string]string
+		status  int
+	}{
+		{"bearer token", true, nil, 200},
+		{"bearer token from another site", true, map[string]string{"Origin": "http://evil.example.com"}, 200},
+		{"dashboard form", false, map[string]string{"Origin": "http://admin.example.com:4446"}, 200},
+		{"dashboard form without origin", false, map[string]string{"Referer": "http://admin.example.com:4446/dashboard"}, 200},
+		{"form on another site", false, map[string]string{"Origin": "http://evil.example.com"}, 403},
+		{"form on another port", false, map[string]string{"Origin": "http://admin.example.com:8080"}, 403},
+		{"form from a sandboxed frame", false, map[string]string{"Origin": "null"}, 403},
+		{"link on another site", false, map[string]string{"Referer": "http://evil.example.com/admin.example.com:4446"}, 403},
+		{"basic auth without origin", false, nil, 403},
+	}
+
+	for _, c := range cases {
+		changed = 0
+		r := httptest.NewRequest("POST", "http://admin.example.com:4446/dashboard/kill", strings.NewReader("control=x"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if c.bearer {
+			r.Header.Set("Authorization", "Bearer secret")
+		} else {
+			r.SetBasicAuth("", "secret")
+		}
+		for name, value := range c.headers {
+			r.Header.Set(name, value)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != c.status || (changed == 1) != (c.status == 200) {
+			t.Errorf("%s: got status %d and %d changes, want %d", c.name, w.Code, changed, c.status)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"flag"
+	"os"
+	"time"
 )
 
 type Options struct {
@@ -14,6 +16,71 @@ type Options struct {
 	tlsKey     string
 	logto      string
 	loglevel   string
+
+	// admin server
+	adminAddr   string
+	adminTokens string
+	adminAudit  string
+	adminTls    bool
+	adminTlsCrt string
+	adminTlsKey string
+
+	// access log target, empty to disable
+	accessLog       string
+	accessLogFormat string
+
+	// lifecycle event hooks, empty to disable
+	eventHooks string
+
+	// cluster membership, disabled when the backend is empty
+	clusterBackend   string
+	clusterNode      string
+	clusterAddr      string
+	clusterAdvertise string
+	clusterSecret    string
+
+	// how users may prove they own a custom domain: dns, http or both
+	domainChallenges string
+
+	// how long a random url stays reserved for its user after its last use
+	reservationTtl time.Duration
+
+	// how long a shutdown waits for public connections to finish
+	drainTimeout time.Duration
+
+	// how long a client may go without pinging before its control connection
+	// is dropped, and how often that is checked
+	pingTimeout  time.Duration
+	reapInterval time.Duration
+
+	// error page templates overriding the built-in ones, and who users
+	// should contact about errors
+	errorPages     string
+	supportContact string
+
+	// OpenID Connect provider for tunnels that require a login, disabled
+	// when the issuer is empty
+	oidcIssuer       string
+	oidcClientId     string
+	oidcClientSecret string
+	oidcCookieSecret string
+	oidcSessionTtl   time.Duration
+
+	// metrics sinks, each is disabled when its address is empty
+	statsdAddr      string
+	statsdPrefix    string
+	statsdTags      bool
+	influxUrl       string
+	metricsWebhook  string
+	metricsInterval time.Duration
+}
+
+// the -pass password unless another one is given
+const defaultPass = "xxxx"
+
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
 }
 
 func parseArgs() *Options {
@@ -21,11 +88,43 @@ func parseArgs() *Options {
 	httpsAddr := flag.String("httpsAddr", ":443", "Public address listening for HTTPS connections, emptry string to disable")
 	tunnelAddr := flag.String("tunnelAddr", ":4443", "Public address listening for ngrok client")
 	domain := flag.String("domain", "ngrok.com", "Domain where the tunnels are hosted")
-	pass := flag.String("pass", "xxxx", "Set password hear")
+	pass := flag.String("pass", defaultPass, "Password that gives ngrok clients admin rights to any tunnel, empty string or the default to disable")
 	tlsCrt := flag.String("tlsCrt", "", "Path to a TLS certificate file")
 	tlsKey := flag.String("tlsKey", "", "Path to a TLS key file")
 	logto := flag.String("log", "stdout", "Write log messages to this file. 'stdout' and 'none' have special meanings")
 	loglevel := flag.String("log-level", "DEBUG", "The level of messages to log. One of: DEBUG, INFO, WARNING, ERROR")
+	adminAddr := flag.String("adminAddr", ":4446", "Address for the admin server")
+	adminTokens := flag.String("adminTokens", "", "JSON file of admin API tokens: [{\"name\": ..., \"token\": ..., \"scope\": \"read\" or \"write\"}]. When empty the admin server accepts -pass")
+	adminAudit := flag.String("adminAudit", "", "Write an audit log of admin actions to this file, 'stdout' has special meaning. When empty they are written to the log")
+	adminTls := flag.Bool("adminTls", false, "Serve the admin server over TLS")
+	adminTlsCrt := flag.String("adminTlsCrt", "", "Path to a TLS certificate file for the admin server (default: -tlsCrt)")
+	adminTlsKey := flag.String("adminTlsKey", "", "Path to a TLS key file for the admin server (default: -tlsKey)")
+	accessLog := flag.String("accessLog", "", "Write an access log of public connections to this file, 'stdout' has special meaning, empty string to disable")
+	accessLogFormat := flag.String("accessLogFormat", "json", "Format of the access log. One of: json, combined")
+	eventHooks := flag.String("eventHooks", "", "JSON file of webhooks for tunnel and client lifecycle events: [{\"url\": ..., \"secret\": ..., \"events\": [...]}], empty string to disable")
+	clusterBackend := flag.String("clusterBackend", "", "Run as a cluster node coordinating through this backend: a redis:// url, or 'memory' for a single node. Empty string to disable")
+	clusterNode := flag.String("clusterNode", hostname(), "Name of this node in the cluster")
+	clusterAddr := flag.String("clusterAddr", ":4447", "Address to listen on for connections relayed by other cluster nodes")
+	clusterAdvertise := flag.String("clusterAdvertise", "", "Address other cluster nodes reach -clusterAddr on (default: the node name and -clusterAddr port)")
+	clusterSecret := flag.String("clusterSecret", "", "Secret shared by all nodes of the cluster, required with -clusterBackend")
+	domainChallenges := flag.String("domainChallenges", "dns,http", "How users may prove they own a custom hostname: dns, http or both separated by a comma. Empty string to only allow hostnames in a user's dns list")
+	reservationTtl := flag.Duration("reservationTtl", 30*24*time.Hour, "How long a random url or port stays reserved for its user after it was last used, 0 to disable")
+	drainTimeout := flag.Duration("drainTimeout", 30*time.Second, "On SIGTERM, how long to wait for open public connections to finish before exiting")
+	pingTimeout := flag.Duration("pingTimeout", 30*time.Second, "Drop the control connection of a client that hasn't sent a heartbeat for this long, longer than the clients' heartbeat.ping_interval")
+	reapInterval := flag.Duration("reapInterval", 10*time.Second, "How often control connections are checked for missed heartbeats")
+	errorPages := flag.String("errorPages", "", "Directory of error page templates overriding the built-in ones: 400.html, 401.html, 403.html, 404.html or error.html for any status. Empty string to use the built-in pages")
+	supportContact := flag.String("supportContact", "", "Contact shown on error pages, e.g. an email address")
+	oidcIssuer := flag.String("oidcIssuer", "", "OpenID Connect issuer URL visitors of tunnels that require a login log in with, empty string to disable")
+	oidcClientId := flag.String("oidcClientId", "", "Client id of ngrokd at the OpenID Connect issuer")
+	oidcClientSecret := flag.String("oidcClientSecret", "", "Client secret of ngrokd at the OpenID Connect issuer")
+	oidcCookieSecret := flag.String("oidcCookieSecret", "", "Secret login session cookies are signed with, the same on every node of a cluster (default: random at startup)")
+	oidcSessionTtl := flag.Duration("oidcSessionTtl", 12*time.Hour, "How long a login to a tunnel lasts")
+	statsdAddr := flag.String("statsdAddr", "", "Send StatsD metrics over UDP to this address, empty string to disable")
+	statsdPrefix := flag.String("statsdPrefix", "ngrokd.", "Prefix for StatsD metric names")
+	statsdTags := flag.Bool("statsdTags", false, "Send DogStatsD tags instead of encoding them in StatsD metric names")
+	influxUrl := flag.String("influxUrl", "", "InfluxDB write URL for metrics (e.g. http://localhost:8086/write?db=ngrokd), empty string to disable")
+	metricsWebhook := flag.String("metricsWebhook", "", "POST batches of JSON metric events to this URL, empty string to disable")
+	metricsInterval := flag.Duration("metricsInterval", 10*time.Second, "How often batched metrics are sent to InfluxDB and the metrics webhook")
 	flag.Parse()
 
 	return &Options{
@@ -38,5 +137,46 @@ func parseArgs() *Options {
 		tlsKey:     *tlsKey,
 		logto:      *logto,
 		loglevel:   *loglevel,
+
+		adminAddr:   *adminAddr,
+		adminTokens: *adminTokens,
+		adminAudit:  *adminAudit,
+		adminTls:    *adminTls,
+		adminTlsCrt: *adminTlsCrt,
+		adminTlsKey: *adminTlsKey,
+
+		accessLog:       *accessLog,
+		accessLogFormat: *accessLogFormat,
+
+		eventHooks: *eventHooks,
+
+		clusterBackend:   *clusterBackend,
+		clusterNode:      *clusterNode,
+		clusterAddr:      *clusterAddr,
+		clusterAdvertise: *clusterAdvertise,
+		clusterSecret:    *clusterSecret,
+
+		domainChallenges: *domainChallenges,
+		reservationTtl:   *reservationTtl,
+		drainTimeout:     *drainTimeout,
+
+		pingTimeout:  *pingTimeout,
+		reapInterval: *reapInterval,
+
+		errorPages:     *errorPages,
+		supportContact: *supportContact,
+
+		oidcIssuer:       *oidcIssuer,
+		oidcClientId:     *oidcClientId,
+		oidcClientSecret: *oidcClientSecret,
+		oidcCookieSecret: *oidcCookieSecret,
+		oidcSessionTtl:   *oidcSessionTtl,
+
+		statsdAddr:      *statsdAddr,
+		statsdPrefix:    *statsdPrefix,
+		statsdTags:      *statsdTags,
+		influxUrl:       *influxUrl,
+		metricsWebhook:  *metricsWebhook,
+		metricsInterval: *metricsInterval,
 	}
 }
@@ -0,0 +1,449 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ngrok/conn"
+	"ngrok/log"
+	"ngrok/msg"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	clusterClaimTTL     = 30 * time.Second
+	clusterRefresh      = 10 * time.Second
+	clusterSyncInterval = 30 * time.Second
+
+	clusterNodeKey    = "ngrokd:node:"
+	clusterTunnelKey  = "ngrokd:tunnel:"
+	clusterControlKey = "ngrokd:control:"
+	clusterUsersKey   = "ngrokd:users"
+
+	clusterForwardHttp  = "http"
+	clusterForwardProxy = "proxy"
+)
+
+// nil unless ngrokd runs as a node of a cluster
+var cluster *Cluster
+
+/**
+ * Cluster: lets several ngrokd nodes serve one domain. Each node claims the
+ *          tunnel urls and client ids it serves in the shared backend. Public
+ *          connections and proxy connections that land on the wrong node are
+ *          relayed to the owner over a TLS link between the nodes.
+ */
+type Cluster struct {
+	log.Logger
+	backend ClusterBackend
+
+	// name of this node and the address other nodes reach its peer listener on
+	node      string
+	advertise string
+	secret    string
+
+	// peer links are TLS with the tunnel certificate on both ends: a peer must
+	// present the same certificate as this node
+	tlsConfig  *tls.Config
+	clientTls  *tls.Config
+	serverCert []byte
+
+	// keys this node holds, with what to do if another node takes one over
+	mu     sync.Mutex
+	claims map[string]func()
+}
+
+func NewCluster(backend ClusterBackend, node, advertise, secret string, tlsConfig *tls.Config) (*Cluster, error) {
+	// peers are only authenticated by the certificate and the secret
+	if secret == "" {
+		return nil, errors.New("A cluster needs a -clusterSecret shared by its nodes")
+	}
+	if isDefaultCertificate(tlsConfig) {
+		return nil, errors.New("A cluster needs its own -tlsCrt and -tlsKey, the key of the default certificate is public")
+	}
+
+	c := &Cluster{
+		Logger:    log.NewPrefixLogger("cluster", node),
+		backend:   backend,
+		node:      node,
+		advertise: advertise,
+		secret:    secret,
+		claims:    make(map[string]func()),
+	}
+
+	c.serverCert = tlsConfig.Certificates[0].Certificate[0]
+	c.tlsConfig = &tls.Config{
+		Certificates:          tlsConfig.Certificates,
+		ClientAuth:            tls.RequireAnyClientCert,
+		VerifyPeerCertificate: c.verifyPeer,
+	}
+	c.clientTls = &tls.Config{
+		Certificates:          tlsConfig.Certificates,
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: c.verifyPeer,
+	}
+	return c, nil
+}
+
+func (c *Cluster) verifyPeer(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], c.serverCert) {
+		return errors.New("peer did not present the cluster certificate")
+	}
+	return nil
+}
+
+// Announce this node and keep its claims alive
+func (c *Cluster) Run() {
+	c.Info("Joining cluster, peers reach this node at %s", c.advertise)
+
+	for {
+		if err := c.backend.Set(clusterNodeKey+c.node, c.advertise, clusterClaimTTL); err != nil {
+			c.Error("Failed to announce node: %v", err)
+		}
+
+		c.refreshClaims()
+		time.Sleep(clusterRefresh)
+	}
+}
+
+func (c *Cluster) refreshClaims() {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.claims))
+	for key := range c.claims {
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+
+	for _, key := range keys {
+		ok, err := c.backend.Refresh(key, c.node, clusterClaimTTL)
+		if err != nil {
+			c.Error("Failed to refresh %s: %v", key, err)
+			continue
+		}
+
+		if !ok {
+			c.mu.Lock()
+			onLost, held := c.claims[key]
+			delete(c.claims, key)
+			c.mu.Unlock()
+
+			if held {
+				c.Warn("Lost %s to another node", key)
+				onLost()
+			}
+		}
+	}
+}
+
+// Claim a tunnel url for this node, failing if another node serves it.
+// onLost is called if another node takes the url over later on.
+func (c *Cluster) ClaimTunnel(url string, onLost func()) error {
+	if c == nil {
+		return nil
+	}
+
+	key := clusterTunnelKey + url
+	owner, err := c.backend.SetNX(key, c.node, clusterClaimTTL)
+	if err != nil {
+		return fmt.Errorf("Failed to claim %s in the cluster: %v", url, err)
+	}
+
+	if owner != c.node {
+		return fmt.Errorf("The tunnel %s is already registered on node %s.", url, owner)
+	}
+
+	c.hold(key, onLost)
+	return nil
+}
+
+// Claim a client id for this node. Like a reconnect to the same node, the
+// newest control wins and the node that held the id before shuts its control down.
+func (c *Cluster) ClaimControl(clientId string, ctl *Control) {
+	if c == nil {
+		return
+	}
+
+	key := clusterControlKey + clientId
+	if err := c.backend.Set(key, c.node, clusterClaimTTL); err != nil {
+		c.Error("Failed to claim client %s: %v", clientId, err)
+		return
+	}
+
+	c.hold(key, func() {
+		ctl.conn.Info("Client reconnected to another node")
+		ctl.shutdown.Begin()
+	})
+}
+
+func (c *Cluster) hold(key string, onLost func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.claims[key] = onLost
+}
+
+func (c *Cluster) ReleaseTunnel(url string) {
+	c.release(clusterTunnelKey + url)
+}
+
+func (c *Cluster) ReleaseControl(clientId string) {
+	c.release(clusterControlKey + clientId)
+}
+
+func (c *Cluster) release(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	_, held := c.claims[key]
+	delete(c.claims, key)
+	c.mu.Unlock()
+
+	if held {
+		if err := c.backend.Delete(key, c.node); err != nil {
+			c.Error("Failed to release %s: %v", key, err)
+		}
+	}
+}
+
+// Returns the peer address of the node holding key, or the empty
+// string if it's unclaimed or held by this node
+func (c *Cluster) peerFor(key string) (addr string, err error) {
+	owner, err := c.backend.Get(key)
+	if err != nil || owner == "" || owner == c.node {
+		return
+	}
+
+	if addr, err = c.backend.Get(clusterNodeKey + owner); err == nil && addr == "" {
+		err = fmt.Errorf("node %s owns %s but has left the cluster", owner, key)
+	}
+	return
+}
+
+// Sent first on a peer link to say what the connection is for
+type clusterForward struct {
+	Secret string
+	Kind   string
+
+	// http: the tunnel, its basic auth and what the first node knows about the request
+	Url     string
+	Auth    string
+	Accept  string
+	Cookies string
+	Entry   *AccessLogEntry
+
+	// proxy: the client the proxy connection belongs to
+	ClientId string
+}
+
+func (c *Cluster) dialPeer(addr string, fwd *clusterForward) (peer conn.Conn, err error) {
+	if peer, err = conn.Dial(addr, "peer", c.clientTls); err != nil {
+		return
+	}
+
+	fwd.Secret = c.secret
+	if err = msg.WriteMsg(peer, fwd); err != nil {
+		peer.Close()
+	}
+	return
+}
+
+// Relay a public http connection to the node that serves url, or the
+// longest wildcard and path prefix matching it. Returns false if no other
+// node does, so the caller should handle it.
+func (c *Cluster) ForwardHttp(publicUrl string, public conn.Conn, auth, accept, cookies string, entry *AccessLogEntry) bool {
+	if c == nil {
+		return false
+	}
+
+	var addr, url string
+	for _, url = range routeUrls(publicUrl, entry.Path) {
+		var err error
+		if addr, err = c.peerFor(clusterTunnelKey + url); err != nil {
+			public.Warn("Failed to look up %s in the cluster: %v", url, err)
+			return false
+		} else if addr != "" {
+			break
+		}
+	}
+	if addr == "" {
+		return false
+	}
+
+	peer, err := c.dialPeer(addr, &clusterForward{Kind: clusterForwardHttp, Url: url, Auth: auth, Accept: accept, Cookies: cookies, Entry: entry})
+	if err != nil {
+		public.Warn("Failed to forward to %s: %v", addr, err)
+		return false
+	}
+
+	public.Info("Forwarding to node at %s", addr)
+	defer trackPublicConn()()
+	public.SetDeadline(time.Time{})
+	conn.Join(public, peer)
+	return true
+}
+
+// Relay a proxy connection to the node its client's control is on. Returns
+// false if no other node has the client.
+func (c *Cluster) ForwardProxy(pxy conn.Conn, clientId string) bool {
+	if c == nil {
+		return false
+	}
+
+	addr, err := c.peerFor(clusterControlKey + clientId)
+	if err != nil {
+		pxy.Warn("Failed to look up client %s in the cluster: %v", clientId, err)
+		return false
+	} else if addr == "" {
+		return false
+	}
+
+	peer, err := c.dialPeer(addr, &clusterForward{Kind: clusterForwardProxy, ClientId: clientId})
+	if err != nil {
+		pxy.Warn("Failed to forward to %s: %v", addr, err)
+		return false
+	}
+
+	pxy.Info("Forwarding proxy connection to node at %s", addr)
+	go conn.Join(pxy, peer)
+	return true
+}
+
+// Accept connections relayed by other nodes
+func (c *Cluster) ListenPeers(addr string) {
+	l, err := listen("peer", addr)
+	if err != nil {
+		panic(err)
+	}
+	listener := conn.NewListener(l, "peer", c.tlsConfig)
+	bind("peer", listener, l)
+
+	c.Info("Listening for peer connections on %s", listener.Addr.String())
+	for peer := range listener.Conns {
+		go c.handlePeer(peer)
+	}
+}
+
+func (c *Cluster) handlePeer(peer conn.Conn) {
+	defer func() {
+		if r := recover(); r != nil {
+			peer.Warn("handlePeer failed with error %v: %s", r, debug.Stack())
+			peer.Close()
+		}
+	}()
+
+	peer.SetReadDeadline(time.Now().Add(connReadTimeout))
+	var fwd clusterForward
+	if err := msg.ReadMsgInto(peer, &fwd); err != nil {
+		peer.Warn("Failed to read forward message: %v", err)
+		peer.Close()
+		return
+	}
+	peer.SetReadDeadline(time.Time{})
+
+	if c.secret == "" || subtle.ConstantTimeCompare([]byte(fwd.Secret), []byte(c.secret)) != 1 {
+		peer.Warn("Peer sent the wrong cluster secret")
+		peer.Close()
+		return
+	}
+
+	switch fwd.Kind {
+	case clusterForwardHttp:
+		defer peer.Close()
+
+		entry := fwd.Entry
+		if entry == nil {
+			entry = newAccessLogEntry(peer)
+		}
+
+		hostUrl, _ := splitRouteUrl(fwd.Url)
+		tunnel, routed := tunnelRegistry.Route(hostUrl, entry.Path)
+		if tunnel == nil {
+			peer.Info("No tunnel found for %s", fwd.Url)
+			peer.Write(errorResponse(nil, 404, fmt.Sprintf("Tunnel %s not found", fwd.Url), entry, fwd.Accept))
+			return
+		}
+
+		if !tunnel.authorized(fwd.Auth) {
+			peer.Info("Authentication failed: %s", fwd.Auth)
+			peer.Write(errorResponse(tunnel, 401, "Authorization required", entry, fwd.Accept))
+			return
+		}
+
+		if tunnel.challengeOnly(entry.Path) {
+			peer.Info("Hostname %s is not verified", tunnel.unverified)
+			peer.Write(errorResponse(tunnel, 403, fmt.Sprintf("Hostname %s not verified", tunnel.unverified), entry, fwd.Accept))
+			return
+		}
+
+		var identity map[string]string
+		if tunnel.req.OidcAuth {
+			proto := strings.SplitN(hostUrl, "://", 2)[0]
+			id, _, resp := oidcGate.Check(tunnel, &gateRequest{proto, entry.Host, entry.Path, fwd.Cookies, fwd.Accept}, entry)
+			if id == nil {
+				peer.Write(resp)
+				return
+			}
+			identity = id.headers()
+		}
+
+		var public conn.Conn = peer
+		if routed || identity != nil {
+			var err error
+			if public, err = rewriteRequest(peer, tunnel, identity); err != nil {
+				peer.Warn("Failed to rewrite request: %v", err)
+				peer.Write(errorResponse(tunnel, 400, "The request could not be parsed", entry, fwd.Accept))
+				return
+			}
+		}
+
+		tunnel.HandlePublicConnection(public, entry)
+
+	case clusterForwardProxy:
+		NewProxy(peer, &msg.RegProxy{ClientId: fwd.ClientId})
+
+	default:
+		peer.Warn("Unknown forward kind %s", fwd.Kind)
+		peer.Close()
+	}
+}
+
+/**
+ * ClusterDb: keeps the user database in the cluster backend so that every
+ *            node knows every user
+ */
+type ClusterDb struct {
+	backend ClusterBackend
+}
+
+func (db *ClusterDb) Save(mgr *ConfigMgr, uc *UserConfig) error {
+	b, err := json.Marshal(uc)
+	if err != nil {
+		return err
+	}
+	return db.backend.HSet(clusterUsersKey, uc.AuthId, string(b))
+}
+
+// Loads users added or changed on other nodes as well as at startup
+func (db *ClusterDb) LoadAll(mgr *ConfigMgr) error {
+	all, err := db.backend.HGetAll(clusterUsersKey)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range all {
+		var uc UserConfig
+		if err := json.Unmarshal([]byte(v), &uc); err != nil {
+			continue
+		}
+		mgr.MergeUserConfig(&uc)
+	}
+	return nil
+}
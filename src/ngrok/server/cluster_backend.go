@@ -0,0 +1,389 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClusterBackend is the shared store the nodes of a cluster coordinate
+// through. Keys with a ttl expire unless they are refreshed, so a node that
+// dies gives up everything it owned.
+type ClusterBackend interface {
+	// Store value at key unless the key already holds a value, returns
+	// the value the key holds afterwards
+	SetNX(key, value string, ttl time.Duration) (string, error)
+
+	// Store value at key, replacing any previous value
+	Set(key, value string, ttl time.Duration) error
+
+	// Extend the ttl of key, returns false if it no longer holds value
+	Refresh(key, value string, ttl time.Duration) (bool, error)
+
+	// Remove key if it still holds value
+	Delete(key, value string) error
+
+	// Returns the empty string if key is not set
+	Get(key string) (string, error)
+
+	HSet(key, field, value string) error
+	HGetAll(key string) (map[string]string, error)
+}
+
+// Create a backend from a url: "memory" or redis://[:password@]host:port[/db]
+func NewClusterBackend(rawUrl string) (ClusterBackend, error) {
+	if rawUrl == "memory" {
+		return NewMemoryBackend(), nil
+	}
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "redis":
+		return NewRedisBackend(u)
+	default:
+		return nil, fmt.Errorf("Unknown cluster backend '%s', must be memory or a redis:// url", rawUrl)
+	}
+}
+
+/**
+ * MemoryBackend: keeps everything in the process. Nodes created in the
+ *                same process can share one, which is mostly useful for tests.
+ */
+type MemoryBackend struct {
+	sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+	hashes  map[string]map[string]string
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		values:  make(map[string]string),
+		expires: make(map[string]time.Time),
+		hashes:  make(map[string]map[string]string),
+	}
+}
+
+// must be called with the lock held
+func (m *MemoryBackend) get(key string) (string, bool) {
+	if exp, ok := m.expires[key]; ok && time.Now().After(exp) {
+		delete(m.values, key)
+		delete(m.expires, key)
+	}
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// must be called with the lock held
+func (m *MemoryBackend) set(key, value string, ttl time.Duration) {
+	m.values[key] = value
+	if ttl > 0 {
+		m.expires[key] = time.Now().Add(ttl)
+	} else {
+		delete(m.expires, key)
+	}
+}
+
+func (m *MemoryBackend) SetNX(key, value string, ttl time.Duration) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if v, ok := m.get(key); ok {
+		return v, nil
+	}
+	m.set(key, value, ttl)
+	return value, nil
+}
+
+func (m *MemoryBackend) Set(key, value string, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	m.set(key, value, ttl)
+	return nil
+}
+
+func (m *MemoryBackend) Refresh(key, value string, ttl time.Duration) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if v, ok := m.get(key); !ok || v != value {
+		return false, nil
+	}
+	m.set(key, value, ttl)
+	return true, nil
+}
+
+func (m *MemoryBackend) Delete(key, value string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if v, ok := m.get(key); ok && v == value {
+		delete(m.values, key)
+		delete(m.expires, key)
+	}
+	return nil
+}
+
+func (m *MemoryBackend) Get(key string) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	v, _ := m.get(key)
+	return v, nil
+}
+
+func (m *MemoryBackend) HSet(key, field, value string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.hashes[key] == nil {
+		m.hashes[key] = make(map[string]string)
+	}
+	m.hashes[key][field] = value
+	return nil
+}
+
+func (m *MemoryBackend) HGetAll(key string) (map[string]string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	all := make(map[string]string)
+	for k, v := range m.hashes[key] {
+		all[k] = v
+	}
+	return all, nil
+}
+
+/**
+ * RedisBackend: a minimal client for servers that speak the redis protocol.
+ *               Commands are sent one at a time over a single connection,
+ *               which is redialed after an error.
+ */
+type RedisBackend struct {
+	addr     string
+	password string
+	db       string
+
+	sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+const (
+	redisDialTimeout = 5 * time.Second
+	redisTimeout     = 5 * time.Second
+
+	// only touch the key if it still holds the value we expect
+	redisRefreshScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) end return 0`
+	redisDeleteScript  = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`
+)
+
+func NewRedisBackend(u *url.URL) (*RedisBackend, error) {
+	r := &RedisBackend{addr: u.Host, db: strings.TrimPrefix(u.Path, "/")}
+	if u.User != nil {
+		r.password, _ = u.User.Password()
+	}
+
+	if _, _, err := net.SplitHostPort(r.addr); err != nil {
+		r.addr = net.JoinHostPort(r.addr, "6379")
+	}
+
+	// fail early on a bad address or password
+	if _, err := r.do("PING"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RedisBackend) dial() (err error) {
+	if r.conn, err = net.DialTimeout("tcp", r.addr, redisDialTimeout); err != nil {
+		return
+	}
+	r.rd = bufio.NewReader(r.conn)
+
+	if r.password != "" {
+		if _, err = r.roundTrip("AUTH", r.password); err != nil {
+			return
+		}
+	}
+
+	if r.db != "" && r.db != "0" {
+		if _, err = r.roundTrip("SELECT", r.db); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Send a command and read its reply. Replies are strings, int64s, nil
+// or slices of those.
+func (r *RedisBackend) do(args ...string) (reply interface{}, err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.conn == nil {
+		if err = r.dial(); err != nil {
+			r.reset()
+			return
+		}
+	}
+
+	if reply, err = r.roundTrip(args...); err != nil {
+		// redis errors leave the connection usable, anything else doesn't
+		if _, ok := err.(redisError); !ok {
+			r.reset()
+		}
+	}
+	return
+}
+
+func (r *RedisBackend) reset() {
+	if r.conn != nil {
+		r.conn.Close()
+	}
+	r.conn, r.rd = nil, nil
+}
+
+func (r *RedisBackend) roundTrip(args ...string) (interface{}, error) {
+	r.conn.SetDeadline(time.Now().Add(redisTimeout))
+
+	var buf []byte
+	buf = append(buf, fmt.Sprintf("*%d\r\n", len(args))...)
+	for _, a := range args {
+		buf = append(buf, fmt.Sprintf("$%d\r\n%s\r\n", len(a), a)...)
+	}
+	if _, err := r.conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	return r.readReply()
+}
+
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func (r *RedisBackend) readReply() (interface{}, error) {
+	line, err := r.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+
+	case '-':
+		return nil, redisError(line[1:])
+
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(r.rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = r.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+func (r *RedisBackend) SetNX(key, value string, ttl time.Duration) (string, error) {
+	reply, err := r.do("SET", key, value, "NX", "PX", redisMillis(ttl))
+	if err != nil {
+		return "", err
+	}
+	if reply != nil {
+		return value, nil
+	}
+
+	// somebody else holds it
+	return r.Get(key)
+}
+
+func (r *RedisBackend) Set(key, value string, ttl time.Duration) error {
+	_, err := r.do("SET", key, value, "PX", redisMillis(ttl))
+	return err
+}
+
+func (r *RedisBackend) Refresh(key, value string, ttl time.Duration) (bool, error) {
+	reply, err := r.do("EVAL", redisRefreshScript, "1", key, value, redisMillis(ttl))
+	if err != nil {
+		return false, err
+	}
+	n, _ := reply.(int64)
+	return n == 1, nil
+}
+
+func (r *RedisBackend) Delete(key, value string) error {
+	_, err := r.do("EVAL", redisDeleteScript, "1", key, value)
+	return err
+}
+
+func (r *RedisBackend) Get(key string) (string, error) {
+	reply, err := r.do("GET", key)
+	if err != nil {
+		return "", err
+	}
+	v, _ := reply.(string)
+	return v, nil
+}
+
+func (r *RedisBackend) HSet(key, field, value string) error {
+	_, err := r.do("HSET", key, field, value)
+	return err
+}
+
+func (r *RedisBackend) HGetAll(key string) (map[string]string, error) {
+	reply, err := r.do("HGETALL", key)
+	if err != nil {
+		return nil, err
+	}
+
+	items, _ := reply.([]interface{})
+	all := make(map[string]string)
+	for i := 0; i+1 < len(items); i += 2 {
+		k, _ := items[i].(string)
+		v, _ := items[i+1].(string)
+		all[k] = v
+	}
+	return all, nil
+}
+
+func redisMillis(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Millisecond), 10)
+}
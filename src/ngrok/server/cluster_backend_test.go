@@ -0,0 +1,313 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A stand-in for a redis server with just the commands RedisBackend sends
+type fakeRedis struct {
+	net.Listener
+	password string
+
+	mu       sync.Mutex
+	values   map[string]string
+	ttls     map[string]string
+	hashes   map[string]map[string]string
+	commands [][]string
+	conns    []net.Conn
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeRedis{
+		Listener: l,
+		password: password,
+		values:   make(map[string]string),
+		ttls:     make(map[string]string),
+		hashes:   make(map[string]map[string]string),
+	}
+	t.Cleanup(func() {
+		l.Close()
+		f.dropConnections()
+	})
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns = append(f.conns, c)
+			f.mu.Unlock()
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) url() *url.URL {
+	u := &url.URL{Scheme: "redis", Host: f.Addr().String(), Path: "/2"}
+	if f.password != "" {
+		u.User = url.UserPassword("", f.password)
+	}
+	return u
+}
+
+// Close every client connection, as a restarting server would
+func (f *fakeRedis) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conns {
+		c.Close()
+	}
+	f.conns = nil
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	rd := bufio.NewReader(c)
+	authed := f.password == ""
+
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			if authed = args[1] == f.password; authed {
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		default:
+			reply = f.exec(args)
+		}
+		f.mu.Unlock()
+
+		if _, err = io.WriteString(c, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' {
+		return nil, fmt.Errorf("bad command %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if line, err = rd.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	switch args[0] {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "SET":
+		key, value, opts := args[1], args[2], args[3:]
+		if len(opts) > 0 && opts[0] == "NX" {
+			if _, exists := f.values[key]; exists {
+				return "$-1\r\n"
+			}
+			opts = opts[1:]
+		}
+		f.values[key] = value
+		if len(opts) == 2 && opts[0] == "PX" {
+			f.ttls[key] = opts[1]
+		}
+		return "+OK\r\n"
+	case "GET":
+		if v, ok := f.values[args[1]]; ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "EVAL":
+		key, value := args[3], args[4]
+		if f.values[key] != value {
+			return ":0\r\n"
+		}
+		switch args[1] {
+		case redisRefreshScript:
+			f.ttls[key] = args[5]
+		case redisDeleteScript:
+			delete(f.values, key)
+		default:
+			return "-ERR unknown script\r\n"
+		}
+		return ":1\r\n"
+	case "HSET":
+		if f.hashes[args[1]] == nil {
+			f.hashes[args[1]] = make(map[string]string)
+		}
+		f.hashes[args[1]][args[2]] = args[3]
+		return ":1\r\n"
+	case "HGETALL":
+		h := f.hashes[args[1]]
+		reply := fmt.Sprintf("*%d\r\n", 2*len(h))
+		for k, v := range h {
+			reply += bulk(k) + bulk(v)
+		}
+		return reply
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func TestRedisBackendClaims(t *testing.T) {
+	f := newFakeRedis(t, "pw")
+	r, err := NewRedisBackend(f.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if owner, err := r.SetNX("k", "node1", 30*time.Second); err != nil || owner != "node1" {
+		t.Fatalf("SetNX = %q, %v, want node1", owner, err)
+	}
+	if owner, err := r.SetNX("k", "node2", 30*time.Second); err != nil || owner != "node1" {
+		t.Fatalf("second SetNX = %q, %v, want the first owner", owner, err)
+	}
+	if f.ttls["k"] != "30000" {
+		t.Errorf("ttl sent as %q, want milliseconds", f.ttls["k"])
+	}
+
+	if ok, err := r.Refresh("k", "node2", time.Minute); err != nil || ok {
+		t.Errorf("refreshed another node's key: %v, %v", ok, err)
+	}
+	if ok, err := r.Refresh("k", "node1", time.Minute); err != nil || !ok || f.ttls["k"] != "60000" {
+		t.Errorf("Refresh = %v, %v with ttl %s", ok, err, f.ttls["k"])
+	}
+
+	if err = r.Delete("k", "node2"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := r.Get("k"); v != "node1" {
+		t.Fatalf("another node deleted the key, now %q", v)
+	}
+	if err = r.Delete("k", "node1"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := r.Get("k"); err != nil || v != "" {
+		t.Fatalf("Get after Delete = %q, %v", v, err)
+	}
+
+	if err = r.Set("k", "node2", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := r.Get("k"); v != "node2" {
+		t.Fatalf("Get = %q after Set", v)
+	}
+
+	// the connection authenticated and picked the database first
+	if first := f.commands[0]; first[0] != "AUTH" || first[1] != "pw" || f.commands[1][0] != "SELECT" || f.commands[1][1] != "2" {
+		t.Errorf("connection started with %v %v", first, f.commands[1])
+	}
+}
+
+func TestRedisBackendHashes(t *testing.T) {
+	f := newFakeRedis(t, "")
+	r, err := NewRedisBackend(f.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// values with line breaks and binary must survive the bulk encoding
+	users := map[string]string{"a": `{"authId":"a"}`, "b": "line\r\nbreak"}
+	for k, v := range users {
+		if err = r.HSet("users", k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := r.HGetAll("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all["a"] != users["a"] || all["b"] != users["b"] {
+		t.Fatalf("HGetAll = %q", all)
+	}
+
+	if all, err = r.HGetAll("nobody"); err != nil || len(all) != 0 {
+		t.Fatalf("HGetAll of a missing key = %q, %v", all, err)
+	}
+}
+
+func TestRedisBackendErrors(t *testing.T) {
+	f := newFakeRedis(t, "pw")
+	u := f.url()
+	u.User = url.UserPassword("", "wrong")
+	if _, err := NewRedisBackend(u); err == nil {
+		t.Fatal("connected with the wrong password")
+	}
+
+	r, err := NewRedisBackend(f.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an error reply leaves the connection usable
+	if _, err = r.do("NOPE"); err == nil {
+		t.Fatal("no error for an unknown command")
+	} else if _, ok := err.(redisError); !ok {
+		t.Fatalf("got %v, want a redis error", err)
+	}
+	if _, err = r.Get("k"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a dropped connection is redialed on the next command
+	f.dropConnections()
+	r.Get("k")
+	if _, err = r.Get("k"); err != nil {
+		t.Fatalf("not reconnected: %v", err)
+	}
+
+	// and a server that's gone is an error, not a hang
+	f.Close()
+	f.dropConnections()
+	if _, err = r.Get("k"); err == nil {
+		t.Fatal("no error once the server is gone")
+	}
+}
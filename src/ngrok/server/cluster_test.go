@@ -0,0 +1,44 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestClusterRefusesPublicCredentials(t *testing.T) {
+	// debug builds read the built in certificate from where they were built
+	snakeoil, err := LoadTLSConfig("", "")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	if _, err = NewCluster(NewMemoryBackend(), "a", "a:4447", "secret", snakeoil); err == nil {
+		t.Error("joined a cluster with the default certificate")
+	}
+	if _, err = NewCluster(NewMemoryBackend(), "a", "a:4447", "", snakeoil); err == nil {
+		t.Error("joined a cluster without a secret")
+	}
+}
+
+func TestMergeUserConfigWhileCheckingDns(t *testing.T) {
+	mgr := setupDomains(t, "", make(fakeResolver))
+	b := mgr.GetUserInfo("b")
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			mgr.MergeUserConfig(&UserConfig{AuthId: "b", Dns: []string{"shop.example.net", "other"}})
+		}
+		close(done)
+	}()
+
+	for i := 0; i < 100; i++ {
+		if !mgr.CheckDns(b, "shop.example.net") {
+			t.Fatal("lost a dns name while the user was merged")
+		}
+	}
+	<-done
+
+	if !mgr.CheckDns(b, "other") || mgr.CheckDns(nil, "other") {
+		t.Fatal("merged dns list not checked")
+	}
+}
@@ -25,6 +25,21 @@ type UserConfig struct {
 	UserId string   `json:"userId"`
 	AuthId string   `json:"authId"`
 	Dns    []string `json:"dns"`
+
+	// random urls the user was given
+	Reservations []Reservation `json:"reservations,omitempty"`
+
+	// custom domains the user claimed
+	Domains []DomainVerification `json:"domains,omitempty"`
+}
+
+// A copy that can be saved while the original changes
+func (uc *UserConfig) copy() *UserConfig {
+	c := *uc
+	c.Dns = append([]string(nil), uc.Dns...)
+	c.Reservations = append([]Reservation(nil), uc.Reservations...)
+	c.Domains = append([]DomainVerification(nil), uc.Domains...)
+	return &c
 }
 
 type UserInfo struct {
@@ -48,6 +63,9 @@ type ConfigMgr struct {
 	db    DbProvider
 	users map[string]*UserInfo
 	dns   map[string]*UserInfo
+
+	// owner of each reserved url
+	reserved map[string]*UserInfo
 }
 
 type appHandler struct {
@@ -142,10 +160,39 @@ func (mgr *ConfigMgr) AddUserConfig(uc *UserConfig) error {
 	for _, dns := range uc.Dns {
 		mgr.dns[dns] = ui
 	}
+	mgr.indexReservations(ui)
 
 	return nil
 }
 
+// Add a user loaded from a shared database, or update it if it was
+// changed elsewhere, e.g. bound by a client on another node
+func (mgr *ConfigMgr) MergeUserConfig(uc *UserConfig) {
+	mgr.mu.Lock()
+	ui, exists := mgr.users[uc.AuthId]
+	if exists {
+		for _, dns := range ui.Uc.Dns {
+			delete(mgr.dns, dns)
+		}
+		ui.Uc.UserId = uc.UserId
+		ui.Uc.Dns = uc.Dns
+		for _, dns := range uc.Dns {
+			mgr.dns[dns] = ui
+		}
+
+		mgr.unindexReservations(ui)
+		ui.Uc.Reservations = uc.Reservations
+		mgr.indexReservations(ui)
+
+		ui.Uc.Domains = uc.Domains
+	}
+	mgr.mu.Unlock()
+
+	if !exists {
+		mgr.AddUserConfig(uc)
+	}
+}
+
 func (mgr *ConfigMgr) bindUserInner(id string, user string) (*UserConfig, error) {
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
@@ -224,10 +271,6 @@ func (mgr *ConfigMgr) TimeoutAllDays() {
 }
 
 func addUser(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
-	if opts.pass != r.Header.Get("Auth") {
-		return 400, errors.New("not allow")
-	}
-
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		return 400, err
@@ -251,10 +294,6 @@ func addUser(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error
 }
 
 func showInfo(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
-	if opts.pass != r.Header.Get("Auth") {
-		return 400, errors.New("not allow")
-	}
-
 	s := mgr.ListAll()
 	for _, ss := range s {
 		fmt.Fprint(w, ss+"\n")
@@ -277,7 +316,16 @@ func GetByDns(dns string) *UserInfo {
 	return cMgr.GetByDns(dns)
 }
 
-func (ui *UserInfo) CheckDns(dns string) bool {
+// Returns true if dns is in the user's dns list. The list is replaced when
+// the user is changed on another node, so it's read under the lock.
+func (mgr *ConfigMgr) CheckDns(ui *UserInfo, dns string) bool {
+	if ui == nil {
+		return false
+	}
+
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+
 	for _, s := range ui.Uc.Dns {
 		if s == dns {
 			return true
@@ -287,28 +335,41 @@ func (ui *UserInfo) CheckDns(dns string) bool {
 	return false
 }
 
-func CheckForLogin(authMsg *msg.Auth) *UserInfo {
-	usr := cMgr.GetUserInfo(authMsg.ClientId)
+// The password a user is bound to, empty until their first login
+func (mgr *ConfigMgr) boundPassword(ui *UserInfo) string {
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+	return ui.Uc.UserId
+}
+
+var (
+	errAuthFailed    = errors.New("Auth failed")
+	errQuotaExceeded = errors.New("Daily transfer quota exceeded")
+)
+
+func CheckForLogin(authMsg *msg.Auth) (*UserInfo, error) {
+	usr := cMgr.GetUserInfo(authMsg.User)
 	if usr == nil {
-		return nil
+		return nil, errAuthFailed
 	}
 
-	if usr.Uc.UserId != "" && usr.Uc.UserId != authMsg.Password {
-		return nil
+	bound := cMgr.boundPassword(usr)
+	if bound != "" && bound != authMsg.Password {
+		return nil, errAuthFailed
 	}
 
-	if usr.Uc.UserId == "" {
+	if bound == "" {
 		//bind
-		cMgr.BindUser(authMsg.ClientId, authMsg.Password)
+		cMgr.BindUser(authMsg.User, authMsg.Password)
 	} else {
 		day := atomic.LoadInt32(&usr.TransPerDay)
 		//bigger than 1G is not allow
 		if day > 1024*1024*1024 {
-			return nil
+			return nil, errQuotaExceeded
 		}
 	}
 
-	return usr
+	return usr, nil
 }
 
 func NewConfigMgr() *ConfigMgr {
@@ -320,12 +381,26 @@ func NewConfigMgr() *ConfigMgr {
 		CacheSizeMax: 1024 * 1024, // 1MB
 	})
 	db := &Db{diskv: diskv}
-	return &ConfigMgr{db: db, users: make(map[string]*UserInfo), dns: make(map[string]*UserInfo)}
+	return &ConfigMgr{
+		db:       db,
+		users:    make(map[string]*UserInfo),
+		dns:      make(map[string]*UserInfo),
+		reserved: make(map[string]*UserInfo),
+	}
 }
 
 func ConfigMain() {
-	addr := ":4446"
 	cMgr = NewConfigMgr()
+	if cluster != nil {
+		cMgr.db = &ClusterDb{backend: cluster.backend}
+		go func() {
+			for range time.Tick(clusterSyncInterval) {
+				if err := cMgr.db.LoadAll(cMgr); err != nil {
+					log.Println("Failed to sync users from the cluster:", err)
+				}
+			}
+		}()
+	}
 	cMgr.db.LoadAll(cMgr)
 
 	go func() {
@@ -338,9 +413,45 @@ func ConfigMain() {
 		}
 	}()
 
+	var err error
+	if adminAuth, err = NewAdminAuth(opts.adminTokens, opts.adminAudit); err != nil {
+		panic(err)
+	}
+	read := func(h http.Handler) http.Handler { return adminAuth.Require(adminScopeRead, h) }
+	write := func(h http.Handler) http.Handler { return adminAuth.Require(adminScopeWrite, h) }
+
 	router := mux.NewRouter()
-	router.Handle("/adduser", appHandler{cMgr, addUser})
-	router.Handle("/info", appHandler{cMgr, showInfo})
+	router.Handle("/adduser", write(appHandler{cMgr, addUser}))
+	router.Handle("/info", read(appHandler{cMgr, showInfo}))
+	router.Handle("/metrics", read(http.HandlerFunc(serveMetrics)))
+	registerAdminRoutes(router, cMgr, read, write)
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("./statics/"))))
-	http.ListenAndServe(addr, router)
+
+	l, err := listen("admin", opts.adminAddr)
+	if err != nil {
+		panic(err)
+	}
+	bind("admin", l, l)
+
+	server := &http.Server{Addr: opts.adminAddr, Handler: router}
+	if !opts.adminTls {
+		log.Println("Admin server listening on", opts.adminAddr)
+		err = server.Serve(l)
+	} else {
+		// default to the same certificate that the tunnels use
+		crt, key := opts.adminTlsCrt, opts.adminTlsKey
+		if crt == "" && key == "" {
+			crt, key = opts.tlsCrt, opts.tlsKey
+		}
+
+		if server.TLSConfig, err = LoadTLSConfig(crt, key); err != nil {
+			panic(err)
+		}
+		log.Println("Admin server listening for TLS on", opts.adminAddr)
+		err = server.ServeTLS(l, "", "")
+	}
+
+	if !isDraining() {
+		log.Println("Admin server failed:", err)
+	}
 }
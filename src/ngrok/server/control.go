@@ -1,7 +1,7 @@
 package server
 
 import (
-	"errors"
+	"crypto/subtle"
 	"fmt"
 	"io"
 	"ngrok/conn"
@@ -10,13 +10,13 @@ import (
 	"ngrok/version"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	pingTimeoutInterval = 30 * time.Second
-	connReapInterval    = 10 * time.Second
 	controlWriteTimeout = 10 * time.Second
+	proxyWaitTimeout    = 30 * time.Second
 	proxyStaleDuration  = 60 * time.Second
 	proxyMaxPoolSize    = 10
 )
@@ -37,7 +37,11 @@ type Control struct {
 	in chan (msg.Message)
 
 	// the last time we received a ping from the client - for heartbeats
-	lastPing time.Time
+	// unix nanoseconds, accessed atomically because the admin api reads it
+	lastPing int64
+
+	// when the control connection was authenticated
+	start time.Time
 
 	// all of the tunnels this control connection handles
 	tunnels []*Tunnel
@@ -64,6 +68,15 @@ type Control struct {
 	userInfo *UserInfo
 }
 
+// Returns true if a client's password gives it admin rights to any tunnel.
+// Like the admin server, this refuses the well known default password.
+func adminPassword(password string) bool {
+	if opts.pass == "" || opts.pass == defaultPass {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(opts.pass), []byte(password)) == 1
+}
+
 func NewControl(ctlConn conn.Conn, authMsg *msg.Auth) {
 	var err error
 
@@ -74,7 +87,8 @@ func NewControl(ctlConn conn.Conn, authMsg *msg.Auth) {
 		out:             make(chan msg.Message),
 		in:              make(chan msg.Message),
 		proxies:         make(chan conn.Conn, 10),
-		lastPing:        time.Now(),
+		lastPing:        time.Now().UnixNano(),
+		start:           time.Now(),
 		writerShutdown:  util.NewShutdown(),
 		readerShutdown:  util.NewShutdown(),
 		managerShutdown: util.NewShutdown(),
@@ -86,7 +100,15 @@ func NewControl(ctlConn conn.Conn, authMsg *msg.Auth) {
 		ctlConn.Close()
 	}
 
-	if opts.pass == authMsg.Password {
+	// authentication failures are reported to event hooks, other errors aren't
+	failLogin := func(typ string, e error) {
+		ev := newControlLifecycleEvent(typ, c)
+		ev.Reason = e.Error()
+		emitEvent(ev)
+		failAuth(e)
+	}
+
+	if adminPassword(authMsg.Password) {
 		c.isAdmin = true
 	}
 
@@ -105,9 +127,13 @@ func NewControl(ctlConn conn.Conn, authMsg *msg.Auth) {
 	}
 
 	//log.Info("clientId: %s", c.id)
-	ui := CheckForLogin(authMsg)
-	if ui == nil && !c.isAdmin {
-		failAuth(errors.New("Auth failed"))
+	ui, err := CheckForLogin(authMsg)
+	if err != nil && !c.isAdmin {
+		if err == errQuotaExceeded {
+			failLogin(EventQuotaExceeded, err)
+		} else {
+			failLogin(EventAuthFailed, err)
+		}
 		return
 	}
 	c.userInfo = ui
@@ -117,7 +143,7 @@ func NewControl(ctlConn conn.Conn, authMsg *msg.Auth) {
 	ctlConn.AddLogPrefix(c.id)
 
 	if authMsg.Version != version.Proto {
-		failAuth(fmt.Errorf("Incompatible versions. Server %s, client %s. Download a new version at http://ngrok.com", version.MajorMinor(), authMsg.Version))
+		failLogin(EventAuthFailed, fmt.Errorf("Incompatible versions. Server %s, client %s. Download a new version at http://ngrok.com", version.MajorMinor(), authMsg.Version))
 		return
 	}
 
@@ -139,6 +165,8 @@ func NewControl(ctlConn conn.Conn, authMsg *msg.Auth) {
 	// As a performance optimization, ask for a proxy connection up front
 	c.out <- &msg.ReqProxy{}
 
+	emitEvent(newControlLifecycleEvent(EventClientConnected, c))
+
 	// manage the connection
 	go c.manager()
 	go c.reader()
@@ -147,17 +175,40 @@ func NewControl(ctlConn conn.Conn, authMsg *msg.Auth) {
 
 // Register a new tunnel on this control connection
 func (c *Control) registerTunnel(rawTunnelReq *msg.ReqTunnel) {
-	if !c.isAdmin && rawTunnelReq.Subdomain != "" && !c.userInfo.CheckDns(rawTunnelReq.Subdomain) {
+	if !c.isAdmin && rawTunnelReq.Subdomain != "" && !cMgr.CheckDns(c.userInfo, rawTunnelReq.Subdomain) {
 		c.conn.Warn("Dns not ok %s, ignore", rawTunnelReq.Subdomain)
 		return
 	}
 
+	// a hostname under our domain is a subdomain, any other must be verified
+	var unverified string
+	if hostname := strings.ToLower(strings.TrimSpace(rawTunnelReq.Hostname)); !c.isAdmin && hostname != "" {
+		allowed := false
+		if sub := strings.TrimSuffix(hostname, "."+opts.domain); sub != hostname {
+			allowed = cMgr.CheckDns(c.userInfo, sub)
+		} else if cMgr.HostnameAllowed(c.userInfo, hostname) {
+			allowed = true
+		} else if cMgr.ChallengePending(c.userInfo, hostname) {
+			c.conn.Info("Hostname %s is not verified yet, serving only its challenge", hostname)
+			unverified, allowed = hostname, true
+		}
+
+		if !allowed {
+			c.conn.Warn("Hostname %s is not verified for this user", hostname)
+			c.out <- &msg.NewTunnel{ReqId: rawTunnelReq.ReqId, Error: fmt.Sprintf("The hostname %s is not verified for your account.", hostname)}
+			if len(c.tunnels) == 0 {
+				c.shutdown.Begin()
+			}
+			return
+		}
+	}
+
 	for _, proto := range strings.Split(rawTunnelReq.Protocol, "+") {
 		tunnelReq := *rawTunnelReq
 		tunnelReq.Protocol = proto
 
 		c.conn.Debug("Registering new tunnel")
-		t, err := NewTunnel(&tunnelReq, c)
+		t, err := NewTunnel(&tunnelReq, c, unverified)
 		if err != nil {
 			c.out <- &msg.NewTunnel{Error: err.Error()}
 			if len(c.tunnels) == 0 {
@@ -178,7 +229,34 @@ func (c *Control) registerTunnel(rawTunnelReq *msg.ReqTunnel) {
 			ReqId:    rawTunnelReq.ReqId,
 		}
 
-		rawTunnelReq.Hostname = strings.Replace(t.url, proto+"://", "", 1)
+		host, _ := splitRouteUrl(t.url)
+		rawTunnelReq.Hostname = strings.Replace(host, proto+"://", "", 1)
+	}
+}
+
+// Sent to manager() over c.in to close a single tunnel of this control
+type closeTunnel struct {
+	url string
+}
+
+func (c *Control) LastPing() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.lastPing))
+}
+
+// Ask the manager to close one of this control's tunnels. The client is
+// not told; it will simply stop receiving traffic for that url.
+func (c *Control) CloseTunnel(url string) error {
+	return util.PanicToError(func() { c.in <- &closeTunnel{url: url} })
+}
+
+func (c *Control) closeTunnel(url string) {
+	for i, t := range c.tunnels {
+		if t.url == url {
+			t.Shutdown()
+			c.tunnels = append(c.tunnels[:i], c.tunnels[i+1:]...)
+			c.conn.Info("Closed tunnel %s", url)
+			return
+		}
 	}
 }
 
@@ -197,14 +275,22 @@ func (c *Control) manager() {
 	defer c.managerShutdown.Complete()
 
 	// reaping timer for detecting heartbeat failure
-	reap := time.NewTicker(connReapInterval)
+	reap := time.NewTicker(opts.reapInterval)
 	defer reap.Stop()
 
 	for {
 		select {
 		case <-reap.C:
-			if time.Since(c.lastPing) > pingTimeoutInterval {
+			if time.Since(c.LastPing()) > opts.pingTimeout {
 				c.conn.Info("Lost heartbeat")
+				metrics.LostHeartbeat(c)
+				emitEvent(newControlLifecycleEvent(EventHeartbeatLost, c))
+
+				// stop routing to our tunnels now so that the standbys of a failover
+				// pool take over without waiting for the connection to be torn down
+				for _, t := range c.tunnels {
+					tunnelRegistry.Del(t.url, t)
+				}
 				c.shutdown.Begin()
 			}
 
@@ -218,8 +304,14 @@ func (c *Control) manager() {
 			case *msg.ReqTunnel:
 				c.registerTunnel(m)
 
+			case *closeTunnel:
+				c.closeTunnel(m.url)
+
+			case *msg.CloseTunnel:
+				c.closeTunnel(m.Url)
+
 			case *msg.Ping:
-				c.lastPing = time.Now()
+				atomic.StoreInt64(&c.lastPing, time.Now().UnixNano())
 				c.out <- &msg.Pong{}
 			}
 		}
@@ -351,15 +443,18 @@ func (c *Control) GetProxy() (proxyConn conn.Conn, err error) {
 			return
 		}
 
+		waitStart := time.Now()
 		select {
 		case proxyConn, ok = <-c.proxies:
 			if !ok {
 				err = fmt.Errorf("No proxy connections available, control is closing")
 				return
 			}
+			metrics.ProxyWait(c, time.Since(waitStart))
 
-		case <-time.After(pingTimeoutInterval):
+		case <-time.After(proxyWaitTimeout):
 			err = fmt.Errorf("Timeout trying to get proxy connection")
+			metrics.ProxyTimeout(c)
 			return
 		}
 	}
@@ -372,6 +467,10 @@ func (c *Control) GetProxy() (proxyConn conn.Conn, err error) {
 func (c *Control) Replaced(replacement *Control) {
 	c.conn.Info("Replaced by control: %s", replacement.conn.Id())
 
+	ev := newControlLifecycleEvent(EventControlReplaced, c)
+	ev.Reason = "Replaced by " + replacement.conn.RemoteAddr().String()
+	emitEvent(ev)
+
 	// set the control id to empty string so that when stopper()
 	// calls registry.Del it won't delete the replacement
 	c.id = ""
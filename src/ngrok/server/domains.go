@@ -0,0 +1,396 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"ngrok/log"
+	"ngrok/util"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	challengeDns  = "dns"
+	challengeHttp = "http"
+
+	// the TXT record _ngrok-challenge.example.org must hold the token, or
+	// http://example.org/.well-known/ngrok-challenge/<token> must answer with it
+	challengeRecord   = "_ngrok-challenge."
+	challengePath     = "/.well-known/ngrok-challenge/"
+	challengeTimeout  = 10 * time.Second
+	challengeMaxReply = 1024
+)
+
+// A custom domain a user has claimed and whether they proved they own it.
+// A domain verified through DNS covers the names under it as well, one
+// verified through HTTP covers only itself.
+type DomainVerification struct {
+	Domain     string    `json:"domain"`
+	Token      string    `json:"token"`
+	Verified   bool      `json:"verified"`
+	Method     string    `json:"method,omitempty"`
+	VerifiedAt time.Time `json:"verifiedAt,omitempty"`
+}
+
+func (d *DomainVerification) covers(hostname string) bool {
+	if !d.Verified {
+		return false
+	}
+
+	if d.Method != challengeDns {
+		return hostname == d.Domain
+	}
+
+	hostname = strings.TrimPrefix(hostname, "*.")
+	return hostname == d.Domain || strings.HasSuffix(hostname, "."+d.Domain)
+}
+
+// Looks up the records a DNS challenge is checked against
+type Resolver interface {
+	LookupTXT(name string) ([]string, error)
+}
+
+type netResolver struct{}
+
+func (netResolver) LookupTXT(name string) ([]string, error) {
+	return net.LookupTXT(name)
+}
+
+var (
+	// replaced in tests
+	challengeResolver Resolver = netResolver{}
+	challengeClient            = &http.Client{
+		Timeout: challengeTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+func challengeEnabled(method string) bool {
+	for _, m := range strings.Split(opts.domainChallenges, ",") {
+		if strings.TrimSpace(m) == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if a user may open a tunnel for a custom hostname, because
+// it's in their dns list or they verified a domain covering it
+func (mgr *ConfigMgr) HostnameAllowed(ui *UserInfo, hostname string) bool {
+	if ui == nil {
+		return false
+	}
+
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+
+	for _, dns := range ui.Uc.Dns {
+		if dns == hostname {
+			return true
+		}
+	}
+
+	for i := range ui.Uc.Domains {
+		if ui.Uc.Domains[i].covers(hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if a user waits for hostname to pass an HTTP challenge. Until
+// it does, their tunnel for it serves nothing but the challenge.
+func (mgr *ConfigMgr) ChallengePending(ui *UserInfo, hostname string) bool {
+	if ui == nil || !challengeEnabled(challengeHttp) {
+		return false
+	}
+
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+
+	// nobody may answer the challenge for a hostname of somebody else's
+	if mgr.ownedByOther(ui.Uc.AuthId, hostname) {
+		return false
+	}
+
+	for _, d := range ui.Uc.Domains {
+		if d.Domain == hostname && !d.Verified {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if hostname is in the dns list of a user other than authId,
+// or covered by a domain they verified. The caller holds mgr.mu.
+func (mgr *ConfigMgr) ownedByOther(authId, hostname string) bool {
+	if owner := mgr.dns[hostname]; owner != nil && owner.Uc.AuthId != authId {
+		return true
+	}
+
+	for id, other := range mgr.users {
+		if id == authId {
+			continue
+		}
+		for i := range other.Uc.Domains {
+			if other.Uc.Domains[i].covers(hostname) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (mgr *ConfigMgr) Domains(authId string) ([]DomainVerification, error) {
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+
+	ui := mgr.users[authId]
+	if ui == nil {
+		return nil, errors.New("not exists")
+	}
+	return append([]DomainVerification(nil), ui.Uc.Domains...), nil
+}
+
+// Start the verification of a domain for a user, returns the challenge
+func (mgr *ConfigMgr) AddDomain(authId, domain string) (*DomainVerification, error) {
+	domain = strings.ToLower(strings.Trim(strings.TrimSpace(domain), "."))
+	if domain == "" || strings.ContainsAny(domain, "*/: ") {
+		return nil, fmt.Errorf("Invalid domain '%s'", domain)
+	}
+
+	mgr.mu.Lock()
+	ui := mgr.users[authId]
+	if ui == nil {
+		mgr.mu.Unlock()
+		return nil, errors.New("not exists")
+	}
+
+	if mgr.ownedByOther(authId, domain) {
+		mgr.mu.Unlock()
+		return nil, fmt.Errorf("%s belongs to another user", domain)
+	}
+
+	for _, d := range ui.Uc.Domains {
+		if d.Domain == domain {
+			mgr.mu.Unlock()
+			return &d, nil
+		}
+	}
+
+	d := DomainVerification{Domain: domain, Token: util.SecureRandIdOrPanic(16)}
+	ui.Uc.Domains = append(ui.Uc.Domains, d)
+	uc := ui.Uc.copy()
+	mgr.mu.Unlock()
+
+	return &d, mgr.db.Save(mgr, uc)
+}
+
+func (mgr *ConfigMgr) RemoveDomain(authId, domain string) error {
+	mgr.mu.Lock()
+	ui := mgr.users[authId]
+	if ui == nil {
+		mgr.mu.Unlock()
+		return errors.New("not exists")
+	}
+
+	var kept []DomainVerification
+	for _, d := range ui.Uc.Domains {
+		if d.Domain != domain {
+			kept = append(kept, d)
+		}
+	}
+	if len(kept) == len(ui.Uc.Domains) {
+		mgr.mu.Unlock()
+		return errors.New("no such domain")
+	}
+
+	ui.Uc.Domains = kept
+	uc := ui.Uc.copy()
+	mgr.mu.Unlock()
+
+	return mgr.db.Save(mgr, uc)
+}
+
+// Check the challenges of a domain and record it as verified if one passes
+func (mgr *ConfigMgr) VerifyDomain(authId, domain string) (*DomainVerification, error) {
+	mgr.mu.RLock()
+	var d *DomainVerification
+	if ui := mgr.users[authId]; ui != nil {
+		for _, candidate := range ui.Uc.Domains {
+			if candidate.Domain == domain {
+				d = &candidate
+				break
+			}
+		}
+	}
+
+	// the same domain can't belong to two users
+	if mgr.ownedByOther(authId, domain) {
+		mgr.mu.RUnlock()
+		return nil, fmt.Errorf("%s belongs to another user", domain)
+	}
+	mgr.mu.RUnlock()
+
+	if d == nil {
+		return nil, errors.New("no such domain")
+	}
+
+	// the challenges are checked without holding the lock
+	method, err := checkChallenges(d)
+	if err != nil {
+		return d, err
+	}
+
+	mgr.mu.Lock()
+	ui := mgr.users[authId]
+	if ui == nil {
+		mgr.mu.Unlock()
+		return nil, errors.New("not exists")
+	}
+
+	// somebody else may have taken it while the challenges were checked
+	if mgr.ownedByOther(authId, domain) {
+		mgr.mu.Unlock()
+		return nil, fmt.Errorf("%s belongs to another user", domain)
+	}
+
+	for i := range ui.Uc.Domains {
+		if ui.Uc.Domains[i].Domain == domain {
+			ui.Uc.Domains[i].Verified = true
+			ui.Uc.Domains[i].Method = method
+			ui.Uc.Domains[i].VerifiedAt = time.Now().UTC()
+			d = &ui.Uc.Domains[i]
+		}
+	}
+	verified := *d
+	uc := ui.Uc.copy()
+	mgr.mu.Unlock()
+
+	if err := mgr.db.Save(mgr, uc); err != nil {
+		log.Warn("Failed to save verification of %s for %s: %v", domain, authId, err)
+	}
+	return &verified, nil
+}
+
+// Returns the method of the first challenge that passes
+func checkChallenges(d *DomainVerification) (string, error) {
+	var failures []string
+
+	if challengeEnabled(challengeDns) {
+		if err := checkDnsChallenge(d); err == nil {
+			return challengeDns, nil
+		} else {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if challengeEnabled(challengeHttp) {
+		if err := checkHttpChallenge(d); err == nil {
+			return challengeHttp, nil
+		} else {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(failures) == 0 {
+		return "", errors.New("Domain challenges are disabled")
+	}
+	return "", fmt.Errorf("Failed to verify %s: %s", d.Domain, strings.Join(failures, "; "))
+}
+
+func checkDnsChallenge(d *DomainVerification) error {
+	name := challengeRecord + d.Domain
+	records, err := challengeResolver.LookupTXT(name)
+	if err != nil {
+		return fmt.Errorf("looking up TXT %s: %v", name, err)
+	}
+
+	for _, r := range records {
+		if strings.TrimSpace(r) == d.Token {
+			return nil
+		}
+	}
+	return fmt.Errorf("no TXT record %s holds the token", name)
+}
+
+func checkHttpChallenge(d *DomainVerification) error {
+	url := "http://" + d.Domain + challengePath + d.Token
+	resp, err := challengeClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, challengeMaxReply))
+	if err != nil {
+		return fmt.Errorf("reading %s: %v", url, err)
+	}
+
+	if resp.StatusCode != 200 || strings.TrimSpace(string(body)) != d.Token {
+		return fmt.Errorf("%s did not answer with the token", url)
+	}
+	return nil
+}
+
+func apiDomains(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
+	domains, err := mgr.Domains(mux.Vars(r)["id"])
+	if err != nil {
+		return 404, err
+	}
+	return writeJSON(w, domains)
+}
+
+func apiAddDomain(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
+	var req struct {
+		Domain string `json:"domain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return 400, err
+	}
+
+	d, err := mgr.AddDomain(mux.Vars(r)["id"], req.Domain)
+	if err != nil {
+		return 400, err
+	}
+
+	return writeJSON(w, map[string]interface{}{
+		"domain":    d,
+		"txtRecord": challengeRecord + d.Domain,
+		"httpUrl":   "http://" + d.Domain + challengePath + d.Token,
+	})
+}
+
+func apiVerifyDomain(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
+	vars := mux.Vars(r)
+	d, err := mgr.VerifyDomain(vars["id"], vars["domain"])
+	if err != nil {
+		if d == nil {
+			return 404, err
+		}
+
+		// the challenge didn't pass, say why
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(422)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return 422, nil
+	}
+	return writeJSON(w, d)
+}
+
+func apiRemoveDomain(mgr *ConfigMgr, w http.ResponseWriter, r *http.Request) (int, error) {
+	vars := mux.Vars(r)
+	if err := mgr.RemoveDomain(vars["id"], vars["domain"]); err != nil {
+		return 404, err
+	}
+	return writeJSON(w, map[string]string{"code": "ok"})
+}
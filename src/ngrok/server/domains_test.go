@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// answers TXT lookups from a map instead of DNS
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupTXT(name string) ([]string, error) {
+	if records, ok := r[name]; ok {
+		return records, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+type nopDb struct{}
+
+func (nopDb) Save(mgr *ConfigMgr, uc *UserConfig) error { return nil }
+func (nopDb) LoadAll(mgr *ConfigMgr) error              { return nil }
+
+// A user database with users a and b, b owning shop.example.net through its
+// dns list, checking the given challenges against resolver
+func setupDomains(t *testing.T, challenges string, resolver Resolver) *ConfigMgr {
+	oldOpts, oldResolver, oldClient := opts, challengeResolver, challengeClient
+	t.Cleanup(func() {
+		opts, challengeResolver, challengeClient = oldOpts, oldResolver, oldClient
+	})
+
+	opts = &Options{domainChallenges: challenges}
+	challengeResolver = resolver
+
+	mgr := &ConfigMgr{
+		db:       nopDb{},
+		users:    make(map[string]*UserInfo),
+		dns:      make(map[string]*UserInfo),
+		reserved: make(map[string]*UserInfo),
+	}
+	for _, uc := range []*UserConfig{
+		{AuthId: "a"},
+		{AuthId: "b", Dns: []string{"shop.example.net"}},
+	} {
+		if err := mgr.AddUserConfig(uc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mgr
+}
+
+// Send HTTP challenges for any domain to a local server answering with body
+func serveHttpChallenges(t *testing.T, body func(token string) string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, challengePath) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body(strings.TrimPrefix(r.URL.Path, challengePath))))
+	}))
+	t.Cleanup(srv.Close)
+
+	challengeClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return net.Dial(network, srv.Listener.Addr().String())
+			},
+		},
+	}
+}
+
+func TestVerifyDomainDns(t *testing.T) {
+	resolver := make(fakeResolver)
+	mgr := setupDomains(t, "dns", resolver)
+
+	d, err := mgr.AddDomain("a", "Example.org.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Domain != "example.org" {
+		t.Fatalf("domain %s was not normalized", d.Domain)
+	}
+
+	// no record yet
+	if _, err = mgr.VerifyDomain("a", "example.org"); err == nil {
+		t.Fatal("verified without a TXT record")
+	}
+
+	resolver["_ngrok-challenge.example.org"] = []string{"unrelated", "wrong-token"}
+	if _, err = mgr.VerifyDomain("a", "example.org"); err == nil {
+		t.Fatal("verified with the wrong token")
+	}
+
+	resolver["_ngrok-challenge.example.org"] = []string{"unrelated", " " + d.Token + " "}
+	verified, err := mgr.VerifyDomain("a", "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verified.Verified || verified.Method != challengeDns {
+		t.Fatalf("got %+v, want verified through dns", verified)
+	}
+
+	a := mgr.GetUserInfo("a")
+	for hostname, want := range map[string]bool{
+		"example.org":       true,
+		"api.example.org":   true,
+		"*.example.org":     true,
+		"a.b.example.org":   true,
+		"badexample.org":    false,
+		"example.org.evil":  false,
+		"other.example.com": false,
+	} {
+		if got := mgr.HostnameAllowed(a, hostname); got != want {
+			t.Errorf("HostnameAllowed(%s) = %v, want %v", hostname, got, want)
+		}
+	}
+}
+
+func TestVerifyDomainHttp(t *testing.T) {
+	mgr := setupDomains(t, "http", make(fakeResolver))
+
+	answer := "nope"
+	serveHttpChallenges(t, func(token string) string { return answer })
+
+	d, err := mgr.AddDomain("a", "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := mgr.GetUserInfo("a")
+	if !mgr.ChallengePending(a, "example.org") {
+		t.Fatal("no challenge pending for a claimed domain")
+	}
+
+	if _, err = mgr.VerifyDomain("a", "example.org"); err == nil {
+		t.Fatal("verified with the wrong answer")
+	}
+
+	answer = d.Token + "\n"
+	verified, err := mgr.VerifyDomain("a", "example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verified.Verified || verified.Method != challengeHttp {
+		t.Fatalf("got %+v, want verified through http", verified)
+	}
+
+	if mgr.ChallengePending(a, "example.org") {
+		t.Fatal("challenge still pending after verification")
+	}
+
+	// an HTTP challenge proves control of one name, not the ones under it
+	for hostname, want := range map[string]bool{
+		"example.org":     true,
+		"*.example.org":   false,
+		"api.example.org": false,
+	} {
+		if got := mgr.HostnameAllowed(a, hostname); got != want {
+			t.Errorf("HostnameAllowed(%s) = %v, want %v", hostname, got, want)
+		}
+	}
+}
+
+func TestDomainChallengesDisabled(t *testing.T) {
+	mgr := setupDomains(t, "", make(fakeResolver))
+
+	if _, err := mgr.AddDomain("a", "example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.VerifyDomain("a", "example.org"); err == nil {
+		t.Fatal("verified with challenges disabled")
+	}
+	if mgr.ChallengePending(mgr.GetUserInfo("a"), "example.org") {
+		t.Fatal("challenge pending with http challenges disabled")
+	}
+}
+
+func TestDomainCovers(t *testing.T) {
+	cases := []struct {
+		d        DomainVerification
+		hostname string
+		want     bool
+	}{
+		{DomainVerification{Domain: "example.org", Verified: true, Method: challengeDns}, "*.example.org", true},
+		{DomainVerification{Domain: "example.org", Verified: true, Method: challengeDns}, "*.api.example.org", true},
+		{DomainVerification{Domain: "example.org", Verified: true, Method: challengeHttp}, "*.example.org", false},
+		{DomainVerification{Domain: "example.org", Verified: true, Method: challengeHttp}, "example.org", true},
+		{DomainVerification{Domain: "example.org", Verified: false, Method: challengeDns}, "example.org", false},
+	}
+
+	for _, c := range cases {
+		if got := c.d.covers(c.hostname); got != c.want {
+			t.Errorf("%s verified=%v by %s covers %s = %v, want %v", c.d.Domain, c.d.Verified, c.d.Method, c.hostname, got, c.want)
+		}
+	}
+}
+
+func TestDomainOwnedByOtherUser(t *testing.T) {
+	resolver := make(fakeResolver)
+	mgr := setupDomains(t, "dns,http", resolver)
+	serveHttpChallenges(t, func(token string) string { return token })
+	a := mgr.GetUserInfo("a")
+
+	// in b's dns list
+	if _, err := mgr.AddDomain("a", "shop.example.net"); err == nil {
+		t.Fatal("claimed a hostname in another user's dns list")
+	}
+
+	// a claim from before b got the hostname can't be used for a challenge
+	a.Uc.Domains = append(a.Uc.Domains, DomainVerification{Domain: "shop.example.net", Token: "t"})
+	if mgr.ChallengePending(a, "shop.example.net") {
+		t.Fatal("challenge pending for a hostname in another user's dns list")
+	}
+	if _, err := mgr.VerifyDomain("a", "shop.example.net"); err == nil {
+		t.Fatal("verified a hostname in another user's dns list")
+	}
+
+	// under a domain b verified through dns
+	d, err := mgr.AddDomain("b", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Uc.Domains = append(a.Uc.Domains, DomainVerification{Domain: "www.example.com", Token: "t"})
+
+	resolver["_ngrok-challenge.example.com"] = []string{d.Token}
+	if _, err = mgr.VerifyDomain("b", "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = mgr.AddDomain("a", "api.example.com"); err == nil {
+		t.Fatal("claimed a hostname under another user's domain")
+	}
+	if mgr.ChallengePending(a, "www.example.com") {
+		t.Fatal("challenge pending under another user's domain")
+	}
+	if _, err = mgr.VerifyDomain("a", "www.example.com"); err == nil {
+		t.Fatal("verified a hostname under another user's domain")
+	}
+	if mgr.HostnameAllowed(a, "www.example.com") {
+		t.Fatal("hostname under another user's domain allowed")
+	}
+
+	// the same domain verified for b
+	if _, err = mgr.AddDomain("a", "example.com"); err == nil {
+		t.Fatal("claimed another user's verified domain")
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"ngrok/log"
+	"ngrok/msg"
+	"ngrok/util"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// names the listeners passed to a restarted ngrokd and their fds: http=3,tun=4,...
+	listenFdsEnv = "NGROKD_LISTEN_FDS"
+
+	// inherited tcp tunnel listeners nobody asked for are closed after this long
+	inheritedGrace = 1 * time.Minute
+
+	drainPollInterval = 100 * time.Millisecond
+)
+
+var (
+	// set once ngrokd stops accepting connections
+	draining int32
+
+	// public connections being proxied, a drain waits for them
+	publicConns int64
+
+	// listeners this process accepts on, by name
+	boundMu sync.Mutex
+	bound   = make(map[string]*boundListener)
+
+	// listeners passed down by the ngrokd that restarted into this one, by name
+	inheritedMu sync.Mutex
+	inherited   map[string]net.Listener
+
+	// replaced in tests
+	exit = os.Exit
+)
+
+// A socket ngrokd accepts connections on
+type boundListener struct {
+	// stops accepting
+	io.Closer
+
+	// the socket, to hand to another process
+	tcp *net.TCPListener
+}
+
+func tcpListenerName(port int) string {
+	return fmt.Sprintf("tcp:%d", port)
+}
+
+// Load the listeners passed down by a restart. Must run before anything listens.
+func loadInheritedListeners() {
+	env := os.Getenv(listenFdsEnv)
+	os.Unsetenv(listenFdsEnv)
+	if env == "" {
+		return
+	}
+
+	inherited = make(map[string]net.Listener)
+	for _, pair := range strings.Split(env, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		fd, err := strconv.Atoi(parts[1])
+		if err != nil {
+			log.Warn("Bad inherited listener %s", pair)
+			continue
+		}
+
+		f := os.NewFile(uintptr(fd), parts[0])
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			log.Warn("Failed to use inherited listener %s: %v", pair, err)
+			continue
+		}
+
+		log.Info("Inherited %s listener on %v", parts[0], l.Addr())
+		inherited[parts[0]] = l
+	}
+
+	// tcp tunnels whose clients don't come back don't keep their port
+	time.AfterFunc(inheritedGrace, func() {
+		inheritedMu.Lock()
+		defer inheritedMu.Unlock()
+		for name, l := range inherited {
+			log.Info("Closing unclaimed inherited %s listener", name)
+			l.Close()
+		}
+		inherited = nil
+	})
+}
+
+func takeInherited(name string) (l net.Listener, ok bool) {
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+	l, ok = inherited[name]
+	delete(inherited, name)
+	return
+}
+
+// Listen on addr, or take over the listener of the same name
+// if the previous ngrokd passed one down
+func listen(name, addr string) (net.Listener, error) {
+	if l, ok := takeInherited(name); ok {
+		return l, nil
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Same as listen for the listener of a tcp tunnel
+func listenTcpPort(port int) (*net.TCPListener, error) {
+	if l, ok := takeInherited(tcpListenerName(port)); ok {
+		if tcp, ok := l.(*net.TCPListener); ok {
+			return tcp, nil
+		}
+		l.Close()
+	}
+	return net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("0.0.0.0"), Port: port})
+}
+
+// Record a listener so that a drain closes it and a restart passes it on
+func bind(name string, closer io.Closer, l net.Listener) {
+	boundMu.Lock()
+	defer boundMu.Unlock()
+	tcp, _ := l.(*net.TCPListener)
+	bound[name] = &boundListener{Closer: closer, tcp: tcp}
+}
+
+func unbind(name string) {
+	boundMu.Lock()
+	defer boundMu.Unlock()
+	delete(bound, name)
+}
+
+func isDraining() bool {
+	return atomic.LoadInt32(&draining) == 1
+}
+
+// Counts a public connection until the returned func is called
+func trackPublicConn() func() {
+	atomic.AddInt64(&publicConns, 1)
+	return func() { atomic.AddInt64(&publicConns, -1) }
+}
+
+// Exit as soon as another SIGTERM or SIGINT arrives, so that a drain can be
+// cut short
+func exitOnSignal(signals <-chan os.Signal) {
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGTERM || sig == os.Interrupt {
+				log.Warn("Got %v while draining, exiting now", sig)
+				exit(1)
+			}
+		}
+	}()
+}
+
+// Stop accepting connections, tell every client to reconnect and wait up
+// to timeout for the clients to go and the public connections in flight to
+// finish
+func drain(timeout time.Duration) {
+	if !atomic.CompareAndSwapInt32(&draining, 0, 1) {
+		return
+	}
+
+	boundMu.Lock()
+	for name, l := range bound {
+		log.Info("Closing %s listener", name)
+		l.Close()
+	}
+	boundMu.Unlock()
+
+	controls := controlRegistry.All()
+	log.Info("Draining: asking %d clients to reconnect", len(controls))
+	for _, c := range controls {
+		go func(c *Control) {
+			util.PanicToError(func() { c.out <- &msg.ServerShutdown{Reason: "server is shutting down"} })
+		}(c)
+	}
+
+	// clients hang up once they got the message, which has to be sent
+	// before the process exits
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if atomic.LoadInt64(&publicConns) == 0 && len(controlRegistry.All()) == 0 {
+			log.Info("Drained all clients and public connections")
+			return
+		}
+		time.Sleep(drainPollInterval)
+	}
+
+	log.Warn("Drain deadline passed with %d clients and %d public connections left", len(controlRegistry.All()), atomic.LoadInt64(&publicConns))
+}
@@ -0,0 +1,77 @@
+// +build !windows
+
+package server
+
+import (
+	"fmt"
+	"ngrok/log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// SIGTERM and SIGINT drain and exit. SIGUSR2 starts a new ngrokd on the
+// same listeners first, so that no connection is refused while it restarts.
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGUSR2)
+	stopOn(signals)
+}
+
+func stopOn(signals chan os.Signal) {
+	for sig := range signals {
+		if sig == syscall.SIGUSR2 {
+			if err := restart(); err != nil {
+				log.Error("Failed to restart: %v", err)
+				continue
+			}
+		}
+
+		log.Info("Got %v, draining connections for up to %v", sig, opts.drainTimeout)
+		exitOnSignal(signals)
+		drain(opts.drainTimeout)
+		return
+	}
+}
+
+// Start a copy of this ngrokd that inherits every bound listener
+func restart() error {
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	boundMu.Lock()
+	var files []*os.File
+	var names []string
+	for name, l := range bound {
+		if l.tcp == nil {
+			continue
+		}
+
+		f, err := l.tcp.File()
+		if err != nil {
+			boundMu.Unlock()
+			return fmt.Errorf("Failed to pass on %s listener: %v", name, err)
+		}
+		defer f.Close()
+
+		// ExtraFiles start at fd 3
+		names = append(names, fmt.Sprintf("%s=%d", name, 3+len(files)))
+		files = append(files, f)
+	}
+	boundMu.Unlock()
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), listenFdsEnv+"="+strings.Join(names, ","))
+	cmd.ExtraFiles = files
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	log.Info("Started new ngrokd as pid %d with %d listeners", cmd.Process.Pid, len(files))
+	return nil
+}
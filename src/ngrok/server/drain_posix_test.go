@@ -0,0 +1,57 @@
+// +build !windows
+
+package server
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSecondSignalExits(t *testing.T) {
+	ctl := setupDrain(t)
+
+	oldOpts, oldExit := opts, exit
+	t.Cleanup(func() { opts, exit = oldOpts, oldExit })
+	opts = &Options{drainTimeout: time.Minute}
+
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+
+	done := trackPublicConn()
+	signals := make(chan os.Signal, 1)
+	stopped := make(chan bool)
+	go func() {
+		stopOn(signals)
+		stopped <- true
+	}()
+
+	signals <- syscall.SIGTERM
+	for deadline := time.Now().Add(5 * time.Second); !isDraining(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("SIGTERM didn't start a drain")
+		}
+	}
+
+	// restarting again while draining isn't a reason to quit
+	signals <- syscall.SIGUSR2
+	signals <- syscall.SIGINT
+	select {
+	case code := <-exited:
+		if code == 0 {
+			t.Error("exited with status 0")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a second signal didn't exit")
+	}
+
+	<-ctl.out
+	controlRegistry.Del(ctl.id)
+	done()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the drain didn't finish")
+	}
+}
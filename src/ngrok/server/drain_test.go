@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net"
+	"ngrok/msg"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Drain with a fresh registry holding one client, returns its control
+func setupDrain(t *testing.T) *Control {
+	oldRegistry := controlRegistry
+	t.Cleanup(func() {
+		controlRegistry = oldRegistry
+		atomic.StoreInt32(&draining, 0)
+	})
+
+	controlRegistry = NewControlRegistry()
+	ctl := &Control{id: "client", out: make(chan msg.Message, 1)}
+	controlRegistry.Add(ctl.id, ctl)
+	return ctl
+}
+
+func TestDrainWaitsForClientsAndPublicConnections(t *testing.T) {
+	ctl := setupDrain(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bind("test", l, l)
+	defer unbind("test")
+
+	done := trackPublicConn()
+	drained := make(chan bool)
+	go func() {
+		drain(time.Minute)
+		drained <- true
+	}()
+
+	select {
+	case m := <-ctl.out:
+		if _, ok := m.(*msg.ServerShutdown); !ok {
+			t.Errorf("client was sent %T, want ServerShutdown", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client wasn't asked to reconnect")
+	}
+
+	if _, err := net.DialTimeout("tcp", l.Addr().String(), time.Second); err == nil {
+		t.Error("the listener still accepts connections")
+	}
+	if !isDraining() {
+		t.Error("not draining")
+	}
+
+	done()
+	select {
+	case <-drained:
+		t.Fatal("drain returned before the client hung up")
+	case <-time.After(3 * drainPollInterval):
+	}
+
+	done = trackPublicConn()
+	controlRegistry.Del(ctl.id)
+	select {
+	case <-drained:
+		t.Fatal("drain returned with a public connection open")
+	case <-time.After(3 * drainPollInterval):
+	}
+
+	done()
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain didn't return once the last public connection closed")
+	}
+}
+
+func TestDrainGivesUpAtDeadline(t *testing.T) {
+	setupDrain(t)
+
+	done := trackPublicConn()
+	defer done()
+
+	start := time.Now()
+	drain(2 * drainPollInterval)
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("drain waited %v past its deadline", waited)
+	}
+
+	// a second drain has nothing left to do
+	start = time.Now()
+	drain(time.Minute)
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("second drain waited %v", waited)
+	}
+}
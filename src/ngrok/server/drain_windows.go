@@ -0,0 +1,19 @@
+package server
+
+import (
+	"ngrok/log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// There's no restart with inherited listeners on windows, only the drain
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+
+	sig := <-signals
+	log.Info("Got %v, draining connections for up to %v", sig, opts.drainTimeout)
+	exitOnSignal(signals)
+	drain(opts.drainTimeout)
+}
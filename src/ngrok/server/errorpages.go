@@ -0,0 +1,42 @@
+package server
+
+import (
+	"ngrok/errorpage"
+)
+
+// the pages ngrokd answers with on behalf of tunnels, -errorPages overrides
+// the built-in ones and a tunnel may override both
+var errorPages = errorpage.Default()
+
+func loadErrorPages(dir string) (err error) {
+	if dir == "" {
+		return
+	}
+	errorPages, err = errorpage.Load(dir, errorPages)
+	return
+}
+
+// The error pages a tunnel asked for, which fall back to the server's
+func tunnelErrorPages(sources map[string]string) (*errorpage.Pages, error) {
+	if len(sources) == 0 {
+		return errorPages, nil
+	}
+	return errorpage.Parse(sources, errorPages)
+}
+
+// Render the response to send on behalf of a tunnel, t is nil if no tunnel
+// was found. accept is the request's Accept header.
+func errorResponse(t *Tunnel, status int, message string, entry *AccessLogEntry, accept string) []byte {
+	pages := errorPages
+	if t != nil && t.pages != nil {
+		pages = t.pages
+	}
+
+	return pages.Render(&errorpage.Data{
+		Status:    status,
+		Message:   message,
+		Host:      entry.Host,
+		RequestId: entry.RequestId,
+		Support:   opts.supportContact,
+	}, accept)
+}
@@ -0,0 +1,223 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"ngrok/log"
+	"ngrok/util"
+	"strconv"
+	"time"
+)
+
+const (
+	EventClientConnected = "client.connected"
+	EventAuthFailed      = "client.auth_failed"
+	EventQuotaExceeded   = "client.quota_exceeded"
+	EventControlReplaced = "client.replaced"
+	EventHeartbeatLost   = "client.heartbeat_lost"
+	EventTunnelOpened    = "tunnel.opened"
+	EventTunnelClosed    = "tunnel.closed"
+
+	eventQueueSize       = 1000
+	eventMaxAttempts     = 6
+	eventRetryBackoff    = 1 * time.Second
+	eventRequestTimeout  = 10 * time.Second
+	eventSignatureHeader = "X-Ngrokd-Signature"
+	eventTimestampHeader = "X-Ngrokd-Timestamp"
+	eventTypeHeader      = "X-Ngrokd-Event"
+)
+
+// configured from -eventHooks, empty when no hooks are configured
+var eventHooks []*EventHook
+
+// A lifecycle event. Fields that don't apply to the event are omitted.
+type Event struct {
+	Id         string    `json:"id"`
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	ClientId   string    `json:"clientId,omitempty"`
+	AuthId     string    `json:"authId,omitempty"`
+	RemoteAddr string    `json:"remoteAddr,omitempty"`
+	OS         string    `json:"os,omitempty"`
+	Version    string    `json:"version,omitempty"`
+	Url        string    `json:"url,omitempty"`
+	Protocol   string    `json:"protocol,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+func newControlLifecycleEvent(typ string, c *Control) *Event {
+	return &Event{
+		Type:       typ,
+		ClientId:   c.id,
+		AuthId:     userLabel(c),
+		RemoteAddr: c.conn.RemoteAddr().String(),
+		OS:         c.auth.OS,
+		Version:    c.auth.MmVersion,
+	}
+}
+
+func newTunnelLifecycleEvent(typ string, t *Tunnel) *Event {
+	e := newControlLifecycleEvent(typ, t.ctl)
+	e.Url = t.url
+	e.Protocol = t.req.Protocol
+	return e
+}
+
+// Hand an event to every hook subscribed to it. Never blocks.
+func emitEvent(e *Event) {
+	if len(eventHooks) == 0 {
+		return
+	}
+
+	e.Id = util.RandId(8)
+	e.Time = time.Now().UTC()
+	for _, h := range eventHooks {
+		h.Send(e)
+	}
+}
+
+// An HTTP endpoint that receives lifecycle events. Each event is POSTed
+// as JSON, signed with an HMAC-SHA256 keyed by Secret of the time it was
+// sent and the body, so that the endpoint can refuse old requests replayed.
+type EventHook struct {
+	log.Logger `json:"-"`
+
+	Url    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"` // empty for all events
+
+	HttpClient http.Client `json:"-"`
+	subscribed map[string]bool
+	queue      chan *Event
+	backoff    time.Duration
+}
+
+// Load hooks from a JSON file containing a list of EventHook and start
+// their delivery loops
+func LoadEventHooks(path string) (hooks []*EventHook, err error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(buf, &hooks); err != nil {
+		err = fmt.Errorf("Failed to parse event hooks %s: %v", path, err)
+		return
+	}
+
+	for _, h := range hooks {
+		if h.Url == "" {
+			err = fmt.Errorf("Event hook in %s has no url", path)
+			return
+		}
+		h.start()
+	}
+	return
+}
+
+func (h *EventHook) start() {
+	h.Logger = log.NewPrefixLogger("events", h.Url)
+	h.HttpClient.Timeout = eventRequestTimeout
+	h.backoff = eventRetryBackoff
+	h.queue = make(chan *Event, eventQueueSize)
+
+	if len(h.Events) > 0 {
+		h.subscribed = make(map[string]bool)
+		for _, typ := range h.Events {
+			h.subscribed[typ] = true
+		}
+	}
+
+	go h.deliverAll()
+	h.Info("Sending events: %v", h.Events)
+}
+
+func (h *EventHook) Send(e *Event) {
+	if h.subscribed != nil && !h.subscribed[e.Type] {
+		return
+	}
+
+	select {
+	case h.queue <- e:
+	default:
+		h.Warn("Event queue full, dropping %s event %s", e.Type, e.Id)
+	}
+}
+
+// delivers events one at a time so that they arrive in order
+func (h *EventHook) deliverAll() {
+	defer func() {
+		if r := recover(); r != nil {
+			h.Error("Event delivery failed: %v", r)
+		}
+	}()
+
+	for e := range h.queue {
+		h.deliver(e)
+	}
+}
+
+func (h *EventHook) deliver(e *Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		h.Error("Failed to serialize event %v: %v", e, err)
+		return
+	}
+
+	backoff := h.backoff
+	for attempt := 1; attempt <= eventMaxAttempts; attempt++ {
+		if err = h.post(e, body); err == nil {
+			h.Debug("Delivered %s event %s", e.Type, e.Id)
+			return
+		}
+
+		h.Warn("Delivery of %s event %s failed, attempt %d of %d: %v", e.Type, e.Id, attempt, eventMaxAttempts, err)
+		if attempt < eventMaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	h.Error("Dropping %s event %s after %d attempts", e.Type, e.Id, eventMaxAttempts)
+}
+
+func (h *EventHook) post(e *Event, body []byte) error {
+	req, err := http.NewRequest("POST", h.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(eventTypeHeader, e.Type)
+	if h.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(eventTimestampHeader, timestamp)
+		req.Header.Set(eventSignatureHeader, "sha256="+signEvent(h.Secret, timestamp, body))
+	}
+
+	resp, err := h.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("got %v response: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// The hex HMAC-SHA256 of the timestamp, a dot and the body
+func signEvent(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
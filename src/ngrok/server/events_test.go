@@ -0,0 +1,176 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"ngrok/log"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type hookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// An endpoint that records the requests of a hook and answers them with the
+// given statuses in turn, then 200
+func hookEndpoint(t *testing.T, secret string, statuses ...int) (*EventHook, chan hookRequest) {
+	requests := make(chan hookRequest, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- hookRequest{r.Header, body}
+
+		status := 200
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	h := &EventHook{
+		Logger:  log.NewPrefixLogger("events", srv.URL),
+		Url:     srv.URL,
+		Secret:  secret,
+		backoff: time.Millisecond,
+	}
+	return h, requests
+}
+
+// Check a request the way the docs tell receivers to
+func verifyHookRequest(t *testing.T, secret string, r hookRequest) {
+	timestamp := r.header.Get(eventTimestampHeader)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		t.Fatalf("bad timestamp %q: %v", timestamp, err)
+	}
+	if age := time.Since(time.Unix(sent, 0)); age < -time.Minute || age > time.Minute {
+		t.Errorf("timestamp %s is %v off", timestamp, age)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + string(r.body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := r.header.Get(eventSignatureHeader); !hmac.Equal([]byte(got), []byte(want)) {
+		t.Errorf("got signature %s, want %s", got, want)
+	}
+}
+
+func TestEventHookSignature(t *testing.T) {
+	h, requests := hookEndpoint(t, "s3cret")
+	h.deliver(&Event{Id: "1", Type: EventTunnelOpened, Url: "http://a.example.com"})
+
+	r := <-requests
+	verifyHookRequest(t, "s3cret", r)
+
+	if got := r.header.Get(eventTypeHeader); got != EventTunnelOpened {
+		t.Errorf("got event type %q", got)
+	}
+
+	var e Event
+	if err := json.Unmarshal(r.body, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Id != "1" || e.Url != "http://a.example.com" {
+		t.Errorf("got event %+v", e)
+	}
+
+	// the same body with another timestamp doesn't verify
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("0." + string(r.body)))
+	if r.header.Get(eventSignatureHeader) == "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Error("the signature doesn't cover the timestamp")
+	}
+}
+
+func TestEventHookWithoutSecret(t *testing.T) {
+	h, requests := hookEndpoint(t, "")
+	h.deliver(&Event{Id: "1", Type: EventClientConnected})
+
+	r := <-requests
+	if r.header.Get(eventSignatureHeader) != "" || r.header.Get(eventTimestampHeader) != "" {
+		t.Errorf("a hook without a secret sent signature %q and timestamp %q",
+			r.header.Get(eventSignatureHeader), r.header.Get(eventTimestampHeader))
+	}
+}
+
+func TestEventHookRetries(t *testing.T) {
+	h, requests := hookEndpoint(t, "s3cret", 500, 502)
+	h.deliver(&Event{Id: "1", Type: EventHeartbeatLost})
+
+	if len(requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(requests))
+	}
+
+	var first []byte
+	for i := 0; i < 3; i++ {
+		r := <-requests
+		verifyHookRequest(t, "s3cret", r)
+		if i == 0 {
+			first = r.body
+		} else if string(r.body) != string(first) {
+			t.Errorf("attempt %d sent %s, the first sent %s", i+1, r.body, first)
+		}
+	}
+}
+
+func TestEventHookGivesUp(t *testing.T) {
+	statuses := make([]int, eventMaxAttempts+1)
+	for i := range statuses {
+		statuses[i] = 500
+	}
+	h, requests := hookEndpoint(t, "", statuses...)
+	h.deliver(&Event{Id: "1", Type: EventHeartbeatLost})
+
+	if len(requests) != eventMaxAttempts {
+		t.Errorf("got %d requests, want %d", len(requests), eventMaxAttempts)
+	}
+}
+
+func TestEventHookSubscriptions(t *testing.T) {
+	h := &EventHook{
+		Logger:     log.NewPrefixLogger("events"),
+		subscribed: map[string]bool{EventTunnelOpened: true},
+		queue:      make(chan *Event, 10),
+	}
+
+	h.Send(&Event{Type: EventTunnelClosed})
+	h.Send(&Event{Type: EventTunnelOpened})
+	if len(h.queue) != 1 || (<-h.queue).Type != EventTunnelOpened {
+		t.Error("an event the hook isn't subscribed to was queued")
+	}
+}
+
+func TestLoadEventHooks(t *testing.T) {
+	dir := t.TempDir()
+	load := func(content string) ([]*EventHook, error) {
+		path := filepath.Join(dir, "hooks.json")
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return LoadEventHooks(path)
+	}
+
+	hooks, err := load(`[{"url": "http://127.0.0.1:1/hook", "secret": "s", "events": ["tunnel.opened"]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := hooks[0]; h.Secret != "s" || !h.subscribed[EventTunnelOpened] || h.subscribed[EventTunnelClosed] {
+		t.Errorf("got hook %+v", h)
+	}
+
+	if _, err := load(`[{"secret": "s"}]`); err == nil {
+		t.Error("a hook without a url loaded")
+	}
+	if _, err := load(`{"url": "http://example.com"}`); err == nil {
+		t.Error("a hook that isn't in a list loaded")
+	}
+}
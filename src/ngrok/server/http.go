@@ -11,34 +11,15 @@ import (
 	"time"
 )
 
-const (
-	NotAuthorized = `HTTP/1.0 401 Not Authorized
-WWW-Authenticate: Basic realm="ngrok"
-Content-Length: 23
-
-Authorization required
-`
-
-	NotFound = `HTTP/1.0 404 Not Found
-Content-Length: %d
-
-Tunnel %s not found
-`
-
-	BadRequest = `HTTP/1.0 400 Bad Request
-Content-Length: 12
-
-Bad Request
-`
-)
-
 // Listens for new http(s) connections from the public internet
-func startHttpListener(addr string, tlsCfg *tls.Config) (listener *conn.Listener) {
+func startHttpListener(name, addr string, tlsCfg *tls.Config) (listener *conn.Listener) {
 	// bind/listen for incoming connections
-	var err error
-	if listener, err = conn.Listen(addr, "pub", tlsCfg); err != nil {
+	l, err := listen(name, addr)
+	if err != nil {
 		panic(err)
 	}
+	listener = conn.NewListener(l, "pub", tlsCfg)
+	bind(name, listener, l)
 
 	proto := "http"
 	if tlsCfg != nil {
@@ -68,17 +49,41 @@ func httpHandler(c conn.Conn, proto string) {
 	// Make sure we detect dead connections while we decide how to multiplex
 	c.SetDeadline(time.Now().Add(connReadTimeout))
 
+	entry := newAccessLogEntry(c)
+
+	// respond on behalf of the tunnel and record it in the access log
+	var accept string
+	respond := func(status int, resp []byte) {
+		n, _ := c.Write(resp)
+		entry.Status = status
+		entry.BytesIn = int64(n)
+		accessLog.Log(entry)
+	}
+	reject := func(t *Tunnel, status int, message string) {
+		respond(status, errorResponse(t, status, message, entry, accept))
+	}
+
 	// multiplex by extracting the Host header, the vhost library
 	vhostConn, err := vhost.HTTP(c)
 	if err != nil {
 		c.Warn("Failed to read valid %s request: %v", proto, err)
-		c.Write([]byte(BadRequest))
+		reject(nil, 400, "The request could not be parsed")
 		return
 	}
 
-	// read out the Host header and auth from the request
+	// read out the Host header, auth and cookies from the request
 	host := strings.ToLower(vhostConn.Host())
 	auth := vhostConn.Request.Header.Get("Authorization")
+	cookies := vhostConn.Request.Header.Get("Cookie")
+
+	req := vhostConn.Request
+	entry.Host = host
+	entry.Method = req.Method
+	entry.Path = req.URL.RequestURI()
+	entry.Proto = req.Proto
+	entry.Referer = req.Referer()
+	entry.UserAgent = req.UserAgent()
+	accept = req.Header.Get("Accept")
 
 	// done reading mux data, free up the request memory
 	vhostConn.Free()
@@ -88,25 +93,60 @@ func httpHandler(c conn.Conn, proto string) {
 
 	// multiplex to find the right backend host
 	c.Debug("Found hostname %s in request", host)
-	tunnel := tunnelRegistry.Get(fmt.Sprintf("%s://%s", proto, host))
+	tunnel, routed := tunnelRegistry.Route(fmt.Sprintf("%s://%s", proto, host), entry.Path)
 	if tunnel == nil {
+		// another node of the cluster may serve it
+		if cluster.ForwardHttp(fmt.Sprintf("%s://%s", proto, host), c, auth, accept, cookies, entry) {
+			return
+		}
+
 		c.Info("No tunnel found for hostname %s", host)
-		c.Write([]byte(fmt.Sprintf(NotFound, len(host)+18, host)))
+		reject(nil, 404, fmt.Sprintf("Tunnel %s not found", host))
 		return
 	}
 
 	// If the client specified http auth and it doesn't match this request's auth
 	// then fail the request with 401 Not Authorized and request the client reissue the
 	// request with basic authdeny the request
-	if tunnel.req.HttpAuth != "" && auth != tunnel.req.HttpAuth {
+	if !tunnel.authorized(auth) {
 		c.Info("Authentication failed: %s", auth)
-		c.Write([]byte(NotAuthorized))
+		entry.setTunnel(tunnel)
+		reject(tunnel, 401, "Authorization required")
+		return
+	}
+
+	if tunnel.challengeOnly(entry.Path) {
+		c.Info("Hostname %s is not verified", host)
+		entry.setTunnel(tunnel)
+		reject(tunnel, 403, fmt.Sprintf("Hostname %s not verified", host))
 		return
 	}
 
+	// the tunnel may only let in people who logged in
+	var identity map[string]string
+	if tunnel.req.OidcAuth {
+		id, status, resp := oidcGate.Check(tunnel, &gateRequest{proto, host, entry.Path, cookies, accept}, entry)
+		if id == nil {
+			entry.setTunnel(tunnel)
+			respond(status, resp)
+			return
+		}
+		identity = id.headers()
+	}
+
+	if routed || identity != nil {
+		rewritten, err := rewriteRequest(c, tunnel, identity)
+		if err != nil {
+			c.Warn("Failed to rewrite request: %v", err)
+			reject(tunnel, 400, "The request could not be parsed")
+			return
+		}
+		c = rewritten
+	}
+
 	// dead connections will now be handled by tunnel heartbeating and the client
 	c.SetDeadline(time.Time{})
 
 	// let the tunnel handle the connection now
-	tunnel.HandlePublicConnection(c)
+	tunnel.HandlePublicConnection(c, entry)
 }
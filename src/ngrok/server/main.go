@@ -3,6 +3,7 @@ package server
 import (
 	"crypto/tls"
 	"math/rand"
+	"net"
 	"ngrok/conn"
 	log "ngrok/log"
 	"ngrok/msg"
@@ -44,6 +45,10 @@ func NewProxy(pxyConn conn.Conn, regPxy *msg.RegProxy) {
 	ctl := controlRegistry.Get(regPxy.ClientId)
 
 	if ctl == nil {
+		// the client's control connection may be on another node
+		if cluster.ForwardProxy(pxyConn, regPxy.ClientId) {
+			return
+		}
 		panic("No client found for identifier: " + regPxy.ClientId)
 	}
 
@@ -57,10 +62,12 @@ func NewProxy(pxyConn conn.Conn, regPxy *msg.RegProxy) {
 // restrictive firewalls.
 func tunnelListener(addr string, tlsConfig *tls.Config) {
 	// listen for incoming connections
-	listener, err := conn.Listen(addr, "tun", tlsConfig)
+	l, err := listen("tun", addr)
 	if err != nil {
 		panic(err)
 	}
+	listener := conn.NewListener(l, "tun", tlsConfig)
+	bind("tun", listener, l)
 
 	log.Info("Listening for control and proxy connections on %s", listener.Addr.String())
 	for c := range listener.Conns {
@@ -105,6 +112,16 @@ func Main() {
 	// init logging
 	log.LogTo(opts.logto, opts.loglevel)
 
+	if opts.pingTimeout <= 0 || opts.reapInterval <= 0 {
+		panic("-pingTimeout and -reapInterval must be positive")
+	}
+
+	// take over the listeners of the ngrokd we're restarting from
+	loadInheritedListeners()
+
+	// init metrics reporting
+	initMetrics(opts)
+
 	// seed random number generator
 	seed, err := util.RandomSeed()
 	if err != nil {
@@ -112,6 +129,32 @@ func Main() {
 	}
 	rand.Seed(seed)
 
+	// init access logging
+	if opts.accessLog != "" {
+		if accessLog, err = NewAccessLog(opts.accessLog, opts.accessLogFormat); err != nil {
+			panic(err)
+		}
+	}
+
+	// init lifecycle event hooks
+	if opts.eventHooks != "" {
+		if eventHooks, err = LoadEventHooks(opts.eventHooks); err != nil {
+			panic(err)
+		}
+	}
+
+	// init error pages
+	if err = loadErrorPages(opts.errorPages); err != nil {
+		panic(err)
+	}
+
+	// init the login gate
+	if opts.oidcIssuer != "" {
+		if oidcGate, err = NewOidcGate(opts.oidcIssuer, opts.oidcClientId, opts.oidcClientSecret, opts.oidcCookieSecret, opts.oidcSessionTtl); err != nil {
+			panic(err)
+		}
+	}
+
 	// init tunnel/control registry
 	registryCacheFile := os.Getenv("REGISTRY_CACHE_FILE")
 	tunnelRegistry = NewTunnelRegistry(registryCacheSize, registryCacheFile)
@@ -126,20 +169,43 @@ func Main() {
 		panic(err)
 	}
 
+	// join the cluster before the user database is loaded from it
+	if opts.clusterBackend != "" {
+		backend, err := NewClusterBackend(opts.clusterBackend)
+		if err != nil {
+			panic(err)
+		}
+
+		advertise := opts.clusterAdvertise
+		if advertise == "" {
+			_, port, _ := net.SplitHostPort(opts.clusterAddr)
+			advertise = net.JoinHostPort(opts.clusterNode, port)
+		}
+
+		if cluster, err = NewCluster(backend, opts.clusterNode, advertise, opts.clusterSecret, tlsConfig); err != nil {
+			panic(err)
+		}
+		go cluster.Run()
+		go cluster.ListenPeers(opts.clusterAddr)
+	}
+
 	//log.Info("start config main")
 	//Add by jannson, start config http server
 	go ConfigMain()
 
 	// listen for http
 	if opts.httpAddr != "" {
-		listeners["http"] = startHttpListener(opts.httpAddr, nil)
+		listeners["http"] = startHttpListener("http", opts.httpAddr, nil)
 	}
 
 	// listen for https
 	if opts.httpsAddr != "" {
-		listeners["https"] = startHttpListener(opts.httpsAddr, tlsConfig)
+		listeners["https"] = startHttpListener("https", opts.httpsAddr, tlsConfig)
 	}
 
 	// ngrok clients
-	tunnelListener(opts.tunnelAddr, tlsConfig)
+	go tunnelListener(opts.tunnelAddr, tlsConfig)
+
+	// run until we're told to stop and have drained
+	handleSignals()
 }
@@ -10,18 +10,49 @@ import (
 	"ngrok/conn"
 	"ngrok/log"
 	"os"
+	"sort"
+	"sync"
 	"time"
 )
 
-var metrics Metrics
+var (
+	metrics Metrics
 
-func init() {
-	keenApiKey := os.Getenv("KEEN_API_KEY")
+	// always collected so that they can be served by the prometheus endpoint,
+	// regardless of which other backend is reporting
+	localMetrics *LocalMetrics
+)
+
+// Set up the metrics backends. Local metrics are always collected, any
+// other sinks that have been configured receive every event as well.
+func initMetrics(opts *Options) {
+	localMetrics = NewLocalMetrics(30 * time.Second)
+	backends := []Metrics{localMetrics}
+
+	if os.Getenv("KEEN_API_KEY") != "" {
+		backends = append(backends, NewKeenIoMetrics(60*time.Second))
+	}
+
+	if opts.statsdAddr != "" {
+		if s, err := NewStatsdMetrics(opts.statsdAddr, opts.statsdPrefix, opts.statsdTags); err != nil {
+			log.Error("Failed to set up statsd metrics for %s: %v", opts.statsdAddr, err)
+		} else {
+			backends = append(backends, s)
+		}
+	}
+
+	if opts.influxUrl != "" {
+		backends = append(backends, NewInfluxMetrics(opts.influxUrl, opts.metricsInterval))
+	}
+
+	if opts.metricsWebhook != "" {
+		backends = append(backends, NewWebhookMetrics(opts.metricsWebhook, opts.metricsInterval))
+	}
 
-	if keenApiKey != "" {
-		metrics = NewKeenIoMetrics(60 * time.Second)
+	if len(backends) == 1 {
+		metrics = localMetrics
 	} else {
-		metrics = NewLocalMetrics(30 * time.Second)
+		metrics = NewMultiMetrics(backends...)
 	}
 }
 
@@ -31,6 +62,122 @@ type Metrics interface {
 	CloseConnection(*Tunnel, conn.Conn, time.Time, int64, int64)
 	OpenTunnel(*Tunnel)
 	CloseTunnel(*Tunnel)
+	LostHeartbeat(*Control)
+	ProxyWait(*Control, time.Duration)
+	ProxyTimeout(*Control)
+	Failover(from, to *Tunnel)
+}
+
+// MultiMetrics reports every event to each of its backends
+type MultiMetrics struct {
+	log.Logger
+	backends []Metrics
+}
+
+func NewMultiMetrics(backends ...Metrics) *MultiMetrics {
+	return &MultiMetrics{
+		Logger:   log.NewPrefixLogger("metrics"),
+		backends: backends,
+	}
+}
+
+func (mm *MultiMetrics) OpenConnection(t *Tunnel, c conn.Conn) {
+	for _, m := range mm.backends {
+		m.OpenConnection(t, c)
+	}
+}
+
+func (mm *MultiMetrics) CloseConnection(t *Tunnel, c conn.Conn, start time.Time, bytesIn, bytesOut int64) {
+	for _, m := range mm.backends {
+		m.CloseConnection(t, c, start, bytesIn, bytesOut)
+	}
+}
+
+func (mm *MultiMetrics) OpenTunnel(t *Tunnel) {
+	for _, m := range mm.backends {
+		m.OpenTunnel(t)
+	}
+}
+
+func (mm *MultiMetrics) CloseTunnel(t *Tunnel) {
+	for _, m := range mm.backends {
+		m.CloseTunnel(t)
+	}
+}
+
+func (mm *MultiMetrics) LostHeartbeat(c *Control) {
+	for _, m := range mm.backends {
+		m.LostHeartbeat(c)
+	}
+}
+
+func (mm *MultiMetrics) ProxyWait(c *Control, wait time.Duration) {
+	for _, m := range mm.backends {
+		m.ProxyWait(c, wait)
+	}
+}
+
+func (mm *MultiMetrics) ProxyTimeout(c *Control) {
+	for _, m := range mm.backends {
+		m.ProxyTimeout(c)
+	}
+}
+
+func (mm *MultiMetrics) Failover(from, to *Tunnel) {
+	for _, m := range mm.backends {
+		m.Failover(from, to)
+	}
+}
+
+// counterVec is a set of counters keyed by a single label value,
+// e.g. the protocol of a tunnel or the user who owns it
+type counterVec struct {
+	sync.RWMutex
+	counters map[string]gometrics.Counter
+}
+
+func newCounterVec() *counterVec {
+	return &counterVec{counters: make(map[string]gometrics.Counter)}
+}
+
+func (v *counterVec) With(label string) gometrics.Counter {
+	v.RLock()
+	c, ok := v.counters[label]
+	v.RUnlock()
+	if ok {
+		return c
+	}
+
+	v.Lock()
+	defer v.Unlock()
+	if c, ok = v.counters[label]; !ok {
+		c = gometrics.NewCounter()
+		v.counters[label] = c
+	}
+	return c
+}
+
+// Each calls fn for every label in sorted order so that output is stable
+func (v *counterVec) Each(fn func(label string, count int64)) {
+	v.RLock()
+	labels := make([]string, 0, len(v.counters))
+	for label := range v.counters {
+		labels = append(labels, label)
+	}
+	v.RUnlock()
+
+	sort.Strings(labels)
+	for _, label := range labels {
+		fn(label, v.With(label).Count())
+	}
+}
+
+// the user a control connection belongs to, for per-user metrics
+func userLabel(c *Control) string {
+	if c.userInfo != nil && c.userInfo.Uc != nil {
+		return c.userInfo.Uc.AuthId
+	}
+	return ""
 }
 
 type LocalMetrics struct {
@@ -52,11 +199,23 @@ type LocalMetrics struct {
 	bytesInCount  gometrics.Counter
 	bytesOutCount gometrics.Counter
 
-	/*
-	   tunnelGauge gometrics.Gauge
-	   tcpTunnelGauge gometrics.Gauge
-	   connGauge gometrics.Gauge
-	*/
+	proxyWaitTimer      gometrics.Timer
+	proxyTimeoutCounter gometrics.Counter
+	failoverCounter     gometrics.Counter
+
+	// broken down by protocol, gauges count what is currently open
+	tunnelCounts *counterVec
+	tunnelGauges *counterVec
+	connCounts   *counterVec
+	connGauges   *counterVec
+	bytesIn      *counterVec
+	bytesOut     *counterVec
+
+	// broken down by user
+	userTunnelGauges *counterVec
+	userConnCounts   *counterVec
+	userBytesIn      *counterVec
+	userBytesOut     *counterVec
 }
 
 func NewLocalMetrics(reportInterval time.Duration) *LocalMetrics {
@@ -79,11 +238,21 @@ func NewLocalMetrics(reportInterval time.Duration) *LocalMetrics {
 		bytesInCount:  gometrics.NewCounter(),
 		bytesOutCount: gometrics.NewCounter(),
 
-		/*
-		   metrics.tunnelGauge = gometrics.NewGauge(),
-		   metrics.tcpTunnelGauge = gometrics.NewGauge(),
-		   metrics.connGauge = gometrics.NewGauge(),
-		*/
+		proxyWaitTimer:      gometrics.NewTimer(),
+		proxyTimeoutCounter: gometrics.NewCounter(),
+		failoverCounter:     gometrics.NewCounter(),
+
+		tunnelCounts: newCounterVec(),
+		tunnelGauges: newCounterVec(),
+		connCounts:   newCounterVec(),
+		connGauges:   newCounterVec(),
+		bytesIn:      newCounterVec(),
+		bytesOut:     newCounterVec(),
+
+		userTunnelGauges: newCounterVec(),
+		userConnCounts:   newCounterVec(),
+		userBytesIn:      newCounterVec(),
+		userBytesOut:     newCounterVec(),
 	}
 
 	go metrics.Report()
@@ -111,18 +280,52 @@ func (m *LocalMetrics) OpenTunnel(t *Tunnel) {
 	case "http":
 		m.httpTunnelMeter.Mark(1)
 	}
+
+	m.tunnelCounts.With(t.req.Protocol).Inc(1)
+	m.tunnelGauges.With(t.req.Protocol).Inc(1)
+	m.userTunnelGauges.With(userLabel(t.ctl)).Inc(1)
 }
 
 func (m *LocalMetrics) CloseTunnel(t *Tunnel) {
+	m.tunnelGauges.With(t.req.Protocol).Dec(1)
+	m.userTunnelGauges.With(userLabel(t.ctl)).Dec(1)
 }
 
 func (m *LocalMetrics) OpenConnection(t *Tunnel, c conn.Conn) {
 	m.connMeter.Mark(1)
+	m.connCounts.With(t.req.Protocol).Inc(1)
+	m.connGauges.With(t.req.Protocol).Inc(1)
+	m.userConnCounts.With(userLabel(t.ctl)).Inc(1)
 }
 
 func (m *LocalMetrics) CloseConnection(t *Tunnel, c conn.Conn, start time.Time, bytesIn, bytesOut int64) {
+	m.connTimer.UpdateSince(start)
+	m.connGauges.With(t.req.Protocol).Dec(1)
+
 	m.bytesInCount.Inc(bytesIn)
 	m.bytesOutCount.Inc(bytesOut)
+	m.bytesIn.With(t.req.Protocol).Inc(bytesIn)
+	m.bytesOut.With(t.req.Protocol).Inc(bytesOut)
+
+	user := userLabel(t.ctl)
+	m.userBytesIn.With(user).Inc(bytesIn)
+	m.userBytesOut.With(user).Inc(bytesOut)
+}
+
+func (m *LocalMetrics) LostHeartbeat(c *Control) {
+	m.lostHeartbeatMeter.Mark(1)
+}
+
+func (m *LocalMetrics) ProxyWait(c *Control, wait time.Duration) {
+	m.proxyWaitTimer.Update(wait)
+}
+
+func (m *LocalMetrics) ProxyTimeout(c *Control) {
+	m.proxyTimeoutCounter.Inc(1)
+}
+
+func (m *LocalMetrics) Failover(from, to *Tunnel) {
+	m.failoverCounter.Inc(1)
 }
 
 func (m *LocalMetrics) Report() {
@@ -143,6 +346,9 @@ func (m *LocalMetrics) Report() {
 			"connMeter.m1":          m.connMeter.Rate1(),
 			"bytesIn.count":         m.bytesInCount.Count(),
 			"bytesOut.count":        m.bytesOutCount.Count(),
+			"lostHeartbeat.count":   m.lostHeartbeatMeter.Count(),
+			"proxyTimeout.count":    m.proxyTimeoutCounter.Count(),
+			"failover.count":        m.failoverCounter.Count(),
 		})
 
 		if err != nil {
@@ -154,17 +360,127 @@ func (m *LocalMetrics) Report() {
 	}
 }
 
-type KeenIoMetric struct {
+type MetricEvent struct {
 	Collection string
 	Event      interface{}
 }
 
+// Collects events by collection and hands them to flush every batchInterval.
+// Runs until events is closed.
+func batchEvents(l log.Logger, events chan *MetricEvent, batchInterval time.Duration, flush func(map[string][]interface{})) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.Error("Metrics batching failed: %v", r)
+		}
+	}()
+
+	batch := make(map[string][]interface{})
+	batchTimer := time.Tick(batchInterval)
+
+	for {
+		select {
+		case m, ok := <-events:
+			if !ok {
+				return
+			}
+			list, ok := batch[m.Collection]
+			if !ok {
+				list = make([]interface{}, 0)
+			}
+			batch[m.Collection] = append(list, m.Event)
+
+		case <-batchTimer:
+			// no metrics to report
+			if len(batch) == 0 {
+				continue
+			}
+
+			for key, val := range batch {
+				l.Debug("Reporting %d metrics for %s", len(val), key)
+			}
+			flush(batch)
+			batch = make(map[string][]interface{})
+		}
+	}
+}
+
+type KeenStruct struct {
+	Timestamp string `json:"timestamp"`
+}
+
+const eventTimeFormat = "2006-01-02T15:04:05.000Z"
+
+type ConnectionEvent struct {
+	Keen               *KeenStruct `json:"keen,omitempty"`
+	Timestamp          string      `json:",omitempty"`
+	OS                 string
+	ClientId           string
+	Protocol           string
+	Url                string
+	User               string
+	Version            string
+	Reason             string
+	HttpAuth           bool
+	Subdomain          bool
+	TunnelDuration     float64
+	ConnectionDuration float64
+	BytesIn            int64
+	BytesOut           int64
+}
+
+func newConnectionEvent(t *Tunnel, start time.Time, in, out int64) *ConnectionEvent {
+	return &ConnectionEvent{
+		OS:                 t.ctl.auth.OS,
+		ClientId:           t.ctl.id,
+		Protocol:           t.req.Protocol,
+		Url:                t.url,
+		User:               t.ctl.auth.User,
+		Version:            t.ctl.auth.MmVersion,
+		HttpAuth:           len(t.auths) > 0,
+		Subdomain:          t.req.Subdomain != "",
+		TunnelDuration:     time.Since(t.start).Seconds(),
+		ConnectionDuration: time.Since(start).Seconds(),
+		BytesIn:            in,
+		BytesOut:           out,
+	}
+}
+
+type TunnelEvent struct {
+	Keen      *KeenStruct `json:"keen,omitempty"`
+	Timestamp string      `json:",omitempty"`
+	OS        string
+	ClientId  string
+	Protocol  string
+	Url       string
+	User      string
+	Version   string
+	Reason    string
+	Duration  float64
+	HttpAuth  bool
+	Subdomain bool
+}
+
+func newTunnelEvent(t *Tunnel) *TunnelEvent {
+	return &TunnelEvent{
+		OS:       t.ctl.auth.OS,
+		ClientId: t.ctl.id,
+		Protocol: t.req.Protocol,
+		Url:      t.url,
+		User:     t.ctl.auth.User,
+		Version:  t.ctl.auth.MmVersion,
+		//Reason: reason,
+		Duration:  time.Since(t.start).Seconds(),
+		HttpAuth:  len(t.auths) > 0,
+		Subdomain: t.req.Subdomain != "",
+	}
+}
+
 type KeenIoMetrics struct {
 	log.Logger
 	ApiKey       string
 	ProjectToken string
 	HttpClient   http.Client
-	Metrics      chan *KeenIoMetric
+	Metrics      chan *MetricEvent
 }
 
 func NewKeenIoMetrics(batchInterval time.Duration) *KeenIoMetrics {
@@ -172,48 +488,18 @@ func NewKeenIoMetrics(batchInterval time.Duration) *KeenIoMetrics {
 		Logger:       log.NewPrefixLogger("metrics"),
 		ApiKey:       os.Getenv("KEEN_API_KEY"),
 		ProjectToken: os.Getenv("KEEN_PROJECT_TOKEN"),
-		Metrics:      make(chan *KeenIoMetric, 1000),
+		Metrics:      make(chan *MetricEvent, 1000),
 	}
 
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				k.Error("KeenIoMetrics failed: %v", r)
-			}
-		}()
-
-		batch := make(map[string][]interface{})
-		batchTimer := time.Tick(batchInterval)
-
-		for {
-			select {
-			case m := <-k.Metrics:
-				list, ok := batch[m.Collection]
-				if !ok {
-					list = make([]interface{}, 0)
-				}
-				batch[m.Collection] = append(list, m.Event)
-
-			case <-batchTimer:
-				// no metrics to report
-				if len(batch) == 0 {
-					continue
-				}
-
-				payload, err := json.Marshal(batch)
-				if err != nil {
-					k.Error("Failed to serialize metrics payload: %v, %v", batch, err)
-				} else {
-					for key, val := range batch {
-						k.Debug("Reporting %d metrics for %s", len(val), key)
-					}
-
-					k.AuthedRequest("POST", "/events", bytes.NewReader(payload))
-				}
-				batch = make(map[string][]interface{})
-			}
+	go batchEvents(k, k.Metrics, batchInterval, func(batch map[string][]interface{}) {
+		payload, err := json.Marshal(batch)
+		if err != nil {
+			k.Error("Failed to serialize metrics payload: %v, %v", batch, err)
+			return
 		}
-	}()
+
+		k.AuthedRequest("POST", "/events", bytes.NewReader(payload))
+	})
 
 	return k
 }
@@ -253,77 +539,30 @@ func (k *KeenIoMetrics) OpenConnection(t *Tunnel, c conn.Conn) {
 }
 
 func (k *KeenIoMetrics) CloseConnection(t *Tunnel, c conn.Conn, start time.Time, in, out int64) {
-	event := struct {
-		Keen               KeenStruct `json:"keen"`
-		OS                 string
-		ClientId           string
-		Protocol           string
-		Url                string
-		User               string
-		Version            string
-		Reason             string
-		HttpAuth           bool
-		Subdomain          bool
-		TunnelDuration     float64
-		ConnectionDuration float64
-		BytesIn            int64
-		BytesOut           int64
-	}{
-		Keen: KeenStruct{
-			Timestamp: start.UTC().Format("2006-01-02T15:04:05.000Z"),
-		},
-		OS:                 t.ctl.auth.OS,
-		ClientId:           t.ctl.id,
-		Protocol:           t.req.Protocol,
-		Url:                t.url,
-		User:               t.ctl.auth.User,
-		Version:            t.ctl.auth.MmVersion,
-		HttpAuth:           t.req.HttpAuth != "",
-		Subdomain:          t.req.Subdomain != "",
-		TunnelDuration:     time.Since(t.start).Seconds(),
-		ConnectionDuration: time.Since(start).Seconds(),
-		BytesIn:            in,
-		BytesOut:           out,
-	}
+	event := newConnectionEvent(t, start, in, out)
+	event.Keen = &KeenStruct{Timestamp: start.UTC().Format(eventTimeFormat)}
 
-	k.Metrics <- &KeenIoMetric{Collection: "CloseConnection", Event: event}
+	k.Metrics <- &MetricEvent{Collection: "CloseConnection", Event: event}
 }
 
 func (k *KeenIoMetrics) OpenTunnel(t *Tunnel) {
 }
 
-type KeenStruct struct {
-	Timestamp string `json:"timestamp"`
+func (k *KeenIoMetrics) LostHeartbeat(c *Control) {
+}
+
+func (k *KeenIoMetrics) ProxyWait(c *Control, wait time.Duration) {
+}
+
+func (k *KeenIoMetrics) ProxyTimeout(c *Control) {
+}
+
+func (k *KeenIoMetrics) Failover(from, to *Tunnel) {
 }
 
 func (k *KeenIoMetrics) CloseTunnel(t *Tunnel) {
-	event := struct {
-		Keen      KeenStruct `json:"keen"`
-		OS        string
-		ClientId  string
-		Protocol  string
-		Url       string
-		User      string
-		Version   string
-		Reason    string
-		Duration  float64
-		HttpAuth  bool
-		Subdomain bool
-	}{
-		Keen: KeenStruct{
-			Timestamp: t.start.UTC().Format("2006-01-02T15:04:05.000Z"),
-		},
-		OS:       t.ctl.auth.OS,
-		ClientId: t.ctl.id,
-		Protocol: t.req.Protocol,
-		Url:      t.url,
-		User:     t.ctl.auth.User,
-		Version:  t.ctl.auth.MmVersion,
-		//Reason: reason,
-		Duration:  time.Since(t.start).Seconds(),
-		HttpAuth:  t.req.HttpAuth != "",
-		Subdomain: t.req.Subdomain != "",
-	}
+	event := newTunnelEvent(t)
+	event.Keen = &KeenStruct{Timestamp: t.start.UTC().Format(eventTimeFormat)}
 
-	k.Metrics <- &KeenIoMetric{Collection: "CloseTunnel", Event: event}
+	k.Metrics <- &MetricEvent{Collection: "CloseTunnel", Event: event}
 }
@@ -0,0 +1,428 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"ngrok/conn"
+	"ngrok/log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sinkQueueSize       = 1000
+	webhookQueueSize    = 16
+	webhookMaxAttempts  = 5
+	webhookRetryBackoff = 1 * time.Second
+	sinkRequestTimeout  = 10 * time.Second
+)
+
+// Queue an event for a batching sink without ever blocking the caller, a
+// slow or dead metrics endpoint must not stall public connections
+func queueEvent(l log.Logger, events chan *MetricEvent, m *MetricEvent) {
+	select {
+	case events <- m:
+	default:
+		l.Warn("Metrics queue full, dropping %s event", m.Collection)
+	}
+}
+
+/**
+ * StatsdMetrics: fire-and-forget UDP packets in the StatsD format,
+ *                optionally with DogStatsD tags
+ */
+type StatsdMetrics struct {
+	log.Logger
+	conn    net.Conn
+	prefix  string
+	dogTags bool
+}
+
+func NewStatsdMetrics(addr, prefix string, dogTags bool) (*StatsdMetrics, error) {
+	c, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &StatsdMetrics{
+		Logger:  log.NewPrefixLogger("metrics", "statsd"),
+		conn:    c,
+		prefix:  prefix,
+		dogTags: dogTags,
+	}
+	s.Info("Reporting to %s", addr)
+	return s, nil
+}
+
+// send a single metric. tags are alternating key/value pairs
+func (s *StatsdMetrics) send(name string, value int64, typ string, tags ...string) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s%s", s.prefix, name)
+
+	// plain statsd has no tags, so they become part of the metric name
+	if !s.dogTags {
+		for i := 1; i < len(tags); i += 2 {
+			fmt.Fprintf(&buf, ".%s", statsdSanitize(tags[i]))
+		}
+	}
+
+	// gauges are only ever adjusted relative to their current value, and
+	// statsd needs an explicit sign to treat the value as a delta
+	if typ == "g" && value >= 0 {
+		fmt.Fprintf(&buf, ":+%d|%s", value, typ)
+	} else {
+		fmt.Fprintf(&buf, ":%d|%s", value, typ)
+	}
+
+	if s.dogTags && len(tags) > 0 {
+		buf.WriteString("|#")
+		for i := 0; i+1 < len(tags); i += 2 {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			fmt.Fprintf(&buf, "%s:%s", tags[i], statsdSanitize(tags[i+1]))
+		}
+	}
+
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		s.Debug("Failed to send %s: %v", name, err)
+	}
+}
+
+func statsdSanitize(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', ',', '#', '.', ' ', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func (s *StatsdMetrics) OpenTunnel(t *Tunnel) {
+	s.send("tunnels.opened", 1, "c", "protocol", t.req.Protocol)
+	s.send("tunnels.open", 1, "g", "protocol", t.req.Protocol)
+}
+
+func (s *StatsdMetrics) CloseTunnel(t *Tunnel) {
+	s.send("tunnels.closed", 1, "c", "protocol", t.req.Protocol)
+	s.send("tunnels.open", -1, "g", "protocol", t.req.Protocol)
+}
+
+func (s *StatsdMetrics) OpenConnection(t *Tunnel, c conn.Conn) {
+	s.send("connections.opened", 1, "c", "protocol", t.req.Protocol)
+}
+
+func (s *StatsdMetrics) CloseConnection(t *Tunnel, c conn.Conn, start time.Time, bytesIn, bytesOut int64) {
+	user := userLabel(t.ctl)
+	s.send("connections.duration", int64(time.Since(start)/time.Millisecond), "ms", "protocol", t.req.Protocol)
+	s.send("bytes.in", bytesIn, "c", "protocol", t.req.Protocol, "user", user)
+	s.send("bytes.out", bytesOut, "c", "protocol", t.req.Protocol, "user", user)
+}
+
+func (s *StatsdMetrics) LostHeartbeat(c *Control) {
+	s.send("heartbeats.lost", 1, "c")
+}
+
+func (s *StatsdMetrics) ProxyWait(c *Control, wait time.Duration) {
+	s.send("proxy.wait", int64(wait/time.Millisecond), "ms")
+}
+
+func (s *StatsdMetrics) ProxyTimeout(c *Control) {
+	s.send("proxy.timeouts", 1, "c")
+}
+
+func (s *StatsdMetrics) Failover(from, to *Tunnel) {
+	s.send("tunnels.failovers", 1, "c", "protocol", to.req.Protocol)
+}
+
+/**
+ * InfluxMetrics: batches points in the InfluxDB line protocol and
+ *                POSTs them to a write endpoint
+ */
+type InfluxMetrics struct {
+	log.Logger
+	writeUrl   string
+	HttpClient http.Client
+	Metrics    chan *MetricEvent
+}
+
+// writeUrl is the complete write endpoint, e.g. http://localhost:8086/write?db=ngrokd
+func NewInfluxMetrics(writeUrl string, batchInterval time.Duration) *InfluxMetrics {
+	m := &InfluxMetrics{
+		Logger:     log.NewPrefixLogger("metrics", "influx"),
+		writeUrl:   writeUrl,
+		HttpClient: http.Client{Timeout: sinkRequestTimeout},
+		Metrics:    make(chan *MetricEvent, sinkQueueSize),
+	}
+
+	go batchEvents(m, m.Metrics, batchInterval, m.write)
+	m.Info("Reporting to %s every %s", writeUrl, batchInterval)
+	return m
+}
+
+func (m *InfluxMetrics) write(batch map[string][]interface{}) {
+	var body bytes.Buffer
+	for _, points := range batch {
+		for _, p := range points {
+			body.WriteString(p.(string))
+			body.WriteString("\n")
+		}
+	}
+
+	resp, err := m.HttpClient.Post(m.writeUrl, "text/plain; charset=utf-8", &body)
+	if err != nil {
+		m.Error("Failed to write points: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		m.Error("Got %v response writing points: %s", resp.StatusCode, msg)
+	}
+}
+
+// queue a point. tags are a map so they can be sorted as influx recommends,
+// field values must already be formatted in line protocol syntax
+func (m *InfluxMetrics) point(measurement string, tags map[string]string, fields map[string]string) {
+	var line bytes.Buffer
+	line.WriteString(influxEscape(measurement))
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if tags[k] != "" {
+			fmt.Fprintf(&line, ",%s=%s", influxEscape(k), influxEscape(tags[k]))
+		}
+	}
+
+	keys = keys[:0]
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		fmt.Fprintf(&line, "%s%s=%s", sep, influxEscape(k), fields[k])
+	}
+
+	fmt.Fprintf(&line, " %d", time.Now().UnixNano())
+	queueEvent(m, m.Metrics, &MetricEvent{Collection: measurement, Event: line.String()})
+}
+
+// line protocol can't escape newlines, so they are dropped rather than let a
+// client supplied tag start a point of its own
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", "", "\r", "")
+
+func influxEscape(s string) string {
+	return influxEscaper.Replace(s)
+}
+
+func influxInt(v int64) string {
+	return fmt.Sprintf("%di", v)
+}
+
+func influxFloat(v float64) string {
+	return fmt.Sprintf("%g", v)
+}
+
+func (m *InfluxMetrics) tunnelTags(t *Tunnel) map[string]string {
+	return map[string]string{"protocol": t.req.Protocol, "user": userLabel(t.ctl), "os": t.ctl.auth.OS}
+}
+
+func (m *InfluxMetrics) OpenTunnel(t *Tunnel) {
+	m.point("ngrokd_tunnel_open", m.tunnelTags(t), map[string]string{"count": influxInt(1)})
+}
+
+func (m *InfluxMetrics) CloseTunnel(t *Tunnel) {
+	m.point("ngrokd_tunnel_close", m.tunnelTags(t), map[string]string{
+		"count":    influxInt(1),
+		"duration": influxFloat(time.Since(t.start).Seconds()),
+	})
+}
+
+func (m *InfluxMetrics) OpenConnection(t *Tunnel, c conn.Conn) {
+}
+
+func (m *InfluxMetrics) CloseConnection(t *Tunnel, c conn.Conn, start time.Time, bytesIn, bytesOut int64) {
+	m.point("ngrokd_connection", m.tunnelTags(t), map[string]string{
+		"bytes_in":  influxInt(bytesIn),
+		"bytes_out": influxInt(bytesOut),
+		"duration":  influxFloat(time.Since(start).Seconds()),
+	})
+}
+
+func (m *InfluxMetrics) LostHeartbeat(c *Control) {
+	m.point("ngrokd_lost_heartbeat", map[string]string{"user": userLabel(c)}, map[string]string{"count": influxInt(1)})
+}
+
+func (m *InfluxMetrics) ProxyWait(c *Control, wait time.Duration) {
+	m.point("ngrokd_proxy_wait", map[string]string{"user": userLabel(c)}, map[string]string{"duration": influxFloat(wait.Seconds())})
+}
+
+func (m *InfluxMetrics) ProxyTimeout(c *Control) {
+	m.point("ngrokd_proxy_timeout", map[string]string{"user": userLabel(c)}, map[string]string{"count": influxInt(1)})
+}
+
+func (m *InfluxMetrics) Failover(from, to *Tunnel) {
+	m.point("ngrokd_failover", m.tunnelTags(to), map[string]string{"count": influxInt(1)})
+}
+
+/**
+ * WebhookMetrics: batches events like the keen.io backend and POSTs them
+ *                 as JSON to an arbitrary URL, retrying failed deliveries
+ *                 without holding up the batching of new events
+ */
+type WebhookMetrics struct {
+	log.Logger
+	url        string
+	HttpClient http.Client
+	Metrics    chan *MetricEvent
+	batches    chan map[string][]interface{}
+	backoff    time.Duration
+}
+
+func NewWebhookMetrics(url string, batchInterval time.Duration) *WebhookMetrics {
+	w := &WebhookMetrics{
+		Logger:     log.NewPrefixLogger("metrics", "webhook"),
+		url:        url,
+		HttpClient: http.Client{Timeout: sinkRequestTimeout},
+		Metrics:    make(chan *MetricEvent, sinkQueueSize),
+		batches:    make(chan map[string][]interface{}, webhookQueueSize),
+		backoff:    webhookRetryBackoff,
+	}
+
+	go w.deliverBatches()
+	go batchEvents(w, w.Metrics, batchInterval, w.queueBatch)
+	w.Info("Reporting to %s every %s", url, batchInterval)
+	return w
+}
+
+// Hand a batch to the delivery goroutine, dropping it if deliveries are
+// that far behind
+func (w *WebhookMetrics) queueBatch(batch map[string][]interface{}) {
+	select {
+	case w.batches <- batch:
+	default:
+		w.Error("Delivery queue full, dropping batch")
+	}
+}
+
+func (w *WebhookMetrics) deliverBatches() {
+	for batch := range w.batches {
+		w.deliver(batch)
+	}
+}
+
+func (w *WebhookMetrics) deliver(batch map[string][]interface{}) {
+	payload, err := json.Marshal(batch)
+	if err != nil {
+		w.Error("Failed to serialize metrics payload: %v, %v", batch, err)
+		return
+	}
+
+	backoff := w.backoff
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		if err = w.post(payload); err == nil {
+			return
+		}
+
+		w.Warn("Delivery attempt %d of %d failed: %v", attempt, webhookMaxAttempts, err)
+		if attempt < webhookMaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	w.Error("Dropping batch after %d failed deliveries", webhookMaxAttempts)
+}
+
+func (w *WebhookMetrics) post(payload []byte) error {
+	resp, err := w.HttpClient.Post(w.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("got %v response: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+func (w *WebhookMetrics) event(collection string, event interface{}) {
+	queueEvent(w, w.Metrics, &MetricEvent{Collection: collection, Event: event})
+}
+
+func (w *WebhookMetrics) OpenTunnel(t *Tunnel) {
+	event := newTunnelEvent(t)
+	event.Timestamp = t.start.UTC().Format(eventTimeFormat)
+	w.event("OpenTunnel", event)
+}
+
+func (w *WebhookMetrics) CloseTunnel(t *Tunnel) {
+	event := newTunnelEvent(t)
+	event.Timestamp = t.start.UTC().Format(eventTimeFormat)
+	w.event("CloseTunnel", event)
+}
+
+func (w *WebhookMetrics) OpenConnection(t *Tunnel, c conn.Conn) {
+}
+
+func (w *WebhookMetrics) CloseConnection(t *Tunnel, c conn.Conn, start time.Time, in, out int64) {
+	event := newConnectionEvent(t, start, in, out)
+	event.Timestamp = start.UTC().Format(eventTimeFormat)
+	w.event("CloseConnection", event)
+}
+
+type controlEvent struct {
+	Timestamp string
+	ClientId  string
+	User      string
+	WaitTime  float64 `json:",omitempty"`
+}
+
+func newControlEvent(c *Control) *controlEvent {
+	return &controlEvent{
+		Timestamp: time.Now().UTC().Format(eventTimeFormat),
+		ClientId:  c.id,
+		User:      userLabel(c),
+	}
+}
+
+func (w *WebhookMetrics) LostHeartbeat(c *Control) {
+	w.event("LostHeartbeat", newControlEvent(c))
+}
+
+func (w *WebhookMetrics) ProxyWait(c *Control, wait time.Duration) {
+	event := newControlEvent(c)
+	event.WaitTime = wait.Seconds()
+	w.event("ProxyWait", event)
+}
+
+func (w *WebhookMetrics) ProxyTimeout(c *Control) {
+	w.event("ProxyTimeout", newControlEvent(c))
+}
+
+func (w *WebhookMetrics) Failover(from, to *Tunnel) {
+	event := newTunnelEvent(to)
+	event.Timestamp = time.Now().UTC().Format(eventTimeFormat)
+	event.Reason = "failover from " + from.ctl.id
+	w.event("Failover", event)
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"ngrok/log"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Listen for statsd packets, returns the address and a func that reads
+// the next n packets
+func statsdListener(t *testing.T) (string, func(n int) []string) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	return pc.LocalAddr().String(), func(n int) []string {
+		var packets []string
+		buf := make([]byte, 1500)
+		for len(packets) < n {
+			pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+			m, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				t.Fatalf("got %d of %d packets: %v", len(packets), n, err)
+			}
+			packets = append(packets, string(buf[:m]))
+		}
+		sort.Strings(packets)
+		return packets
+	}
+}
+
+func expectPackets(t *testing.T, got []string, want ...string) {
+	sort.Strings(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got packets:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestStatsdMetrics(t *testing.T) {
+	addr, read := statsdListener(t)
+	s, err := NewStatsdMetrics(addr, "ngrokd.", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tun := metricsTunnel("http", "linux", "alice.smith")
+	s.OpenTunnel(tun)
+	expectPackets(t, read(2),
+		"ngrokd.tunnels.opened.http:1|c",
+		"ngrokd.tunnels.open.http:+1|g",
+	)
+
+	s.CloseConnection(tun, nil, time.Now(), 100, 10)
+	got := read(3)
+	if !strings.HasPrefix(got[2], "ngrokd.connections.duration.http:") || !strings.HasSuffix(got[2], "|ms") {
+		t.Errorf("unexpected duration packet %q", got[2])
+	}
+	expectPackets(t, got[:2],
+		// the dot in the user would add a level to the name
+		"ngrokd.bytes.in.http.alice_smith:100|c",
+		"ngrokd.bytes.out.http.alice_smith:10|c",
+	)
+
+	s.CloseTunnel(tun)
+	expectPackets(t, read(2),
+		"ngrokd.tunnels.closed.http:1|c",
+		"ngrokd.tunnels.open.http:-1|g",
+	)
+}
+
+func TestStatsdMetricsDogTags(t *testing.T) {
+	addr, read := statsdListener(t)
+	s, err := NewStatsdMetrics(addr, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tun := metricsTunnel("tcp", "linux", "")
+	s.CloseConnection(tun, nil, time.Now(), 5, 6)
+	got := read(3)
+	expectPackets(t, got[:2],
+		"bytes.in:5|c|#protocol:tcp,user:none",
+		"bytes.out:6|c|#protocol:tcp,user:none",
+	)
+
+	s.LostHeartbeat(tun.ctl)
+	expectPackets(t, read(1), "heartbeats.lost:1|c")
+}
+
+// An http endpoint that records the bodies posted to it and answers with
+// the given statuses in turn, then 204
+func recordingEndpoint(t *testing.T, statuses ...int) (string, chan string) {
+	bodies := make(chan string, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(body)
+
+		status := http.StatusNoContent
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, bodies
+}
+
+func nextBody(t *testing.T, bodies chan string) string {
+	select {
+	case body := <-bodies:
+		return body
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing was posted")
+		return ""
+	}
+}
+
+func TestInfluxMetrics(t *testing.T) {
+	url, bodies := recordingEndpoint(t)
+	m := NewInfluxMetrics(url+"/write?db=ngrokd", 10*time.Millisecond)
+
+	m.OpenTunnel(metricsTunnel("http", "linux", "alice"))
+	m.ProxyTimeout(metricsTunnel("http", "linux", "bob").ctl)
+
+	var lines []string
+	for len(lines) < 2 {
+		lines = append(lines, strings.Split(strings.TrimSpace(nextBody(t, bodies)), "\n")...)
+	}
+	sort.Strings(lines)
+
+	want := []string{
+		"ngrokd_proxy_timeout,user=bob count=1i ",
+		"ngrokd_tunnel_open,os=linux,protocol=http,user=alice count=1i ",
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("got line %q, want it to start with %q", lines[i], prefix)
+		}
+	}
+}
+
+func TestInfluxMetricsEscaping(t *testing.T) {
+	url, bodies := recordingEndpoint(t)
+	m := NewInfluxMetrics(url, 10*time.Millisecond)
+
+	// the os comes from the client, which must not be able to add points
+	m.OpenTunnel(metricsTunnel("http", "linux\nngrokd_tunnel_open,user=mallory count=1000i 0\r\n", "a b,c=d"))
+
+	body := strings.TrimSpace(nextBody(t, bodies))
+	if strings.Contains(body, "\n") || strings.Contains(body, "\r") {
+		t.Fatalf("a tag value broke the line: %q", body)
+	}
+
+	prefix := `ngrokd_tunnel_open,os=linuxngrokd_tunnel_open\,user\=mallory\ count\=1000i\ 0,protocol=http,user=a\ b\,c\=d count=1i `
+	if !strings.HasPrefix(body, prefix) {
+		t.Errorf("got %q, want it to start with %q", body, prefix)
+	}
+}
+
+// A webhook sink that retries quickly
+func testWebhook(url string) *WebhookMetrics {
+	w := &WebhookMetrics{
+		Logger:  log.NewPrefixLogger("metrics", "webhook"),
+		url:     url,
+		Metrics: make(chan *MetricEvent, sinkQueueSize),
+		batches: make(chan map[string][]interface{}, webhookQueueSize),
+		backoff: time.Millisecond,
+	}
+	go w.deliverBatches()
+	return w
+}
+
+func TestWebhookMetricsRetries(t *testing.T) {
+	url, bodies := recordingEndpoint(t, 500, 503)
+	w := testWebhook(url)
+
+	w.queueBatch(map[string][]interface{}{"ProxyTimeout": {newControlEvent(metricsTunnel("http", "linux", "alice").ctl)}})
+
+	// the same batch three times, the last one accepted
+	var first string
+	for i := 0; i < 3; i++ {
+		body := nextBody(t, bodies)
+		if i == 0 {
+			first = body
+		} else if body != first {
+			t.Errorf("attempt %d posted %s, the first posted %s", i+1, body, first)
+		}
+	}
+
+	var payload map[string][]controlEvent
+	if err := json.Unmarshal([]byte(first), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload["ProxyTimeout"]) != 1 || payload["ProxyTimeout"][0].User != "alice" {
+		t.Errorf("got payload %s", first)
+	}
+
+	select {
+	case body := <-bodies:
+		t.Errorf("delivered batch posted again: %s", body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWebhookMetricsGivesUp(t *testing.T) {
+	statuses := make([]int, webhookMaxAttempts)
+	for i := range statuses {
+		statuses[i] = 500
+	}
+	url, bodies := recordingEndpoint(t, statuses...)
+	w := testWebhook(url)
+
+	w.queueBatch(map[string][]interface{}{"LostHeartbeat": {"first"}})
+	w.queueBatch(map[string][]interface{}{"LostHeartbeat": {"second"}})
+
+	// the first batch is dropped after its attempts, the second still goes out
+	for i := 0; i < webhookMaxAttempts; i++ {
+		if body := nextBody(t, bodies); !strings.Contains(body, "first") {
+			t.Fatalf("attempt %d posted %s", i+1, body)
+		}
+	}
+	if body := nextBody(t, bodies); !strings.Contains(body, "second") {
+		t.Errorf("got %s after the first batch was dropped", body)
+	}
+}
+
+func TestWebhookMetricsDeliversInBackground(t *testing.T) {
+	release := make(chan struct{})
+	posted := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		select {
+		case posted <- string(body):
+		default:
+		}
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	w := NewWebhookMetrics(srv.URL, 10*time.Millisecond)
+	w.LostHeartbeat(metricsTunnel("http", "linux", "alice").ctl)
+	nextBody(t, posted)
+
+	// while the first delivery hangs, events are still taken and batched
+	for i := 0; i < 2*sinkQueueSize; i++ {
+		select {
+		case w.Metrics <- &MetricEvent{Collection: "ProxyTimeout", Event: i}:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("batching stalled after %d events", i)
+		}
+	}
+}
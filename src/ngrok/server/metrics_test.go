@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"ngrok/msg"
+	"strings"
+	"testing"
+	"time"
+)
+
+func metricsTunnel(proto, os, user string) *Tunnel {
+	ctl := &Control{id: "client", auth: &msg.Auth{OS: os}}
+	if user != "" {
+		ctl.userInfo = &UserInfo{Uc: &UserConfig{AuthId: user}}
+	}
+	return &Tunnel{req: &msg.ReqTunnel{Protocol: proto}, ctl: ctl}
+}
+
+// Check that the prometheus output of m has these samples
+func expectSamples(t *testing.T, m *LocalMetrics, samples ...string) {
+	var buf bytes.Buffer
+	m.WritePrometheus(&buf)
+
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		lines[line] = true
+	}
+
+	for _, sample := range samples {
+		if !lines[sample] {
+			t.Errorf("missing sample %s in:\n%s", sample, buf.String())
+		}
+	}
+}
+
+func TestLocalMetricsTunnels(t *testing.T) {
+	m := NewLocalMetrics(time.Hour)
+
+	alice := metricsTunnel("http", "linux", "alice")
+	bob := metricsTunnel("tcp", "windows", "bob")
+	anon := metricsTunnel("http", "plan9", "")
+
+	m.OpenTunnel(alice)
+	m.OpenTunnel(bob)
+	m.OpenTunnel(anon)
+	m.CloseTunnel(anon)
+
+	expectSamples(t, m,
+		`ngrokd_tunnels_opened_total{protocol="http"} 2`,
+		`ngrokd_tunnels_opened_total{protocol="tcp"} 1`,
+		`ngrokd_tunnels{protocol="http"} 1`,
+		`ngrokd_tunnels{protocol="tcp"} 1`,
+		`ngrokd_client_os_total{os="linux"} 1`,
+		`ngrokd_client_os_total{os="windows"} 1`,
+		`ngrokd_client_os_total{os="darwin"} 0`,
+		`ngrokd_client_os_total{os="other"} 1`,
+		`ngrokd_user_tunnels{user="alice"} 1`,
+		`ngrokd_user_tunnels{user="bob"} 1`,
+		`ngrokd_user_tunnels{user=""} 0`,
+	)
+
+	m.CloseTunnel(alice)
+	m.CloseTunnel(bob)
+	expectSamples(t, m,
+		`ngrokd_tunnels_opened_total{protocol="http"} 2`,
+		`ngrokd_tunnels{protocol="http"} 0`,
+		`ngrokd_tunnels{protocol="tcp"} 0`,
+		`ngrokd_user_tunnels{user="alice"} 0`,
+	)
+}
+
+func TestLocalMetricsConnections(t *testing.T) {
+	m := NewLocalMetrics(time.Hour)
+	alice := metricsTunnel("http", "darwin", "alice")
+	bob := metricsTunnel("tcp", "linux", "bob")
+
+	m.OpenConnection(alice, nil)
+	m.OpenConnection(alice, nil)
+	m.OpenConnection(bob, nil)
+	m.CloseConnection(alice, nil, time.Now().Add(-2*time.Second), 100, 10)
+
+	expectSamples(t, m,
+		`ngrokd_connections_total{protocol="http"} 2`,
+		`ngrokd_connections_total{protocol="tcp"} 1`,
+		`ngrokd_connections{protocol="http"} 1`,
+		`ngrokd_connections{protocol="tcp"} 1`,
+		`ngrokd_bytes_in_total{protocol="http"} 100`,
+		`ngrokd_bytes_out_total{protocol="http"} 10`,
+		`ngrokd_user_connections_total{user="alice"} 2`,
+		`ngrokd_user_connections_total{user="bob"} 1`,
+		`ngrokd_user_bytes_in_total{user="alice"} 100`,
+		`ngrokd_user_bytes_out_total{user="alice"} 10`,
+		`ngrokd_connection_duration_seconds_count 1`,
+	)
+
+	m.CloseConnection(alice, nil, time.Now(), 50, 5)
+	m.CloseConnection(bob, nil, time.Now(), 7, 3)
+	expectSamples(t, m,
+		`ngrokd_connections{protocol="http"} 0`,
+		`ngrokd_connections{protocol="tcp"} 0`,
+		`ngrokd_bytes_in_total{protocol="http"} 150`,
+		`ngrokd_bytes_in_total{protocol="tcp"} 7`,
+		`ngrokd_user_bytes_out_total{user="alice"} 15`,
+		`ngrokd_user_bytes_out_total{user="bob"} 3`,
+		`ngrokd_connection_duration_seconds_count 3`,
+	)
+}
+
+func TestLocalMetricsEvents(t *testing.T) {
+	m := NewLocalMetrics(time.Hour)
+	ctl := metricsTunnel("http", "linux", "alice").ctl
+
+	m.LostHeartbeat(ctl)
+	m.ProxyTimeout(ctl)
+	m.ProxyTimeout(ctl)
+	m.ProxyWait(ctl, 500*time.Millisecond)
+
+	expectSamples(t, m,
+		`ngrokd_lost_heartbeats_total 1`,
+		`ngrokd_proxy_timeouts_total 2`,
+		`ngrokd_proxy_wait_seconds_sum 0.5`,
+		`ngrokd_proxy_wait_seconds_count 1`,
+		`ngrokd_proxy_wait_seconds{quantile="0.5"} 0.5`,
+	)
+}
+
+func TestMultiMetricsReportsToEveryBackend(t *testing.T) {
+	a, b := NewLocalMetrics(time.Hour), NewLocalMetrics(time.Hour)
+	mm := NewMultiMetrics(a, b)
+
+	alice := metricsTunnel("http", "linux", "alice")
+	mm.OpenTunnel(alice)
+	mm.OpenConnection(alice, nil)
+	mm.CloseConnection(alice, nil, time.Now(), 1, 2)
+
+	for _, m := range []*LocalMetrics{a, b} {
+		expectSamples(t, m,
+			`ngrokd_tunnels{protocol="http"} 1`,
+			`ngrokd_connections_total{protocol="http"} 1`,
+			`ngrokd_connections{protocol="http"} 0`,
+			`ngrokd_bytes_out_total{protocol="http"} 2`,
+		)
+	}
+}
+
+func TestPromEscape(t *testing.T) {
+	m := NewLocalMetrics(time.Hour)
+	m.OpenTunnel(metricsTunnel("http", "linux", "a\"b\\c\nd"))
+	expectSamples(t, m, `ngrokd_user_tunnels{user="a\"b\\c\nd"} 1`)
+}
@@ -0,0 +1,455 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"ngrok/errorpage"
+	"ngrok/log"
+	"ngrok/util"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// the path of every host behind the gate the provider sends users back to
+	oidcCallbackPath = "/_ngrok/oidc/callback"
+
+	oidcSessionCookie = "ngrok_session"
+	oidcStateCookie   = "ngrok_oidc_state"
+
+	// how long a user has to log in at the provider
+	oidcStateTtl = 10 * time.Minute
+
+	oidcTimeout  = 5 * time.Second
+	oidcMaxReply = 64 * 1024
+)
+
+// the headers identifying the user to the tunnel, the request's own are replaced
+var oidcIdentityHeaders = []string{"X-Ngrok-Auth-Email", "X-Ngrok-Auth-Subject", "X-Ngrok-Auth-Name"}
+
+// nil when no OpenID Connect provider is configured
+var oidcGate *OidcGate
+
+/**
+ * OidcGate: makes visitors of the tunnels that ask for it log in with an
+ *           OpenID Connect provider before their requests reach the tunnel
+ */
+type OidcGate struct {
+	log.Logger
+	issuer       string
+	clientId     string
+	clientSecret string
+	sessionTtl   time.Duration
+	client       *http.Client
+
+	// signs the session and state cookies
+	secret []byte
+
+	// the provider's endpoints, fetched the first time they're needed
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+// Who logged in, kept in the session cookie
+type oidcIdentity struct {
+	Email   string `json:"email"`
+	Subject string `json:"sub"`
+	Name    string `json:"name,omitempty"`
+	Host    string `json:"host"`
+	Expires int64  `json:"exp"`
+}
+
+// The headers a request is forwarded to the tunnel with
+func (id *oidcIdentity) headers() map[string]string {
+	return map[string]string{
+		oidcIdentityHeaders[0]: id.Email,
+		oidcIdentityHeaders[1]: id.Subject,
+		oidcIdentityHeaders[2]: id.Name,
+	}
+}
+
+// A login in progress, kept in the state cookie
+type oidcState struct {
+	State   string `json:"state"`
+	Nonce   string `json:"nonce"`
+	Return  string `json:"return"`
+	Expires int64  `json:"exp"`
+}
+
+// The claims of an ID token that are checked
+type oidcClaims struct {
+	Issuer        string          `json:"iss"`
+	Subject       string          `json:"sub"`
+	Audience      json.RawMessage `json:"aud"`
+	Expires       int64           `json:"exp"`
+	Nonce         string          `json:"nonce"`
+	Email         string          `json:"email"`
+	EmailVerified *bool           `json:"email_verified"`
+	Name          string          `json:"name"`
+}
+
+// What the gate needs to know about a public request
+type gateRequest struct {
+	proto   string
+	host    string
+	path    string
+	cookies string
+	accept  string
+}
+
+func NewOidcGate(issuer, clientId, clientSecret, secret string, sessionTtl time.Duration) (*OidcGate, error) {
+	if clientId == "" {
+		return nil, errors.New("An OpenID Connect issuer needs a client id")
+	}
+
+	g := &OidcGate{
+		Logger:       log.NewPrefixLogger("oidc"),
+		issuer:       strings.TrimRight(issuer, "/"),
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		sessionTtl:   sessionTtl,
+		client:       &http.Client{Timeout: oidcTimeout},
+		secret:       []byte(secret),
+	}
+
+	if secret == "" {
+		g.Warn("No -oidcCookieSecret, sessions end when ngrokd restarts and aren't shared by a cluster")
+		g.secret = []byte(util.SecureRandIdOrPanic(32))
+	}
+	return g, nil
+}
+
+func (g *OidcGate) discover() (*oidcDiscovery, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.discovery != nil {
+		return g.discovery, nil
+	}
+
+	resp, err := g.client.Get(g.issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Discovery of %s failed with status %d", g.issuer, resp.StatusCode)
+	}
+
+	var d oidcDiscovery
+	if err = json.NewDecoder(io.LimitReader(resp.Body, oidcMaxReply)).Decode(&d); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimRight(d.Issuer, "/") != g.issuer {
+		return nil, fmt.Errorf("Provider at %s claims to be issuer %s", g.issuer, d.Issuer)
+	}
+
+	g.discovery = &d
+	return g.discovery, nil
+}
+
+// Encode v into a cookie value only the gate can have made
+func (g *OidcGate) sign(v interface{}) string {
+	buf, _ := json.Marshal(v)
+	payload := base64.RawURLEncoding.EncodeToString(buf)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(g.mac(payload))
+}
+
+func (g *OidcGate) verify(value string, v interface{}) bool {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, g.mac(parts[0])) {
+		return false
+	}
+
+	buf, err := base64.RawURLEncoding.DecodeString(parts[0])
+	return err == nil && json.Unmarshal(buf, v) == nil
+}
+
+func (g *OidcGate) mac(payload string) []byte {
+	h := hmac.New(sha256.New, g.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+func cookieValue(cookies, name string) string {
+	r := &http.Request{Header: http.Header{"Cookie": {cookies}}}
+	if c, err := r.Cookie(name); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// Returns true if the tunnel lets in the user with this email
+func (t *Tunnel) oidcAllows(email string) bool {
+	if len(t.req.OidcEmails)+len(t.req.OidcDomains) == 0 {
+		return true
+	}
+
+	email = strings.ToLower(email)
+	for _, allowed := range t.req.OidcEmails {
+		if strings.ToLower(allowed) == email {
+			return true
+		}
+	}
+
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for _, allowed := range t.req.OidcDomains {
+		if strings.ToLower(strings.TrimPrefix(allowed, "@")) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// Check a request to a tunnel behind the gate. Returns who made it if they
+// are logged in and let in, otherwise the status and response to send
+// instead: the provider's login page, or an error.
+func (g *OidcGate) Check(t *Tunnel, r *gateRequest, entry *AccessLogEntry) (id *oidcIdentity, status int, resp []byte) {
+	if g == nil {
+		return nil, 500, errorResponse(t, 500, "This server has no login provider", entry, r.accept)
+	}
+
+	if strings.SplitN(r.path, "?", 2)[0] == oidcCallbackPath {
+		return g.callback(t, r, entry)
+	}
+
+	var session oidcIdentity
+	if g.verify(cookieValue(r.cookies, oidcSessionCookie), &session) && session.Host == r.host && time.Now().Unix() < session.Expires {
+		if !t.oidcAllows(session.Email) {
+			return nil, 403, errorResponse(t, 403, fmt.Sprintf("%s may not use this tunnel", session.Email), entry, r.accept)
+		}
+		return &session, 0, nil
+	}
+
+	// send people to log in, and programs an error
+	if errorpage.WantsJSON(r.accept) || !strings.Contains(r.accept, "text/html") {
+		return nil, 403, errorResponse(t, 403, "Log in with a browser to use this tunnel", entry, r.accept)
+	}
+	return g.login(t, r, entry)
+}
+
+func (g *OidcGate) redirectUri(r *gateRequest) string {
+	return r.proto + "://" + r.host + oidcCallbackPath
+}
+
+// Redirect to the provider's login page
+func (g *OidcGate) login(t *Tunnel, r *gateRequest, entry *AccessLogEntry) (*oidcIdentity, int, []byte) {
+	d, err := g.discover()
+	if err != nil {
+		g.Warn("Failed to discover %s: %v", g.issuer, err)
+		return nil, 502, errorResponse(t, 502, "The login provider can't be reached", entry, r.accept)
+	}
+
+	st := oidcState{
+		State:   util.SecureRandIdOrPanic(16),
+		Nonce:   util.SecureRandIdOrPanic(16),
+		Return:  r.path,
+		Expires: time.Now().Add(oidcStateTtl).Unix(),
+	}
+
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {g.clientId},
+		"redirect_uri":  {g.redirectUri(r)},
+		"scope":         {"openid email profile"},
+		"state":         {st.State},
+		"nonce":         {st.Nonce},
+	}
+
+	location := d.AuthorizationEndpoint
+	if strings.Contains(location, "?") {
+		location += "&" + q.Encode()
+	} else {
+		location += "?" + q.Encode()
+	}
+
+	cookie := &http.Cookie{Name: oidcStateCookie, Value: g.sign(&st), Path: oidcCallbackPath, MaxAge: int(oidcStateTtl.Seconds())}
+	return nil, 302, redirectResponse(location, r, cookie)
+}
+
+// Finish a login: exchange the code for an ID token, check it and who it
+// names, then start a session and send the user where they were going
+func (g *OidcGate) callback(t *Tunnel, r *gateRequest, entry *AccessLogEntry) (*oidcIdentity, int, []byte) {
+	fail := func(status int, message string) (*oidcIdentity, int, []byte) {
+		return nil, status, errorResponse(t, status, message, entry, r.accept)
+	}
+
+	u, err := url.ParseRequestURI(r.path)
+	if err != nil {
+		return fail(400, "The login callback could not be parsed")
+	}
+	q := u.Query()
+
+	if e := q.Get("error"); e != "" {
+		return fail(403, fmt.Sprintf("Login failed: %s", e))
+	}
+
+	var st oidcState
+	if !g.verify(cookieValue(r.cookies, oidcStateCookie), &st) || st.State != q.Get("state") || time.Now().Unix() > st.Expires {
+		return fail(400, "The login expired or was started elsewhere, try again")
+	}
+
+	claims, err := g.exchange(q.Get("code"), r)
+	if err != nil {
+		g.Warn("Login to %s failed: %v", r.host, err)
+		return fail(403, "Login failed")
+	}
+
+	if claims.Nonce != st.Nonce {
+		return fail(403, "Login failed")
+	}
+
+	if claims.Email == "" || (claims.EmailVerified != nil && !*claims.EmailVerified) {
+		return fail(403, "The login provider didn't vouch for an email address")
+	}
+
+	if !t.oidcAllows(claims.Email) {
+		g.Info("%s may not use %s", claims.Email, r.host)
+		return fail(403, fmt.Sprintf("%s may not use this tunnel", claims.Email))
+	}
+
+	id := &oidcIdentity{
+		Email:   claims.Email,
+		Subject: claims.Subject,
+		Name:    claims.Name,
+		Host:    r.host,
+		Expires: time.Now().Add(g.sessionTtl).Unix(),
+	}
+	g.Info("%s logged in to %s", id.Email, r.host)
+
+	// only send users back to paths of the same host
+	location := st.Return
+	if !strings.HasPrefix(location, "/") || strings.HasPrefix(location, "//") || strings.HasPrefix(location, oidcCallbackPath) {
+		location = "/"
+	}
+
+	session := &http.Cookie{Name: oidcSessionCookie, Value: g.sign(id), Path: "/", MaxAge: int(g.sessionTtl.Seconds())}
+	clearState := &http.Cookie{Name: oidcStateCookie, Value: "", Path: oidcCallbackPath, MaxAge: -1}
+	return nil, 302, redirectResponse(location, r, session, clearState)
+}
+
+// Exchange an authorization code for the claims of its ID token. The token
+// comes straight from the provider's token endpoint over TLS, so its issuer,
+// audience and expiry are checked but not its signature.
+func (g *OidcGate) exchange(code string, r *gateRequest) (*oidcClaims, error) {
+	d, err := g.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {g.redirectUri(r)},
+		"client_id":    {g.clientId},
+	}
+
+	req, err := http.NewRequest("POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(g.clientId), url.QueryEscape(g.clientSecret))
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("token endpoint answered with status %d", resp.StatusCode)
+	}
+
+	var tokens struct {
+		IdToken string `json:"id_token"`
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, oidcMaxReply)).Decode(&tokens); err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(tokens.IdToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("no valid ID token in the token response")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, err
+	}
+
+	var claims oidcClaims
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimRight(claims.Issuer, "/") != g.issuer {
+		return nil, fmt.Errorf("ID token issued by %s", claims.Issuer)
+	}
+
+	if !claims.hasAudience(g.clientId) {
+		return nil, errors.New("ID token is for another client")
+	}
+
+	if time.Now().Unix() > claims.Expires {
+		return nil, errors.New("ID token expired")
+	}
+
+	return &claims, nil
+}
+
+// aud is either a string or an array of them
+func (c *oidcClaims) hasAudience(clientId string) bool {
+	var one string
+	if json.Unmarshal(c.Audience, &one) == nil {
+		return one == clientId
+	}
+
+	var many []string
+	if json.Unmarshal(c.Audience, &many) == nil {
+		for _, aud := range many {
+			if aud == clientId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func redirectResponse(location string, r *gateRequest, cookies ...*http.Cookie) []byte {
+	var resp bytes.Buffer
+	resp.WriteString("HTTP/1.0 302 Found\r\n")
+	fmt.Fprintf(&resp, "Location: %s\r\n", location)
+	for _, c := range cookies {
+		c.HttpOnly = true
+		c.Secure = r.proto == "https"
+		c.SameSite = http.SameSiteLaxMode
+		fmt.Fprintf(&resp, "Set-Cookie: %s\r\n", c.String())
+	}
+	resp.WriteString("Cache-Control: no-store\r\n")
+	resp.WriteString("Content-Length: 0\r\n\r\n")
+	return resp.Bytes()
+}
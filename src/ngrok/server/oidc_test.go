@@ -0,0 +1,312 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"ngrok/msg"
+	"testing"
+	"time"
+)
+
+// An OpenID Connect provider answering the code exchange with an ID token
+// made of claims, which a test may change before it logs in
+type mockProvider struct {
+	*httptest.Server
+	issuer string
+	claims map[string]interface{}
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	p := new(mockProvider)
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.issuer,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if r.Method != "POST" || id != "client" || secret != "secret" ||
+			r.FormValue("grant_type") != "authorization_code" || r.FormValue("code") != "good-code" {
+			http.Error(w, "bad request", 400)
+			return
+		}
+
+		payload, _ := json.Marshal(p.claims)
+		token := "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+		json.NewEncoder(w).Encode(map[string]string{"id_token": token})
+	})
+
+	p.Server = httptest.NewServer(mux)
+	p.issuer = p.URL
+	t.Cleanup(p.Close)
+	return p
+}
+
+func setupOidc(t *testing.T) (*OidcGate, *mockProvider, *Tunnel) {
+	oldOpts := opts
+	t.Cleanup(func() { opts = oldOpts })
+	opts = &Options{}
+
+	p := newMockProvider(t)
+	g, err := NewOidcGate(p.URL, "client", "secret", "cookie-secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, p, &Tunnel{req: &msg.ReqTunnel{OidcAuth: true}}
+}
+
+func parseResponse(t *testing.T, raw []byte) *http.Response {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(raw)), nil)
+	if err != nil {
+		t.Fatalf("invalid response %q: %v", raw, err)
+	}
+	return resp
+}
+
+func responseCookie(resp *http.Response, name string) string {
+	for _, c := range resp.Cookies() {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// Start a login, returns the state cookie and the state and nonce sent to
+// the provider
+func startLogin(t *testing.T, g *OidcGate, tunnel *Tunnel) (cookie, state, nonce string) {
+	id, status, raw := g.Check(tunnel, &gateRequest{"https", "app.example.com", "/page?x=1", "", "text/html"}, &AccessLogEntry{})
+	if id != nil || status != 302 {
+		t.Fatalf("got status %d, want a redirect to log in", status)
+	}
+
+	resp := parseResponse(t, raw)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := location.Query()
+	if q.Get("client_id") != "client" || q.Get("redirect_uri") != "https://app.example.com"+oidcCallbackPath {
+		t.Fatalf("unexpected login redirect %s", location)
+	}
+	return responseCookie(resp, oidcStateCookie), q.Get("state"), q.Get("nonce")
+}
+
+func callback(g *OidcGate, tunnel *Tunnel, stateCookie, query string) (*oidcIdentity, int, []byte) {
+	return g.Check(tunnel, &gateRequest{
+		proto:   "https",
+		host:    "app.example.com",
+		path:    oidcCallbackPath + "?" + query,
+		cookies: oidcStateCookie + "=" + stateCookie,
+		accept:  "text/html",
+	}, &AccessLogEntry{})
+}
+
+func goodClaims(p *mockProvider, nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   p.issuer,
+		"sub":   "1234",
+		"aud":   []string{"other", "client"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": nonce,
+		"email": "alice@example.com",
+		"name":  "Alice",
+	}
+}
+
+func TestOidcLogin(t *testing.T) {
+	g, p, tunnel := setupOidc(t)
+
+	// programs get an error instead of a login page
+	if id, status, _ := g.Check(tunnel, &gateRequest{"https", "app.example.com", "/", "", "application/json"}, &AccessLogEntry{}); id != nil || status != 403 {
+		t.Fatalf("got status %d for a program, want 403", status)
+	}
+
+	stateCookie, state, nonce := startLogin(t, g, tunnel)
+	p.claims = goodClaims(p, nonce)
+
+	_, status, raw := callback(g, tunnel, stateCookie, url.Values{"code": {"good-code"}, "state": {state}}.Encode())
+	if status != 302 {
+		t.Fatalf("callback answered %d: %s", status, raw)
+	}
+
+	resp := parseResponse(t, raw)
+	if location := resp.Header.Get("Location"); location != "/page?x=1" {
+		t.Fatalf("sent back to %s, want where the login started", location)
+	}
+
+	session := responseCookie(resp, oidcSessionCookie)
+	if session == "" {
+		t.Fatal("no session cookie")
+	}
+
+	id, status, _ := g.Check(tunnel, &gateRequest{"https", "app.example.com", "/other", oidcSessionCookie + "=" + session, "text/html"}, &AccessLogEntry{})
+	if id == nil {
+		t.Fatalf("session not accepted, status %d", status)
+	}
+	if h := id.headers(); h["X-Ngrok-Auth-Email"] != "alice@example.com" || h["X-Ngrok-Auth-Subject"] != "1234" || h["X-Ngrok-Auth-Name"] != "Alice" {
+		t.Fatalf("unexpected identity headers %v", h)
+	}
+
+	// the session is only good for the host it was made for
+	if id, _, _ = g.Check(tunnel, &gateRequest{"https", "other.example.com", "/", oidcSessionCookie + "=" + session, "text/html"}, &AccessLogEntry{}); id != nil {
+		t.Fatal("session accepted for another host")
+	}
+
+	// nor when it's tampered with
+	if id, _, _ = g.Check(tunnel, &gateRequest{"https", "app.example.com", "/", oidcSessionCookie + "=x" + session, "text/html"}, &AccessLogEntry{}); id != nil {
+		t.Fatal("tampered session accepted")
+	}
+}
+
+func TestOidcDiscovery(t *testing.T) {
+	g, p, _ := setupOidc(t)
+
+	d, err := g.discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.TokenEndpoint != p.URL+"/token" || d.AuthorizationEndpoint != p.URL+"/authorize" {
+		t.Fatalf("unexpected endpoints %+v", d)
+	}
+
+	// a provider claiming to be another issuer isn't trusted
+	g, p, _ = setupOidc(t)
+	p.issuer = "https://evil.example.com"
+	if _, err = g.discover(); err == nil {
+		t.Fatal("accepted a provider for another issuer")
+	}
+}
+
+func TestOidcCallbackRejects(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  func(state string) url.Values
+		claims func(claims map[string]interface{})
+		cookie func(cookie string) string
+		status int
+	}{
+		{name: "state mismatch", status: 400,
+			query: func(state string) url.Values { return url.Values{"code": {"good-code"}, "state": {"other"}} }},
+		{name: "no state cookie", status: 400,
+			cookie: func(string) string { return "" }},
+		{name: "forged state cookie", status: 400,
+			cookie: func(cookie string) string { return "x" + cookie }},
+		{name: "provider error", status: 403,
+			query: func(state string) url.Values { return url.Values{"error": {"access_denied"}, "state": {state}} }},
+		{name: "bad code", status: 403,
+			query: func(state string) url.Values { return url.Values{"code": {"bad-code"}, "state": {state}} }},
+		{name: "nonce mismatch", status: 403,
+			claims: func(c map[string]interface{}) { c["nonce"] = "other" }},
+		{name: "wrong audience", status: 403,
+			claims: func(c map[string]interface{}) { c["aud"] = "other" }},
+		{name: "wrong issuer", status: 403,
+			claims: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired token", status: 403,
+			claims: func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{name: "no email", status: 403,
+			claims: func(c map[string]interface{}) { delete(c, "email") }},
+		{name: "unverified email", status: 403,
+			claims: func(c map[string]interface{}) { c["email_verified"] = false }},
+	}
+
+	for _, c := range cases {
+		g, p, tunnel := setupOidc(t)
+		stateCookie, state, nonce := startLogin(t, g, tunnel)
+
+		p.claims = goodClaims(p, nonce)
+		if c.claims != nil {
+			c.claims(p.claims)
+		}
+
+		query := url.Values{"code": {"good-code"}, "state": {state}}
+		if c.query != nil {
+			query = c.query(state)
+		}
+
+		if c.cookie != nil {
+			stateCookie = c.cookie(stateCookie)
+		}
+
+		id, status, raw := callback(g, tunnel, stateCookie, query.Encode())
+		if id != nil || status != c.status {
+			t.Errorf("%s: got status %d, want %d", c.name, status, c.status)
+		}
+		if responseCookie(parseResponse(t, raw), oidcSessionCookie) != "" {
+			t.Errorf("%s: got a session cookie", c.name)
+		}
+	}
+}
+
+func TestOidcAllowLists(t *testing.T) {
+	cases := []struct {
+		emails, domains []string
+		email           string
+		want            bool
+	}{
+		{nil, nil, "anybody@example.org", true},
+		{[]string{"Alice@Example.com"}, nil, "alice@example.com", true},
+		{[]string{"alice@example.com"}, nil, "bob@example.com", false},
+		{nil, []string{"@example.com"}, "bob@EXAMPLE.com", true},
+		{nil, []string{"example.com"}, "bob@example.com.evil.org", false},
+		{nil, []string{"example.com"}, "bob@sub.example.com", false},
+		{[]string{"carol@other.org"}, []string{"example.com"}, "carol@other.org", true},
+	}
+
+	for _, c := range cases {
+		tunnel := &Tunnel{req: &msg.ReqTunnel{OidcAuth: true, OidcEmails: c.emails, OidcDomains: c.domains}}
+		if got := tunnel.oidcAllows(c.email); got != c.want {
+			t.Errorf("emails %v domains %v allow %s = %v, want %v", c.emails, c.domains, c.email, got, c.want)
+		}
+	}
+
+	// a user who isn't let in gets no session
+	g, p, tunnel := setupOidc(t)
+	tunnel.req.OidcDomains = []string{"example.org"}
+	stateCookie, state, nonce := startLogin(t, g, tunnel)
+	p.claims = goodClaims(p, nonce)
+	if id, status, _ := callback(g, tunnel, stateCookie, url.Values{"code": {"good-code"}, "state": {state}}.Encode()); id != nil || status != 403 {
+		t.Fatalf("got status %d for a user outside the allow list, want 403", status)
+	}
+}
+
+// A connected pair of local TCP connections, the kind conn.Wrap takes
+func TestRewriteRequestStripsIdentityHeaders(t *testing.T) {
+	tunnel := &Tunnel{req: &msg.ReqTunnel{OidcAuth: true}}
+	identity := (&oidcIdentity{Email: "alice@example.com", Subject: "1234"}).headers()
+
+	c := publicConn(t, "GET / HTTP/1.1\r\nHost: app.example.com\r\nX-Ngrok-Auth-Email: mallory@example.com\r\n"+
+		"x-ngrok-auth-name: Mallory\r\nConnection: keep-alive\r\n\r\n"+
+		"GET /second HTTP/1.1\r\nHost: app.example.com\r\nX-Ngrok-Auth-Email: mallory@example.com\r\n\r\n")
+
+	rewritten, err := rewriteRequest(c, tunnel, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.ReadRequest(bufio.NewReader(rewritten))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := req.Header["X-Ngrok-Auth-Email"]; len(got) != 1 || got[0] != "alice@example.com" {
+		t.Errorf("X-Ngrok-Auth-Email is %v, want only the logged in user", got)
+	}
+	if got := req.Header.Get("X-Ngrok-Auth-Name"); got != "" {
+		t.Errorf("X-Ngrok-Auth-Name is %q, want the forged one removed", got)
+	}
+	if !req.Close {
+		t.Error("the request doesn't close the connection, the next one would skip the gate")
+	}
+}
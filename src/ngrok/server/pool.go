@@ -0,0 +1,249 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"ngrok/conn"
+	"ngrok/log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	balanceRoundRobin = "roundrobin"
+	balanceLeastConn  = "leastconn"
+	balanceFailover   = "failover"
+)
+
+func validateBalance(balance string) error {
+	switch balance {
+	case "", balanceRoundRobin, balanceLeastConn, balanceFailover:
+		return nil
+	default:
+		return fmt.Errorf("Unknown balance mode '%s', must be one of: %s, %s, %s", balance, balanceRoundRobin, balanceLeastConn, balanceFailover)
+	}
+}
+
+// TunnelPool holds the tunnels registered at one url. Most urls are
+// served by a single tunnel. Tunnels that ask for a balance mode may share
+// their url with the other tunnels of the same user that ask for the same
+// mode, and public connections are spread across them. In failover mode
+// they all go to the live tunnel with the highest priority instead.
+type TunnelPool struct {
+	url     string
+	balance string
+
+	// tcp tunnels share the listener bound by the first tunnel of the pool
+	listener *net.TCPListener
+	closing  int32
+
+	// round robin position
+	next uint32
+
+	// the tunnel serving a failover pool, to notice when it changes
+	activeMu sync.Mutex
+	active   *Tunnel
+
+	sync.RWMutex
+	tunnels []*Tunnel
+}
+
+func newTunnelPool(url string, t *Tunnel) *TunnelPool {
+	p := &TunnelPool{
+		url:      url,
+		balance:  t.req.Balance,
+		listener: t.listener,
+		tunnels:  []*Tunnel{t},
+	}
+
+	if p.listener != nil {
+		bind(p.listenerName(), p.listener, p.listener)
+		go p.listenTcp()
+	}
+	return p
+}
+
+func (p *TunnelPool) add(t *Tunnel) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.balance == "" || t.req.Balance != p.balance {
+		return fmt.Errorf("The tunnel %s is already registered.", p.url)
+	}
+
+	for _, member := range p.tunnels {
+		if !sameOwner(member.ctl, t.ctl) {
+			return fmt.Errorf("The tunnel %s is already registered by another user.", p.url)
+		}
+
+		if member.ctl == t.ctl {
+			return fmt.Errorf("The tunnel %s is already registered by this client.", p.url)
+		}
+	}
+
+	p.tunnels = append(p.tunnels, t)
+	t.ctl.conn.Info("Joined pool of %d tunnels at %s", len(p.tunnels), p.url)
+	return nil
+}
+
+// Returns true if the tunnels of both controls may share a url. Users are
+// compared by their auth id, as a user's UserInfo is replaced when the user
+// database is reloaded. Clients without a user, such as those that logged in
+// with the -pass password, only share with clients using the same auth token.
+func sameOwner(a, b *Control) bool {
+	if a.userInfo == nil || b.userInfo == nil {
+		return a.userInfo == nil && b.userInfo == nil && a.auth.User != "" && a.auth.User == b.auth.User
+	}
+
+	user := userLabel(a)
+	return user != "" && user == userLabel(b)
+}
+
+// Removes a tunnel from the pool, returns true if it was the last one.
+// A failover pool hands its traffic to the next standby right away.
+func (p *TunnelPool) remove(t *Tunnel) (empty bool) {
+	p.Lock()
+	for i, member := range p.tunnels {
+		if member == t {
+			p.tunnels = append(p.tunnels[:i], p.tunnels[i+1:]...)
+			break
+		}
+	}
+	empty = len(p.tunnels) == 0
+	p.Unlock()
+
+	if p.balance == balanceFailover && !empty {
+		p.Pick()
+	}
+	return
+}
+
+func (p *TunnelPool) close() {
+	atomic.StoreInt32(&p.closing, 1)
+	if p.listener != nil {
+		unbind(p.listenerName())
+		p.listener.Close()
+	}
+}
+
+func (p *TunnelPool) listenerName() string {
+	return tcpListenerName(p.listener.Addr().(*net.TCPAddr).Port)
+}
+
+func (p *TunnelPool) Tunnels() []*Tunnel {
+	p.RLock()
+	defer p.RUnlock()
+	return append([]*Tunnel(nil), p.tunnels...)
+}
+
+// Pick the tunnel that should handle the next public connection. Tunnels
+// whose client has missed its heartbeat are skipped unless none are left.
+func (p *TunnelPool) Pick() *Tunnel {
+	p.RLock()
+	defer p.RUnlock()
+
+	if len(p.tunnels) == 1 && p.balance != balanceFailover {
+		return p.tunnels[0]
+	}
+
+	var live []*Tunnel
+	for _, t := range p.tunnels {
+		if t.Healthy() {
+			live = append(live, t)
+		}
+	}
+	if len(live) == 0 {
+		live = p.tunnels
+	}
+	if len(live) == 0 {
+		return nil
+	}
+
+	switch p.balance {
+	case balanceFailover:
+		best := live[0]
+		for _, t := range live[1:] {
+			if t.req.Priority > best.req.Priority {
+				best = t
+			}
+		}
+		p.setActive(best)
+		return best
+
+	case balanceLeastConn:
+		best := live[0]
+		for _, t := range live[1:] {
+			if atomic.LoadInt64(&t.activeConns) < atomic.LoadInt64(&best.activeConns) {
+				best = t
+			}
+		}
+		return best
+
+	default:
+		n := atomic.AddUint32(&p.next, 1)
+		return live[n%uint32(len(live))]
+	}
+}
+
+// Records which tunnel serves a failover pool, reporting every switch
+// from one client to another
+func (p *TunnelPool) setActive(t *Tunnel) {
+	p.activeMu.Lock()
+	defer p.activeMu.Unlock()
+
+	prev := p.active
+	if prev == t {
+		return
+	}
+
+	p.active = t
+	if prev != nil {
+		log.Warn("Failover of %s from client %s (priority %d) to client %s (priority %d)",
+			p.url, prev.ctl.id, prev.req.Priority, t.ctl.id, t.req.Priority)
+		metrics.Failover(prev, t)
+	}
+}
+
+// Listens for new public tcp connections from the internet and hands
+// each to a tunnel of the pool.
+func (p *TunnelPool) listenTcp() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Warn("listenTcp failed with error %v", r)
+		}
+	}()
+
+	for {
+		// accept public connections
+		tcpConn, err := p.listener.AcceptTCP()
+
+		if err != nil {
+			// not an error, we're shutting down this pool or the server
+			if atomic.LoadInt32(&p.closing) == 1 || isDraining() {
+				return
+			}
+
+			log.Error("Failed to accept new TCP connection for %s: %v", p.url, err)
+			continue
+		}
+
+		conn := conn.Wrap(tcpConn, "pub")
+		conn.AddLogPrefix(p.url)
+		conn.Info("New connection from %v", conn.RemoteAddr())
+
+		t := p.Pick()
+		if t == nil {
+			conn.Warn("No tunnel left to handle the connection")
+			conn.Close()
+			continue
+		}
+
+		go t.HandlePublicConnection(conn, newAccessLogEntry(conn))
+	}
+}
+
+// A tunnel is healthy while its client keeps up with heartbeats
+func (t *Tunnel) Healthy() bool {
+	return atomic.LoadInt32(&t.closing) == 0 && time.Since(t.ctl.LastPing()) < opts.pingTimeout
+}
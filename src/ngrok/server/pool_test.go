@@ -0,0 +1,141 @@
+package server
+
+import (
+	"ngrok/conn"
+	"ngrok/msg"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func poolTunnel(t *testing.T, user, token string) *Tunnel {
+	_, c := tcpPipe(t)
+	ctl := &Control{id: user + token, auth: &msg.Auth{User: token}, conn: conn.Wrap(c, "ctl")}
+	if user != "" {
+		ctl.userInfo = &UserInfo{Uc: &UserConfig{AuthId: user}}
+	}
+	return &Tunnel{req: &msg.ReqTunnel{Protocol: "http", Balance: balanceRoundRobin}, ctl: ctl}
+}
+
+func TestPoolSharedBySameUser(t *testing.T) {
+	first := poolTunnel(t, "alice", "tok")
+	p := newTunnelPool("http://a.example.com", first)
+
+	// a reload of the user database gives the next client of alice a new UserInfo
+	if err := p.add(poolTunnel(t, "alice", "tok")); err != nil {
+		t.Fatalf("alice can't join her own pool: %v", err)
+	}
+
+	for _, other := range []*Tunnel{
+		poolTunnel(t, "bob", "tok"),
+		poolTunnel(t, "", "tok"),
+		poolTunnel(t, "", ""),
+	} {
+		if err := p.add(other); err == nil {
+			t.Errorf("user %q with token %q joined alice's pool", userLabel(other.ctl), other.ctl.auth.User)
+		}
+	}
+
+	if err := p.add(first); err == nil {
+		t.Error("the same client joined twice")
+	}
+}
+
+func TestPoolWithoutUser(t *testing.T) {
+	p := newTunnelPool("http://a.example.com", poolTunnel(t, "", "tok"))
+
+	if err := p.add(poolTunnel(t, "", "other")); err == nil {
+		t.Error("a client with another token joined")
+	}
+	if err := p.add(poolTunnel(t, "alice", "tok")); err == nil {
+		t.Error("a user joined the pool of a client without one")
+	}
+	if err := p.add(poolTunnel(t, "", "tok")); err != nil {
+		t.Errorf("a client with the same token can't join: %v", err)
+	}
+
+	// nor do clients without a token share
+	p = newTunnelPool("http://b.example.com", poolTunnel(t, "", ""))
+	if err := p.add(poolTunnel(t, "", "")); err == nil {
+		t.Error("clients without a token shared a pool")
+	}
+}
+
+// A failover pool of live tunnels with the given priorities, with the
+// failovers counted in a LocalMetrics
+func failoverPool(t *testing.T, priorities ...int) (*TunnelPool, []*Tunnel, *LocalMetrics) {
+	oldOpts, oldMetrics := opts, metrics
+	t.Cleanup(func() { opts, metrics = oldOpts, oldMetrics })
+	opts = &Options{pingTimeout: time.Minute}
+	local := NewLocalMetrics(time.Hour)
+	metrics = local
+
+	var tunnels []*Tunnel
+	for _, priority := range priorities {
+		tun := poolTunnel(t, "alice", "tok")
+		tun.req.Balance = balanceFailover
+		tun.req.Priority = priority
+		tun.ctl.lastPing = time.Now().UnixNano()
+		tunnels = append(tunnels, tun)
+	}
+
+	p := newTunnelPool("http://a.example.com", tunnels[0])
+	for _, tun := range tunnels[1:] {
+		if err := p.add(tun); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p, tunnels, local
+}
+
+func TestFailoverPicksHighestPriority(t *testing.T) {
+	p, tunnels, m := failoverPool(t, 1, 3, 2)
+
+	for i := 0; i < 3; i++ {
+		if got := p.Pick(); got != tunnels[1] {
+			t.Fatalf("picked the tunnel with priority %d, want 3", got.req.Priority)
+		}
+	}
+
+	// the first pick isn't a failover
+	expectSamples(t, m, "ngrokd_failovers_total 0")
+}
+
+func TestFailoverTakesOver(t *testing.T) {
+	p, tunnels, m := failoverPool(t, 1, 3, 2)
+	p.Pick()
+
+	// the active client stops sending heartbeats
+	tunnels[1].ctl.lastPing = time.Now().Add(-time.Hour).UnixNano()
+	if got := p.Pick(); got != tunnels[2] {
+		t.Fatalf("picked the tunnel with priority %d, want 2", got.req.Priority)
+	}
+	expectSamples(t, m, "ngrokd_failovers_total 1")
+
+	// the standby goes away too, the last one is picked right away
+	atomic.StoreInt32(&tunnels[2].closing, 1)
+	p.remove(tunnels[2])
+	expectSamples(t, m, "ngrokd_failovers_total 2")
+	if got := p.Pick(); got != tunnels[0] {
+		t.Fatalf("picked the tunnel with priority %d, want 1", got.req.Priority)
+	}
+
+	// once it's back, the top tunnel takes its traffic again
+	tunnels[1].ctl.lastPing = time.Now().UnixNano()
+	if got := p.Pick(); got != tunnels[1] {
+		t.Fatalf("picked the tunnel with priority %d, want 3", got.req.Priority)
+	}
+	expectSamples(t, m, "ngrokd_failovers_total 3")
+}
+
+func TestFailoverWithoutLiveTunnels(t *testing.T) {
+	p, tunnels, _ := failoverPool(t, 1, 2)
+	for _, tun := range tunnels {
+		tun.ctl.lastPing = 0
+	}
+
+	// better a tunnel that may be dead than none at all
+	if got := p.Pick(); got != tunnels[1] {
+		t.Errorf("picked the tunnel with priority %d, want 2", got.req.Priority)
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+var promQuantiles = []float64{0.5, 0.9, 0.99}
+
+// Serves the local metrics in the prometheus text exposition format
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	localMetrics.WritePrometheus(w)
+}
+
+func (m *LocalMetrics) WritePrometheus(w io.Writer) {
+	p := &promWriter{w: w}
+
+	p.header("ngrokd_tunnels_opened_total", "counter", "Tunnels opened since start")
+	m.tunnelCounts.Each(func(proto string, v int64) { p.sample("ngrokd_tunnels_opened_total", "protocol", proto, v) })
+
+	p.header("ngrokd_tunnels", "gauge", "Tunnels currently open")
+	m.tunnelGauges.Each(func(proto string, v int64) { p.sample("ngrokd_tunnels", "protocol", proto, v) })
+
+	p.header("ngrokd_connections_total", "counter", "Public connections accepted since start")
+	m.connCounts.Each(func(proto string, v int64) { p.sample("ngrokd_connections_total", "protocol", proto, v) })
+
+	p.header("ngrokd_connections", "gauge", "Public connections currently open")
+	m.connGauges.Each(func(proto string, v int64) { p.sample("ngrokd_connections", "protocol", proto, v) })
+
+	p.header("ngrokd_bytes_in_total", "counter", "Bytes proxied from tunnel clients to public connections")
+	m.bytesIn.Each(func(proto string, v int64) { p.sample("ngrokd_bytes_in_total", "protocol", proto, v) })
+
+	p.header("ngrokd_bytes_out_total", "counter", "Bytes proxied from public connections to tunnel clients")
+	m.bytesOut.Each(func(proto string, v int64) { p.sample("ngrokd_bytes_out_total", "protocol", proto, v) })
+
+	p.header("ngrokd_client_os_total", "counter", "Tunnels opened by client operating system")
+	p.sample("ngrokd_client_os_total", "os", "windows", m.windowsCounter.Count())
+	p.sample("ngrokd_client_os_total", "os", "linux", m.linuxCounter.Count())
+	p.sample("ngrokd_client_os_total", "os", "darwin", m.osxCounter.Count())
+	p.sample("ngrokd_client_os_total", "os", "other", m.otherCounter.Count())
+
+	p.summary("ngrokd_connection_duration_seconds", "Duration of public connections", m.connTimer)
+
+	p.header("ngrokd_lost_heartbeats_total", "counter", "Control connections reaped for missing heartbeats")
+	p.sample("ngrokd_lost_heartbeats_total", "", "", m.lostHeartbeatMeter.Count())
+
+	p.summary("ngrokd_proxy_wait_seconds", "Time spent waiting for a proxy connection when the pool was empty", m.proxyWaitTimer)
+
+	p.header("ngrokd_proxy_timeouts_total", "counter", "Public connections dropped because no proxy connection arrived in time")
+	p.sample("ngrokd_proxy_timeouts_total", "", "", m.proxyTimeoutCounter.Count())
+
+	p.header("ngrokd_failovers_total", "counter", "Times a failover tunnel's traffic moved to a standby client")
+	p.sample("ngrokd_failovers_total", "", "", m.failoverCounter.Count())
+
+	p.header("ngrokd_user_tunnels", "gauge", "Tunnels currently open by user")
+	m.userTunnelGauges.Each(func(user string, v int64) { p.sample("ngrokd_user_tunnels", "user", user, v) })
+
+	p.header("ngrokd_user_connections_total", "counter", "Public connections accepted by user")
+	m.userConnCounts.Each(func(user string, v int64) { p.sample("ngrokd_user_connections_total", "user", user, v) })
+
+	p.header("ngrokd_user_bytes_in_total", "counter", "Bytes proxied from tunnel clients to public connections by user")
+	m.userBytesIn.Each(func(user string, v int64) { p.sample("ngrokd_user_bytes_in_total", "user", user, v) })
+
+	p.header("ngrokd_user_bytes_out_total", "counter", "Bytes proxied from public connections to tunnel clients by user")
+	m.userBytesOut.Each(func(user string, v int64) { p.sample("ngrokd_user_bytes_out_total", "user", user, v) })
+}
+
+type promWriter struct {
+	w io.Writer
+}
+
+func (p *promWriter) header(name, typ, help string) {
+	fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (p *promWriter) sample(name, label, value string, v int64) {
+	if label == "" {
+		fmt.Fprintf(p.w, "%s %d\n", name, v)
+	} else {
+		fmt.Fprintf(p.w, "%s{%s=\"%s\"} %d\n", name, label, promEscape(value), v)
+	}
+}
+
+// go-metrics timers keep a sample of durations rather than fixed buckets,
+// so they are exposed as prometheus summaries
+func (p *promWriter) summary(name, help string, t gometrics.Timer) {
+	t = t.Snapshot()
+	seconds := func(ns float64) string {
+		return strconv.FormatFloat(ns/float64(time.Second), 'g', -1, 64)
+	}
+
+	p.header(name, "summary", help)
+	for i, v := range t.Percentiles(promQuantiles) {
+		q := strconv.FormatFloat(promQuantiles[i], 'g', -1, 64)
+		fmt.Fprintf(p.w, "%s{quantile=\"%s\"} %s\n", name, q, seconds(v))
+	}
+	fmt.Fprintf(p.w, "%s_sum %s\n", name, seconds(float64(t.Sum())))
+	fmt.Fprintf(p.w, "%s_count %d\n", name, t.Count())
+}
+
+func promEscape(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
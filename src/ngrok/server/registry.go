@@ -6,6 +6,7 @@ import (
 	"net"
 	"ngrok/cache"
 	"ngrok/log"
+	"strings"
 	"sync"
 	"time"
 )
@@ -20,18 +21,27 @@ func (url cacheUrl) Size() int {
 	return len(url)
 }
 
-// TunnelRegistry maps a tunnel URL to Tunnel structures
+// TunnelRegistry maps a tunnel URL to the pool of Tunnel structures serving it
 type TunnelRegistry struct {
-	tunnels  map[string]*Tunnel
+	tunnels  map[string]*TunnelPool
 	affinity *cache.LRUCache
+
+	// number of tunnel urls routed by path under each host url
+	routed map[string]int
+
+	// held while a url is claimed or released in the cluster, which goes
+	// over the network, instead of the lock that routing needs
+	claimMu sync.Mutex
+
 	log.Logger
 	sync.RWMutex
 }
 
 func NewTunnelRegistry(cacheSize uint64, cacheFile string) *TunnelRegistry {
 	registry := &TunnelRegistry{
-		tunnels:  make(map[string]*Tunnel),
+		tunnels:  make(map[string]*TunnelPool),
 		affinity: cache.NewLRUCache(cacheSize),
+		routed:   make(map[string]int),
 		Logger:   log.NewPrefixLogger("registry", "tun"),
 	}
 
@@ -76,20 +86,59 @@ func (r *TunnelRegistry) SaveCacheThread(path string, interval time.Duration) {
 }
 
 // Register a tunnel with a specific url, returns an error
-// if a tunnel is already registered at that url
+// if a tunnel is already registered at that url and can't share it
 func (r *TunnelRegistry) Register(url string, t *Tunnel) error {
-	r.Lock()
-	defer r.Unlock()
+	r.claimMu.Lock()
+	defer r.claimMu.Unlock()
 
-	if r.tunnels[url] != nil {
-		return fmt.Errorf("The tunnel %s is already registered.", url)
+	// pools are only made and dropped with claimMu held, so the url stays
+	// unregistered while it's claimed
+	r.RLock()
+	p := r.tunnels[url]
+	r.RUnlock()
+
+	if p != nil {
+		r.Lock()
+		defer r.Unlock()
+		return p.add(t)
 	}
 
-	r.tunnels[url] = t
+	if err := cluster.ClaimTunnel(url, func() { r.lost(url) }); err != nil {
+		return err
+	}
+
+	r.Lock()
+	r.tunnels[url] = newTunnelPool(url, t)
+	if host, prefix := splitRouteUrl(url); prefix != "" {
+		r.routed[host]++
+	}
+	r.Unlock()
 
 	return nil
 }
 
+// Close the tunnels at url once another node of the cluster has claimed it
+func (r *TunnelRegistry) lost(url string) {
+	for _, t := range r.Pool(url) {
+		t.Warn("Closing, another node now serves %s", url)
+		t.ctl.CloseTunnel(url)
+	}
+}
+
+// Add a tunnel to the pool already registered at url, returns an error
+// if there is none or the tunnel can't join it
+func (r *TunnelRegistry) Join(url string, t *Tunnel) error {
+	r.Lock()
+	defer r.Unlock()
+
+	p := r.tunnels[url]
+	if p == nil {
+		return fmt.Errorf("No tunnel is registered at %s", url)
+	}
+
+	return p.add(t)
+}
+
 func (r *TunnelRegistry) cacheKeys(t *Tunnel) (ip string, id string) {
 	clientIp := t.ctl.conn.RemoteAddr().(*net.TCPAddr).IP.String()
 	clientId := t.ctl.id
@@ -100,6 +149,19 @@ func (r *TunnelRegistry) cacheKeys(t *Tunnel) (ip string, id string) {
 }
 
 func (r *TunnelRegistry) GetCachedRegistration(t *Tunnel) (url string) {
+	// a url reserved for the user beats anything cached for the client,
+	// skipping those already served by the user's other tunnels
+	for _, reserved := range cMgr.Reservations(t.ctl.userInfo, t.req.Protocol) {
+		r.RLock()
+		_, taken := r.tunnels[reserved]
+		r.RUnlock()
+
+		if !taken {
+			t.Debug("Found reservation %s for user", reserved)
+			return reserved
+		}
+	}
+
 	ipCacheKey, idCacheKey := r.cacheKeys(t)
 
 	// check cache for ID first, because we prefer that over IP which might
@@ -120,6 +182,11 @@ func (r *TunnelRegistry) RegisterAndCache(url string, t *Tunnel) (err error) {
 		ipCacheKey, idCacheKey := r.cacheKeys(t)
 		r.affinity.Set(ipCacheKey, cacheUrl(url))
 		r.affinity.Set(idCacheKey, cacheUrl(url))
+
+		// and keep it for the user, unless they asked for this port
+		if t.req.RemotePort == 0 {
+			cMgr.Reserve(t.ctl.userInfo, t.req.Protocol, url)
+		}
 	}
 	return
 
@@ -136,6 +203,11 @@ func (r *TunnelRegistry) RegisterRepeat(urlFn func() string, t *Tunnel) (string,
 
 	maxAttempts := 5
 	for i := 0; i < maxAttempts; i++ {
+		if cMgr.ReservedByOther(t.ctl.userInfo, url) {
+			url = urlFn()
+			continue
+		}
+
 		if err := r.RegisterAndCache(url, t); err != nil {
 			// pick a new url and try again
 			url = urlFn()
@@ -148,16 +220,124 @@ func (r *TunnelRegistry) RegisterRepeat(urlFn func() string, t *Tunnel) (string,
 	return "", fmt.Errorf("Failed to assign a URL after %d attempts!", maxAttempts)
 }
 
-func (r *TunnelRegistry) Del(url string) {
+// Remove a tunnel from the pool at url. The url is released once
+// the last tunnel of the pool is gone.
+func (r *TunnelRegistry) Del(url string, t *Tunnel) {
+	r.claimMu.Lock()
+	defer r.claimMu.Unlock()
+
 	r.Lock()
-	defer r.Unlock()
-	delete(r.tunnels, url)
+	p := r.tunnels[url]
+	if p == nil {
+		r.Unlock()
+		return
+	}
+
+	last := p.remove(t)
+	if last {
+		p.close()
+		delete(r.tunnels, url)
+		if host, prefix := splitRouteUrl(url); prefix != "" {
+			if r.routed[host]--; r.routed[host] == 0 {
+				delete(r.routed, host)
+			}
+		}
+	}
+	r.Unlock()
+
+	if last {
+		cluster.ReleaseTunnel(url)
+	}
 }
 
+// Get returns the tunnel that should serve the next connection to url,
+// falling back to the longest wildcard that matches it
 func (r *TunnelRegistry) Get(url string) *Tunnel {
+	r.RLock()
+	var p *TunnelPool
+	for _, candidate := range matchingUrls(url) {
+		if p = r.tunnels[candidate]; p != nil {
+			break
+		}
+	}
+	r.RUnlock()
+
+	if p == nil {
+		return nil
+	}
+	return p.Pick()
+}
+
+// Route returns the tunnel that should serve the next request for path at
+// hostUrl, matching the longest wildcard and then the longest path prefix.
+// routed is true if the matching host has tunnels routed by path.
+func (r *TunnelRegistry) Route(hostUrl, path string) (t *Tunnel, routed bool) {
+	r.RLock()
+	var p *TunnelPool
+	for _, candidate := range routeUrls(hostUrl, path) {
+		if p = r.tunnels[candidate]; p != nil {
+			host, _ := splitRouteUrl(candidate)
+			routed = r.routed[host] > 0
+			break
+		}
+	}
+	r.RUnlock()
+
+	if p == nil {
+		return nil, false
+	}
+	return p.Pick(), routed
+}
+
+// The urls that may serve a public url, most specific first: the url itself
+// and then, for http(s), a wildcard for each parent domain. For
+// http://a.b.example.com that's http://*.b.example.com, http://*.example.com
+// and http://*.com.
+func matchingUrls(url string) []string {
+	urls := []string{url}
+
+	parts := strings.SplitN(url, "://", 2)
+	if len(parts) != 2 || (parts[0] != "http" && parts[0] != "https") {
+		return urls
+	}
+
+	labels := strings.Split(parts[1], ".")
+	for i := 1; i < len(labels); i++ {
+		urls = append(urls, parts[0]+"://*."+strings.Join(labels[i:], "."))
+	}
+	return urls
+}
+
+// A wildcard may only stand for the whole leftmost label, e.g. *.team
+func validateWildcard(name string) error {
+	if strings.Contains(name, "*") && (!strings.HasPrefix(name, "*.") || strings.Count(name, "*") > 1) {
+		return fmt.Errorf("Invalid name %s, a wildcard must be the whole first label, e.g. *.team", name)
+	}
+	return nil
+}
+
+// Pool returns every tunnel registered at url
+func (r *TunnelRegistry) Pool(url string) []*Tunnel {
+	r.RLock()
+	p := r.tunnels[url]
+	r.RUnlock()
+
+	if p == nil {
+		return nil
+	}
+	return p.Tunnels()
+}
+
+// All returns every registered tunnel
+func (r *TunnelRegistry) All() []*Tunnel {
 	r.RLock()
 	defer r.RUnlock()
-	return r.tunnels[url]
+
+	tunnels := make([]*Tunnel, 0, len(r.tunnels))
+	for _, p := range r.tunnels {
+		tunnels = append(tunnels, p.Tunnels()...)
+	}
+	return tunnels
 }
 
 // ControlRegistry maps a client ID to Control structures
@@ -190,6 +370,7 @@ func (r *ControlRegistry) Add(clientId string, ctl *Control) (oldCtl *Control) {
 	}
 
 	r.controls[clientId] = ctl
+	cluster.ClaimControl(clientId, ctl)
 	r.Info("Registered control with id %s", clientId)
 	return
 }
@@ -202,6 +383,19 @@ func (r *ControlRegistry) Del(clientId string) error {
 	} else {
 		r.Info("Removed control registry id %s", clientId)
 		delete(r.controls, clientId)
+		cluster.ReleaseControl(clientId)
 		return nil
 	}
 }
+
+// All returns every registered control
+func (r *ControlRegistry) All() []*Control {
+	r.RLock()
+	defer r.RUnlock()
+
+	controls := make([]*Control, 0, len(r.controls))
+	for _, c := range r.controls {
+		controls = append(controls, c)
+	}
+	return controls
+}
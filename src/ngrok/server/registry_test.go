@@ -0,0 +1,187 @@
+package server
+
+import (
+	"ngrok/log"
+	"ngrok/msg"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A backend whose claims wait until the test lets them through
+type slowBackend struct {
+	*MemoryBackend
+	claiming chan string
+	proceed  chan bool
+}
+
+func (b *slowBackend) SetNX(key, value string, ttl time.Duration) (string, error) {
+	b.claiming <- key
+	<-b.proceed
+	return b.MemoryBackend.SetNX(key, value, ttl)
+}
+
+func setupCluster(t *testing.T, backend ClusterBackend) *Cluster {
+	oldCluster := cluster
+	t.Cleanup(func() { cluster = oldCluster })
+
+	cluster = &Cluster{
+		Logger:  log.NewPrefixLogger("cluster", "a"),
+		backend: backend,
+		node:    "a",
+		claims:  make(map[string]func()),
+	}
+	return cluster
+}
+
+func testTunnel(url string) *Tunnel {
+	return &Tunnel{
+		url:    url,
+		req:    &msg.ReqTunnel{Protocol: "http"},
+		ctl:    &Control{id: "client-" + url, in: make(chan msg.Message, 1)},
+		Logger: log.NewPrefixLogger("tun", url),
+	}
+}
+
+func TestRegisterClaimsOutsideLock(t *testing.T) {
+	backend := &slowBackend{NewMemoryBackend(), make(chan string), make(chan bool)}
+	setupCluster(t, backend)
+	r := &TunnelRegistry{tunnels: make(map[string]*TunnelPool), routed: make(map[string]int), Logger: log.NewPrefixLogger("registry")}
+
+	served := testTunnel("http://a.example.com")
+	r.tunnels[served.url] = newTunnelPool(served.url, served)
+
+	done := make(chan error)
+	go func() { done <- r.Register("http://b.example.com", testTunnel("http://b.example.com")) }()
+	<-backend.claiming
+
+	// routing goes on while the cluster is slow to answer
+	routed := make(chan *Tunnel)
+	go func() { routed <- r.Get(served.url) }()
+	select {
+	case got := <-routed:
+		if got != served {
+			t.Fatalf("routed to %v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("routing waited for a claim")
+	}
+
+	backend.proceed <- true
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if owner, _ := backend.Get(clusterTunnelKey + "http://b.example.com"); owner != "a" {
+		t.Fatalf("url claimed by %q", owner)
+	}
+}
+
+func TestRegisterClaimedByOtherNode(t *testing.T) {
+	backend := NewMemoryBackend()
+	c := setupCluster(t, backend)
+	r := &TunnelRegistry{tunnels: make(map[string]*TunnelPool), routed: make(map[string]int), Logger: log.NewPrefixLogger("registry")}
+
+	backend.Set(clusterTunnelKey+"http://a.example.com", "b", clusterClaimTTL)
+	if err := r.Register("http://a.example.com", testTunnel("http://a.example.com")); err == nil {
+		t.Fatal("registered a url another node serves")
+	}
+	if r.Get("http://a.example.com") != nil || len(c.claims) != 0 {
+		t.Fatal("failed registration left the url behind")
+	}
+}
+
+func TestLostClaimClosesTunnel(t *testing.T) {
+	backend := NewMemoryBackend()
+	c := setupCluster(t, backend)
+	r := &TunnelRegistry{tunnels: make(map[string]*TunnelPool), routed: make(map[string]int), Logger: log.NewPrefixLogger("registry")}
+
+	tun := testTunnel("http://a.example.com")
+	if err := r.Register(tun.url, tun); err != nil {
+		t.Fatal(err)
+	}
+
+	// another node took the url over, e.g. after this one stalled
+	backend.Set(clusterTunnelKey+tun.url, "b", clusterClaimTTL)
+	c.refreshClaims()
+
+	select {
+	case m := <-tun.ctl.in:
+		if closing, ok := m.(*closeTunnel); !ok || closing.url != tun.url {
+			t.Fatalf("control got %#v, want the tunnel closed", m)
+		}
+	default:
+		t.Fatal("tunnel kept open after its url was lost")
+	}
+
+	// unregistering doesn't release the other node's claim
+	r.Del(tun.url, tun)
+	if owner, _ := backend.Get(clusterTunnelKey + tun.url); owner != "b" {
+		t.Fatalf("url now claimed by %q", owner)
+	}
+}
+
+func TestMatchingUrls(t *testing.T) {
+	cases := []struct {
+		url  string
+		want []string
+	}{
+		{"http://a.b.example.com", []string{"http://a.b.example.com", "http://*.b.example.com", "http://*.example.com", "http://*.com"}},
+		{"https://example.com:8443", []string{"https://example.com:8443", "https://*.com:8443"}},
+		{"http://localhost", []string{"http://localhost"}},
+
+		// tcp urls are only ever served by their own tunnel
+		{"tcp://a.example.com:5000", []string{"tcp://a.example.com:5000"}},
+		{"a.example.com", []string{"a.example.com"}},
+	}
+
+	for _, c := range cases {
+		if got := matchingUrls(c.url); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("matchingUrls(%q) = %q, want %q", c.url, got, c.want)
+		}
+	}
+}
+
+func TestGetPrefersMostSpecific(t *testing.T) {
+	r := &TunnelRegistry{tunnels: make(map[string]*TunnelPool), routed: make(map[string]int), Logger: log.NewPrefixLogger("registry")}
+	for _, url := range []string{
+		"http://a.team.example.com",
+		"http://*.team.example.com",
+		"http://*.example.com",
+	} {
+		if err := r.Register(url, testTunnel(url)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[string]string{
+		"http://a.team.example.com":   "http://a.team.example.com",
+		"http://b.team.example.com":   "http://*.team.example.com",
+		"http://x.b.team.example.com": "http://*.team.example.com",
+		"http://team.example.com":     "http://*.example.com",
+		"http://example.com":          "",
+		"https://a.team.example.com":  "",
+	}
+	for url, want := range cases {
+		got := ""
+		if tunnel := r.Get(url); tunnel != nil {
+			got = tunnel.url
+		}
+		if got != want {
+			t.Errorf("%s is served by %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestValidateWildcard(t *testing.T) {
+	for _, name := range []string{"team", "*.team", "*.a.team", "a-b"} {
+		if err := validateWildcard(name); err != nil {
+			t.Errorf("%s was rejected: %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"*", "*team", "a.*.team", "team.*", "*.*.team", "*.team*", "a*b"} {
+		if err := validateWildcard(name); err == nil {
+			t.Errorf("%s was accepted", name)
+		}
+	}
+}
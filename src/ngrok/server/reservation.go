@@ -0,0 +1,156 @@
+package server
+
+import (
+	"ngrok/log"
+	"sort"
+	"time"
+)
+
+// at most this many urls are reserved for a user per protocol, the least
+// recently used is given up first
+const reservationMax = 10
+
+// A random url a user was given. It's handed back to them the next time
+// they ask for a random url of the same protocol, and isn't given to
+// anybody else until it goes unused for -reservationTtl.
+type Reservation struct {
+	Protocol string    `json:"protocol"`
+	Url      string    `json:"url"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+func (r *Reservation) expired() bool {
+	return time.Since(r.LastUsed) > opts.reservationTtl
+}
+
+func reservationsEnabled(ui *UserInfo) bool {
+	return ui != nil && opts.reservationTtl > 0
+}
+
+// must be called with the lock held
+func (mgr *ConfigMgr) indexReservations(ui *UserInfo) {
+	for _, r := range ui.Uc.Reservations {
+		mgr.reserved[r.Url] = ui
+	}
+}
+
+// must be called with the lock held
+func (mgr *ConfigMgr) unindexReservations(ui *UserInfo) {
+	for _, r := range ui.Uc.Reservations {
+		if mgr.reserved[r.Url] == ui {
+			delete(mgr.reserved, r.Url)
+		}
+	}
+}
+
+// Urls reserved for a user for protocol, most recently used first
+func (mgr *ConfigMgr) Reservations(ui *UserInfo, protocol string) (urls []string) {
+	if !reservationsEnabled(ui) {
+		return
+	}
+
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+
+	var rs []Reservation
+	for _, r := range ui.Uc.Reservations {
+		if r.Protocol == protocol && !r.expired() {
+			rs = append(rs, r)
+		}
+	}
+
+	sort.Sort(reservationsByUse(rs))
+	for _, r := range rs {
+		urls = append(urls, r.Url)
+	}
+	return
+}
+
+// Returns true if url is reserved for a user other than ui
+func (mgr *ConfigMgr) ReservedByOther(ui *UserInfo, url string) bool {
+	if opts.reservationTtl <= 0 {
+		return false
+	}
+
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+
+	owner := mgr.reserved[url]
+	if owner == nil || owner == ui {
+		return false
+	}
+
+	for _, r := range owner.Uc.Reservations {
+		if r.Url == url {
+			return !r.expired()
+		}
+	}
+	return false
+}
+
+// Reserve url for a user, or mark it as used now if it already is
+func (mgr *ConfigMgr) Reserve(ui *UserInfo, protocol, url string) {
+	if !reservationsEnabled(ui) {
+		return
+	}
+
+	mgr.mu.Lock()
+	mgr.unindexReservations(ui)
+
+	// drop expired reservations and the one being renewed
+	var mine, others []Reservation
+	for _, r := range ui.Uc.Reservations {
+		if r.Url == url || r.expired() {
+			continue
+		} else if r.Protocol == protocol {
+			mine = append(mine, r)
+		} else {
+			others = append(others, r)
+		}
+	}
+
+	mine = append([]Reservation{{Protocol: protocol, Url: url, LastUsed: time.Now()}}, mine...)
+	sort.Sort(reservationsByUse(mine))
+	if len(mine) > reservationMax {
+		mine = mine[:reservationMax]
+	}
+
+	ui.Uc.Reservations = append(mine, others...)
+	mgr.indexReservations(ui)
+	uc := ui.Uc.copy()
+	mgr.mu.Unlock()
+
+	if err := mgr.db.Save(mgr, uc); err != nil {
+		log.Warn("Failed to save reservation of %s for %s: %v", url, uc.AuthId, err)
+	}
+}
+
+// Mark a url reserved for a user as used now, e.g. when its tunnel closes
+func (mgr *ConfigMgr) TouchReservation(ui *UserInfo, url string) {
+	if !reservationsEnabled(ui) {
+		return
+	}
+
+	mgr.mu.Lock()
+	touched := false
+	for i := range ui.Uc.Reservations {
+		if ui.Uc.Reservations[i].Url == url {
+			ui.Uc.Reservations[i].LastUsed = time.Now()
+			touched = true
+		}
+	}
+	uc := ui.Uc.copy()
+	mgr.mu.Unlock()
+
+	if touched {
+		if err := mgr.db.Save(mgr, uc); err != nil {
+			log.Warn("Failed to save reservation of %s for %s: %v", url, uc.AuthId, err)
+		}
+	}
+}
+
+type reservationsByUse []Reservation
+
+func (s reservationsByUse) Len() int           { return len(s) }
+func (s reservationsByUse) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s reservationsByUse) Less(i, j int) bool { return s[i].LastUsed.After(s[j].LastUsed) }
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// records the configs saved to it
+type savingDb struct {
+	saved []*UserConfig
+}
+
+func (db *savingDb) Save(mgr *ConfigMgr, uc *UserConfig) error {
+	db.saved = append(db.saved, uc)
+	return nil
+}
+
+func (db *savingDb) LoadAll(mgr *ConfigMgr) error { return nil }
+
+// A user database with users a and b, keeping reservations for an hour
+func setupReservations(t *testing.T) (*ConfigMgr, *savingDb) {
+	oldOpts := opts
+	t.Cleanup(func() { opts = oldOpts })
+	opts = &Options{reservationTtl: time.Hour}
+
+	db := new(savingDb)
+	mgr := &ConfigMgr{
+		db:       db,
+		users:    make(map[string]*UserInfo),
+		dns:      make(map[string]*UserInfo),
+		reserved: make(map[string]*UserInfo),
+	}
+	for _, id := range []string{"a", "b"} {
+		if err := mgr.AddUserConfig(&UserConfig{AuthId: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mgr, db
+}
+
+// Make the reservation of url look used d ago
+func age(ui *UserInfo, url string, d time.Duration) {
+	for i := range ui.Uc.Reservations {
+		if ui.Uc.Reservations[i].Url == url {
+			ui.Uc.Reservations[i].LastUsed = time.Now().Add(-d)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	mgr, db := setupReservations(t)
+	a := mgr.users["a"]
+
+	mgr.Reserve(a, "http", "http://one.example.com")
+	mgr.Reserve(a, "tcp", "tcp://example.com:4000")
+	age(a, "http://one.example.com", time.Minute)
+	mgr.Reserve(a, "http", "http://two.example.com")
+
+	want := []string{"http://two.example.com", "http://one.example.com"}
+	if got := mgr.Reservations(a, "http"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got reservations %q, want %q", got, want)
+	}
+	if got := mgr.Reservations(a, "tcp"); !reflect.DeepEqual(got, []string{"tcp://example.com:4000"}) {
+		t.Errorf("got tcp reservations %q", got)
+	}
+
+	// using the older url again puts it first, without a second copy
+	age(a, "http://two.example.com", time.Minute)
+	mgr.Reserve(a, "http", "http://one.example.com")
+	want = []string{"http://one.example.com", "http://two.example.com"}
+	if got := mgr.Reservations(a, "http"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got reservations %q after reusing one, want %q", got, want)
+	}
+
+	if len(db.saved) != 4 || len(db.saved[3].Reservations) != 3 {
+		t.Errorf("got %d saves, the last with %d reservations", len(db.saved), len(db.saved[len(db.saved)-1].Reservations))
+	}
+}
+
+func TestReservationsWithoutUser(t *testing.T) {
+	mgr, db := setupReservations(t)
+
+	mgr.Reserve(nil, "http", "http://one.example.com")
+	if got := mgr.Reservations(nil, "http"); len(got) != 0 {
+		t.Errorf("got reservations %q without a user", got)
+	}
+	if mgr.ReservedByOther(mgr.users["a"], "http://one.example.com") {
+		t.Error("a url was reserved for nobody")
+	}
+
+	// nor are urls reserved when they aren't kept
+	opts.reservationTtl = 0
+	mgr.Reserve(mgr.users["a"], "http", "http://one.example.com")
+	if len(db.saved) != 0 {
+		t.Errorf("saved %d configs", len(db.saved))
+	}
+}
+
+func TestReservedByOther(t *testing.T) {
+	mgr, _ := setupReservations(t)
+	a, b := mgr.users["a"], mgr.users["b"]
+
+	mgr.Reserve(a, "http", "http://one.example.com")
+	if !mgr.ReservedByOther(b, "http://one.example.com") {
+		t.Error("b can take a's url")
+	}
+	if !mgr.ReservedByOther(nil, "http://one.example.com") {
+		t.Error("a client without a user can take a's url")
+	}
+	if mgr.ReservedByOther(a, "http://one.example.com") {
+		t.Error("a can't use their own url")
+	}
+	if mgr.ReservedByOther(b, "http://two.example.com") {
+		t.Error("a url nobody reserved is taken")
+	}
+
+	// the url is released once it goes unused for the ttl
+	age(a, "http://one.example.com", 2*time.Hour)
+	if mgr.ReservedByOther(b, "http://one.example.com") {
+		t.Error("b can't take a's expired url")
+	}
+	if got := mgr.Reservations(a, "http"); len(got) != 0 {
+		t.Errorf("a still has expired reservations %q", got)
+	}
+
+	// and b keeps it from then on
+	mgr.Reserve(b, "http", "http://one.example.com")
+	if !mgr.ReservedByOther(a, "http://one.example.com") {
+		t.Error("a can take back the url b reserved")
+	}
+	mgr.Reserve(a, "http", "http://two.example.com")
+	if len(a.Uc.Reservations) != 1 || a.Uc.Reservations[0].Url != "http://two.example.com" {
+		t.Errorf("a kept the expired reservation: %+v", a.Uc.Reservations)
+	}
+}
+
+func TestReservationMax(t *testing.T) {
+	mgr, _ := setupReservations(t)
+	a, b := mgr.users["a"], mgr.users["b"]
+
+	for i := 0; i < reservationMax; i++ {
+		mgr.Reserve(a, "http", fmt.Sprintf("http://%d.example.com", i))
+		age(a, fmt.Sprintf("http://%d.example.com", i), time.Duration(reservationMax-i)*time.Minute)
+	}
+	mgr.Reserve(a, "http", "http://new.example.com")
+
+	got := mgr.Reservations(a, "http")
+	if len(got) != reservationMax || got[0] != "http://new.example.com" {
+		t.Fatalf("got reservations %q", got)
+	}
+
+	// the least recently used url was given up
+	if mgr.ReservedByOther(b, "http://0.example.com") {
+		t.Error("the dropped url is still reserved")
+	}
+	if !mgr.ReservedByOther(b, "http://1.example.com") {
+		t.Error("a kept url isn't reserved")
+	}
+}
+
+func TestTouchReservation(t *testing.T) {
+	mgr, db := setupReservations(t)
+	a := mgr.users["a"]
+
+	mgr.Reserve(a, "http", "http://one.example.com")
+	age(a, "http://one.example.com", 30*time.Minute)
+
+	mgr.TouchReservation(a, "http://one.example.com")
+	if time.Since(a.Uc.Reservations[0].LastUsed) > time.Minute {
+		t.Error("the reservation wasn't marked as used")
+	}
+	if len(db.saved) != 2 {
+		t.Errorf("got %d saves, want 2", len(db.saved))
+	}
+
+	// urls the user didn't reserve aren't saved
+	mgr.TouchReservation(a, "http://other.example.com")
+	if len(db.saved) != 2 {
+		t.Error("touching an unreserved url saved the user")
+	}
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"ngrok/conn"
+	"strings"
+	"sync"
+)
+
+// Clean up the path prefix a tunnel asked for: /api/ and api both become
+// /api, and / becomes the empty prefix that serves the whole host
+func normalizePathPrefix(prefix string) (string, error) {
+	prefix = strings.TrimSpace(prefix)
+	if strings.ContainsAny(prefix, "?#* ") {
+		return "", fmt.Errorf("Invalid path prefix %s", prefix)
+	}
+
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return "", nil
+	}
+	return "/" + prefix, nil
+}
+
+// Splits a tunnel url into the host url and the path prefix it serves
+func splitRouteUrl(url string) (hostUrl, prefix string) {
+	i := strings.Index(url, "://")
+	if i < 0 {
+		return url, ""
+	}
+
+	if j := strings.Index(url[i+3:], "/"); j >= 0 {
+		return url[:i+3+j], url[i+3+j:]
+	}
+	return url, ""
+}
+
+// The prefixes a request path may be routed by, longest first and ending
+// with the empty prefix: /api/v1/users has /api/v1/users, /api/v1 and /api
+func pathPrefixes(path string) []string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+
+	// OPTIONS * and other paths without a slash only match the whole host
+	var prefixes []string
+	for path = strings.TrimRight(path, "/"); strings.HasPrefix(path, "/"); path = path[:strings.LastIndex(path, "/")] {
+		prefixes = append(prefixes, path)
+	}
+	return append(prefixes, "")
+}
+
+// The tunnel urls that may serve a request, most specific host first and for
+// each host the longest path prefix first
+func routeUrls(hostUrl, path string) (urls []string) {
+	prefixes := pathPrefixes(path)
+	for _, host := range matchingUrls(hostUrl) {
+		for _, prefix := range prefixes {
+			urls = append(urls, host+prefix)
+		}
+	}
+	return
+}
+
+// A public connection whose first request head was rewritten
+type rewrittenConn struct {
+	conn.Conn
+	rd io.Reader
+
+	// set if the request asks for an upgrade
+	upgrade *upgradeGuard
+}
+
+func (c *rewrittenConn) Read(p []byte) (int, error) {
+	return c.rd.Read(p)
+}
+
+// Holds back what a client sends after a request asking for an upgrade until
+// the response shows whether the connection was upgraded. If it wasn't, the
+// rest is dropped and the connection closed after the response, so that no
+// further request gets past the routing and the login gate unchecked.
+type upgradeGuard struct {
+	method   string
+	once     sync.Once
+	decided  chan struct{}
+	upgraded bool
+}
+
+func newUpgradeGuard(method string) *upgradeGuard {
+	return &upgradeGuard{method: method, decided: make(chan struct{})}
+}
+
+func (g *upgradeGuard) decide(upgraded bool) {
+	g.once.Do(func() {
+		g.upgraded = upgraded
+		close(g.decided)
+	})
+}
+
+// The rest of what the client sends, once the connection was upgraded
+func (g *upgradeGuard) requests(rd io.Reader) io.Reader {
+	return readerFunc(func(p []byte) (int, error) {
+		<-g.decided
+		if !g.upgraded {
+			io.Copy(ioutil.Discard, rd)
+			return 0, io.EOF
+		}
+		return rd.Read(p)
+	})
+}
+
+// Watch the responses coming from the tunnel for whether the upgrade happened
+func (g *upgradeGuard) watch(c conn.Conn) conn.Conn {
+	return &upgradeWatcher{Conn: c, guard: g}
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+type upgradeWatcher struct {
+	conn.Conn
+	guard *upgradeGuard
+	rd    io.Reader
+}
+
+func (w *upgradeWatcher) Read(p []byte) (int, error) {
+	if w.rd == nil {
+		w.rd = w.response()
+	}
+	return w.rd.Read(p)
+}
+
+// Read the first response. An upgrade passes through as it came, anything
+// else is sent on with the connection closed after it.
+func (w *upgradeWatcher) response() io.Reader {
+	var raw bytes.Buffer
+	br := bufio.NewReader(io.TeeReader(w.Conn, &raw))
+	resp, err := http.ReadResponse(br, &http.Request{Method: w.guard.method})
+	if err != nil {
+		w.guard.decide(false)
+		return io.MultiReader(&raw, readerFunc(func([]byte) (int, error) { return 0, err }))
+	}
+
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		w.guard.decide(true)
+		return io.MultiReader(&raw, w.Conn)
+	}
+	w.guard.decide(false)
+
+	resp.Close = true
+	resp.Header.Set("Connection", "close")
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(resp.Write(pw))
+	}()
+	return pr
+}
+
+// Rewrite the head of the first request on a connection to a host that is
+// routed by path, or to a tunnel behind the login gate. The tunnel's prefix is
+// stripped if it asked for that, headers are set, replacing the request's own
+// of the same name, and the connection is closed after the response so that
+// the next request is routed and checked again, unless it's upgraded, e.g. to
+// a websocket.
+func rewriteRequest(c conn.Conn, t *Tunnel, headers map[string]string) (conn.Conn, error) {
+	rd := bufio.NewReader(c)
+	requestLine, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	// GET /api/users?page=2 HTTP/1.1
+	parts := strings.SplitN(strings.TrimRight(requestLine, "\r\n"), " ", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("Malformed request line %q", requestLine)
+	}
+
+	var head bytes.Buffer
+	prefix := t.req.PathPrefix
+	if t.req.StripPrefix && prefix != "" && strings.HasPrefix(parts[1], prefix) {
+		target := parts[1][len(prefix):]
+		if !strings.HasPrefix(target, "/") {
+			target = "/" + target
+		}
+		fmt.Fprintf(&head, "%s %s %s\r\n", parts[0], target, parts[2])
+		fmt.Fprintf(&head, "X-Forwarded-Prefix: %s\r\n", prefix)
+	} else {
+		head.WriteString(requestLine)
+	}
+
+	replaced := make(map[string]bool)
+	for name := range headers {
+		replaced[strings.ToLower(name)] = true
+	}
+
+	upgrade, body := false, false
+	var connHeaders []string
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		if line == "\r\n" || line == "\n" {
+			break
+		}
+
+		name := strings.ToLower(strings.TrimSpace(strings.SplitN(line, ":", 2)[0]))
+		switch name {
+		case "upgrade":
+			upgrade = true
+		case "content-length":
+			body = body || strings.TrimSpace(strings.SplitN(line, ":", 2)[1]) != "0"
+		case "transfer-encoding":
+			body = true
+		case "connection":
+			connHeaders = append(connHeaders, line)
+			continue
+		}
+
+		if _, ok := replaced[name]; ok {
+			continue
+		}
+		head.WriteString(line)
+	}
+
+	for name, value := range headers {
+		fmt.Fprintf(&head, "%s: %s\r\n", name, value)
+	}
+
+	// what follows a request with a body can't be held back from the tunnel,
+	// so such a request isn't upgraded
+	if upgrade && !body {
+		for _, line := range connHeaders {
+			head.WriteString(line)
+		}
+		head.WriteString("\r\n")
+
+		guard := newUpgradeGuard(parts[0])
+		return &rewrittenConn{Conn: c, rd: io.MultiReader(&head, guard.requests(rd)), upgrade: guard}, nil
+	}
+
+	head.WriteString("Connection: close\r\n\r\n")
+	return &rewrittenConn{Conn: c, rd: io.MultiReader(&head, rd)}, nil
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"ngrok/conn"
+	"ngrok/log"
+	"ngrok/msg"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPathPrefixes(t *testing.T) {
+	cases := []struct {
+		path string
+		want []string
+	}{
+		{"/api/v1/users?page=2", []string{"/api/v1/users", "/api/v1", "/api", ""}},
+		{"/api/#top", []string{"/api", ""}},
+		{"/", []string{""}},
+		{"", []string{""}},
+
+		// request targets that aren't paths only match the whole host
+		{"*", []string{""}},
+		{"abc", []string{""}},
+		{"abc/def", []string{""}},
+	}
+
+	for _, c := range cases {
+		if got := pathPrefixes(c.path); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("pathPrefixes(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
+
+func TestRouteLongestPrefix(t *testing.T) {
+	r := &TunnelRegistry{tunnels: make(map[string]*TunnelPool), routed: make(map[string]int), Logger: log.NewPrefixLogger("registry")}
+	for _, url := range []string{
+		"http://a.example.com",
+		"http://a.example.com/api",
+		"http://a.example.com/api/v1",
+		"http://*.example.com/docs",
+	} {
+		if err := r.Register(url, testTunnel(url)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		host, path string
+		want       string
+	}{
+		{"http://a.example.com", "/api/v1/users", "http://a.example.com/api/v1"},
+		{"http://a.example.com", "/api/v2", "http://a.example.com/api"},
+		{"http://a.example.com", "/api", "http://a.example.com/api"},
+		{"http://a.example.com", "/apis", "http://a.example.com"},
+		{"http://a.example.com", "/", "http://a.example.com"},
+		{"http://a.example.com", "*", "http://a.example.com"},
+		{"http://a.example.com", "abc", "http://a.example.com"},
+		{"http://b.example.com", "/docs/intro", "http://*.example.com/docs"},
+		{"http://b.example.com", "/", ""},
+	}
+
+	for _, c := range cases {
+		tunnel, routed := r.Route(c.host, c.path)
+		got := ""
+		if tunnel != nil {
+			got = tunnel.url
+		}
+		if got != c.want {
+			t.Errorf("%s%s routed to %q, want %q", c.host, c.path, got, c.want)
+		}
+		if tunnel != nil && !routed {
+			t.Errorf("%s%s isn't reported as routed by path", c.host, c.path)
+		}
+	}
+}
+
+func TestRewriteRequestStripsPrefix(t *testing.T) {
+	cases := []struct {
+		strip       bool
+		target      string
+		want        string
+		forwardedAs string
+	}{
+		{true, "/api/users?page=2", "/users?page=2", "/api"},
+		{true, "/api", "/", "/api"},
+		{true, "/api/", "/", "/api"},
+		{false, "/api/users", "/api/users", ""},
+	}
+
+	for _, c := range cases {
+		tunnel := &Tunnel{req: &msg.ReqTunnel{PathPrefix: "/api", StripPrefix: c.strip}}
+		rewritten, err := rewriteRequest(publicConn(t, "GET "+c.target+" HTTP/1.1\r\nHost: a.example.com\r\n\r\n"), tunnel, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.ReadRequest(bufio.NewReader(rewritten))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.RequestURI != c.want {
+			t.Errorf("%s was rewritten to %s, want %s", c.target, req.RequestURI, c.want)
+		}
+		if got := req.Header.Get("X-Forwarded-Prefix"); got != c.forwardedAs {
+			t.Errorf("%s has X-Forwarded-Prefix %q, want %q", c.target, got, c.forwardedAs)
+		}
+		if req.Host != "a.example.com" {
+			t.Errorf("%s lost its host, got %q", c.target, req.Host)
+		}
+	}
+}
+
+func tcpPipe(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	a, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, b
+}
+
+// A connection that the other end writes raw to and then closes
+func sentConn(t *testing.T, typ, raw string) conn.Conn {
+	sender, c := tcpPipe(t)
+	go func() {
+		sender.Write([]byte(raw))
+		sender.Close()
+	}()
+	return conn.Wrap(c, typ)
+}
+
+// A public connection that a client writes raw to
+func publicConn(t *testing.T, raw string) conn.Conn {
+	return sentConn(t, "pub", raw)
+}
+
+// The tunnel's side of a connection, answering with raw
+func tunnelConn(t *testing.T, raw string) conn.Conn {
+	return sentConn(t, "pxy", raw)
+}
+
+func TestDeclinedUpgradeClosesConnection(t *testing.T) {
+	tunnel := &Tunnel{req: &msg.ReqTunnel{PathPrefix: "/ws"}}
+
+	c := publicConn(t, "GET /ws HTTP/1.1\r\nHost: app.example.com\r\nUpgrade: x\r\nConnection: keep-alive\r\n\r\n"+
+		"GET /admin HTTP/1.1\r\nHost: app.example.com\r\n\r\n")
+
+	rewritten, err := rewriteRequest(c, tunnel, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(rewritten)
+	if _, err = http.ReadRequest(br); err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := rewritten.(*rewrittenConn).upgrade.watch(tunnelConn(t, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"))
+	resp, err := http.ReadResponse(bufio.NewReader(proxy), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Close {
+		t.Error("a declined upgrade doesn't close the connection")
+	}
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != "ok" {
+		t.Errorf("got body %q", body)
+	}
+
+	// the second request never reaches the tunnel
+	if rest, _ := ioutil.ReadAll(br); len(rest) != 0 {
+		t.Errorf("forwarded %q after a declined upgrade", rest)
+	}
+}
+
+func TestAcceptedUpgradePassesThrough(t *testing.T) {
+	tunnel := &Tunnel{req: &msg.ReqTunnel{PathPrefix: "/ws"}}
+
+	c := publicConn(t, "GET /ws HTTP/1.1\r\nHost: app.example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\nframes")
+
+	rewritten, err := rewriteRequest(c, tunnel, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(rewritten)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.EqualFold(req.Header.Get("Connection"), "upgrade") {
+		t.Fatalf("Connection is %q, want the client's", req.Header.Get("Connection"))
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\nserver frames"
+	proxy := rewritten.(*rewrittenConn).upgrade.watch(tunnelConn(t, response))
+	if got, _ := ioutil.ReadAll(proxy); string(got) != response {
+		t.Errorf("tunnel sent %q, got %q", response, got)
+	}
+
+	if rest, _ := ioutil.ReadAll(br); string(rest) != "frames" {
+		t.Errorf("got %q after the upgrade, want the client's frames", rest)
+	}
+}
+
+func TestUpgradeWithBodyIsNotUpgraded(t *testing.T) {
+	tunnel := &Tunnel{req: &msg.ReqTunnel{PathPrefix: "/ws"}}
+
+	c := publicConn(t, "POST /ws HTTP/1.1\r\nHost: app.example.com\r\nUpgrade: x\r\nConnection: Upgrade\r\nContent-Length: 2\r\n\r\nhi")
+
+	rewritten, err := rewriteRequest(c, tunnel, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.ReadRequest(bufio.NewReader(rewritten))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !req.Close || rewritten.(*rewrittenConn).upgrade != nil {
+		t.Error("a request with a body was let upgrade")
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"crypto/tls"
 	"io/ioutil"
 	"ngrok/server/assets"
@@ -44,3 +45,13 @@ func LoadTLSConfig(crtPath string, keyPath string) (tlsConfig *tls.Config, err e
 
 	return
 }
+
+// Returns true if tlsConfig serves the snakeoil certificate built into
+// ngrokd, whose key is public
+func isDefaultCertificate(tlsConfig *tls.Config) bool {
+	snakeoil, err := LoadTLSConfig("", "")
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(tlsConfig.Certificates[0].Certificate[0], snakeoil.Certificates[0].Certificate[0])
+}
@@ -1,11 +1,13 @@
 package server
 
 import (
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"math/rand"
 	"net"
 	"ngrok/conn"
+	"ngrok/errorpage"
 	"ngrok/log"
 	"ngrok/msg"
 	"ngrok/util"
@@ -36,9 +38,19 @@ type Tunnel struct {
 	// public url
 	url string
 
-	// tcp listener
+	// tcp listener, owned by the tunnel's pool once registered
 	listener *net.TCPListener
 
+	// custom hostname waiting for its HTTP challenge, only the challenge
+	// is served until it passes
+	unverified string
+
+	// the Authorization headers accepted when the tunnel asked for basic auth
+	auths []string
+
+	// the pages sent on the tunnel's behalf when a request can't reach it
+	pages *errorpage.Pages
+
 	// control connection
 	ctl *Control
 
@@ -47,6 +59,11 @@ type Tunnel struct {
 
 	// closing
 	closing int32
+
+	// public connection stats, accessed atomically
+	activeConns int64
+	bytesIn     int64
+	bytesOut    int64
 }
 
 // Common functionality for registering virtually hosted protocols
@@ -70,17 +87,32 @@ func registerVhost(t *Tunnel, protocol string, servingPort int) (err error) {
 	// Canonicalize by always using lower-case
 	vhost = strings.ToLower(vhost)
 
-	// Register for specific hostname
 	hostname := strings.ToLower(strings.TrimSpace(t.req.Hostname))
+	subdomain := strings.ToLower(strings.TrimSpace(t.req.Subdomain))
+
+	// a tunnel may serve only the paths under a prefix of its host
+	if t.req.PathPrefix, err = normalizePathPrefix(t.req.PathPrefix); err != nil {
+		return
+	}
+	if t.req.PathPrefix != "" && hostname == "" && subdomain == "" {
+		return fmt.Errorf("A path prefix needs a hostname or subdomain to share")
+	}
+
+	// Register for specific hostname
 	if hostname != "" {
-		t.url = fmt.Sprintf("%s://%s", protocol, hostname)
+		if err = validateWildcard(hostname); err != nil {
+			return
+		}
+		t.url = fmt.Sprintf("%s://%s%s", protocol, hostname, t.req.PathPrefix)
 		return tunnelRegistry.Register(t.url, t)
 	}
 
 	// Register for specific subdomain
-	subdomain := strings.ToLower(strings.TrimSpace(t.req.Subdomain))
 	if subdomain != "" {
-		t.url = fmt.Sprintf("%s://%s.%s", protocol, subdomain, vhost)
+		if err = validateWildcard(subdomain); err != nil {
+			return
+		}
+		t.url = fmt.Sprintf("%s://%s.%s%s", protocol, subdomain, vhost, t.req.PathPrefix)
 		return tunnelRegistry.Register(t.url, t)
 	}
 
@@ -94,19 +126,38 @@ func registerVhost(t *Tunnel, protocol string, servingPort int) (err error) {
 
 // Create a new tunnel from a registration message received
 // on a control channel
-func NewTunnel(m *msg.ReqTunnel, ctl *Control) (t *Tunnel, err error) {
+func NewTunnel(m *msg.ReqTunnel, ctl *Control, unverified string) (t *Tunnel, err error) {
 	t = &Tunnel{
-		req:    m,
-		start:  time.Now(),
-		ctl:    ctl,
-		Logger: log.NewPrefixLogger(),
+		req:        m,
+		start:      time.Now(),
+		ctl:        ctl,
+		unverified: unverified,
+		Logger:     log.NewPrefixLogger(),
+	}
+
+	if err = validateBalance(t.req.Balance); err != nil {
+		return
+	}
+
+	if t.pages, err = tunnelErrorPages(t.req.ErrorPages); err != nil {
+		return
+	}
+
+	if t.req.OidcAuth {
+		if oidcGate == nil {
+			err = fmt.Errorf("This server has no login provider")
+			return
+		} else if t.req.Protocol == "tcp" {
+			err = fmt.Errorf("TCP tunnels can't require a login")
+			return
+		}
 	}
 
 	proto := t.req.Protocol
 	switch proto {
 	case "tcp":
 		bindTcp := func(port int) error {
-			if t.listener, err = net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("0.0.0.0"), Port: port}); err != nil {
+			if t.listener, err = listenTcpPort(port); err != nil {
 				err = t.ctl.conn.Error("Error binding TCP listener: %v", err)
 				return err
 			}
@@ -115,6 +166,13 @@ func NewTunnel(m *msg.ReqTunnel, ctl *Control) (t *Tunnel, err error) {
 			addr := t.listener.Addr().(*net.TCPAddr)
 			t.url = fmt.Sprintf("tcp://%s:%d", opts.domain, addr.Port)
 
+			// a port we picked may be reserved for a user who is offline
+			if t.req.RemotePort == 0 && cMgr.ReservedByOther(t.ctl.userInfo, t.url) {
+				t.listener.Close()
+				err = fmt.Errorf("Port %d is reserved for another user", addr.Port)
+				return err
+			}
+
 			// register it
 			if err = tunnelRegistry.RegisterAndCache(t.url, t); err != nil {
 				// This should never be possible because the OS will
@@ -124,14 +182,26 @@ func NewTunnel(m *msg.ReqTunnel, ctl *Control) (t *Tunnel, err error) {
 				return err
 			}
 
-			go t.listenTcp(t.listener)
 			return nil
 		}
 
+		// share the port with the other tunnels of a pool that is already listening
+		if t.req.RemotePort != 0 && t.req.Balance != "" {
+			t.url = fmt.Sprintf("tcp://%s:%d", opts.domain, t.req.RemotePort)
+			if len(tunnelRegistry.Pool(t.url)) > 0 {
+				if err = tunnelRegistry.Join(t.url, t); err != nil {
+					return
+				}
+				break
+			}
+		}
+
 		// use the custom remote port you asked for
 		if t.req.RemotePort != 0 {
-			bindTcp(int(t.req.RemotePort))
-			return
+			if err = bindTcp(int(t.req.RemotePort)); err != nil {
+				return
+			}
+			break
 		}
 
 		// try to return to you the same port you had before
@@ -149,14 +219,20 @@ func NewTunnel(m *msg.ReqTunnel, ctl *Control) (t *Tunnel, err error) {
 					t.ctl.conn.Warn("Failed to get custom port %d: %v, trying a random one", port, err)
 				} else {
 					// success, we're done
-					return
+					break
 				}
 			}
 		}
 
 		// Bind for TCP connections
-		bindTcp(0)
-		return
+		for i := 0; i < 5; i++ {
+			if err = bindTcp(0); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return
+		}
 
 	case "http", "https":
 		l, ok := listeners[proto]
@@ -175,14 +251,17 @@ func NewTunnel(m *msg.ReqTunnel, ctl *Control) (t *Tunnel, err error) {
 	}
 
 	// pre-encode the http basic auth for fast comparisons later
-	if m.HttpAuth != "" {
-		m.HttpAuth = "Basic " + base64.StdEncoding.EncodeToString([]byte(m.HttpAuth))
+	for _, creds := range append([]string{m.HttpAuth}, m.HttpAuths...) {
+		if creds != "" {
+			t.auths = append(t.auths, "Basic "+base64.StdEncoding.EncodeToString([]byte(creds)))
+		}
 	}
 
 	t.AddLogPrefix(t.Id())
 	t.Info("Registered new tunnel on: %s", t.ctl.conn.Id())
 
 	metrics.OpenTunnel(t)
+	emitEvent(newTunnelLifecycleEvent(EventTunnelOpened, t))
 	return
 }
 
@@ -192,13 +271,10 @@ func (t *Tunnel) Shutdown() {
 	// mark that we're shutting down
 	atomic.StoreInt32(&t.closing, 1)
 
-	// if we have a public listener (this is a raw TCP tunnel), shut it down
-	if t.listener != nil {
-		t.listener.Close()
-	}
-
-	// remove ourselves from the tunnel registry
-	tunnelRegistry.Del(t.url)
+	// remove ourselves from the tunnel registry, this closes the public
+	// listener of a raw TCP tunnel when no other tunnel shares it
+	tunnelRegistry.Del(t.url, t)
+	cMgr.TouchReservation(t.ctl.userInfo, t.url)
 
 	// let the control connection know we're shutting down
 	// currently, only the control connection shuts down tunnels,
@@ -206,43 +282,37 @@ func (t *Tunnel) Shutdown() {
 	// t.ctl.stoptunnel <- t
 
 	metrics.CloseTunnel(t)
+	emitEvent(newTunnelLifecycleEvent(EventTunnelClosed, t))
 }
 
-func (t *Tunnel) Id() string {
-	return t.url
+// Returns true if a request for path must be refused because the tunnel's
+// hostname hasn't been verified yet and path isn't its challenge
+func (t *Tunnel) challengeOnly(path string) bool {
+	return t.unverified != "" && !strings.HasPrefix(path, challengePath) && !cMgr.HostnameAllowed(t.ctl.userInfo, t.unverified)
 }
 
-// Listens for new public tcp connections from the internet.
-func (t *Tunnel) listenTcp(listener *net.TCPListener) {
-	for {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Warn("listenTcp failed with error %v", r)
-			}
-		}()
-
-		// accept public connections
-		tcpConn, err := listener.AcceptTCP()
-
-		if err != nil {
-			// not an error, we're shutting down this tunnel
-			if atomic.LoadInt32(&t.closing) == 1 {
-				return
-			}
+// Returns true if a request with this Authorization header may use the tunnel
+func (t *Tunnel) authorized(auth string) bool {
+	if len(t.auths) == 0 {
+		return true
+	}
 
-			t.Error("Failed to accept new TCP connection: %v", err)
-			continue
+	for _, accepted := range t.auths {
+		if subtle.ConstantTimeCompare([]byte(auth), []byte(accepted)) == 1 {
+			return true
 		}
-
-		conn := conn.Wrap(tcpConn, "pub")
-		conn.AddLogPrefix(t.Id())
-		conn.Info("New connection from %v", conn.RemoteAddr())
-
-		go t.HandlePublicConnection(conn)
 	}
+	return false
+}
+
+func (t *Tunnel) Id() string {
+	return t.url
 }
 
-func (t *Tunnel) HandlePublicConnection(publicConn conn.Conn) {
+// Proxy a public connection through this tunnel's client. The access log
+// entry is written once the connection closes, or for http connections
+// each request is logged once its response is through.
+func (t *Tunnel) HandlePublicConnection(publicConn conn.Conn, entry *AccessLogEntry) {
 	defer publicConn.Close()
 	defer func() {
 		if r := recover(); r != nil {
@@ -252,6 +322,25 @@ func (t *Tunnel) HandlePublicConnection(publicConn conn.Conn) {
 
 	startTime := time.Now()
 	metrics.OpenConnection(t, publicConn)
+	defer trackPublicConn()()
+
+	atomic.AddInt64(&t.activeConns, 1)
+	defer atomic.AddInt64(&t.activeConns, -1)
+
+	// always report the close, even if we never got a proxy connection,
+	// so that open connection gauges stay accurate
+	var bytesIn, bytesOut int64
+	defer func() { metrics.CloseConnection(t, publicConn, startTime, bytesIn, bytesOut) }()
+
+	var requests *requestLogger
+	entry.setTunnel(t)
+	defer func() {
+		if requests != nil && requests.finish() > 0 {
+			return
+		}
+		entry.BytesIn, entry.BytesOut = bytesIn, bytesOut
+		accessLog.Log(entry)
+	}()
 
 	var proxyConn conn.Conn
 	var err error
@@ -259,6 +348,7 @@ func (t *Tunnel) HandlePublicConnection(publicConn conn.Conn) {
 		// get a proxy connection
 		if proxyConn, err = t.ctl.GetProxy(); err != nil {
 			t.Warn("Failed to get proxy connection: %v", err)
+			entry.Error = err.Error()
 			return
 		}
 		defer proxyConn.Close()
@@ -268,7 +358,7 @@ func (t *Tunnel) HandlePublicConnection(publicConn conn.Conn) {
 		// tell the client we're going to start using this proxy connection
 		startPxyMsg := &msg.StartProxy{
 			Url:        t.url,
-			ClientAddr: publicConn.RemoteAddr().String(),
+			ClientAddr: entry.RemoteAddr,
 		}
 
 		if err = msg.WriteMsg(proxyConn, startPxyMsg); err != nil {
@@ -283,8 +373,10 @@ func (t *Tunnel) HandlePublicConnection(publicConn conn.Conn) {
 	if err != nil {
 		// give up
 		publicConn.Error("Too many failures starting proxy connection")
+		entry.Error = "Too many failures starting proxy connection"
 		return
 	}
+	entry.ProxyWait = time.Since(startTime).Seconds()
 
 	// To reduce latency handling tunnel connections, we employ the following curde heuristic:
 	// Whenever we take a proxy connection from the pool, replace it with a new one
@@ -293,11 +385,26 @@ func (t *Tunnel) HandlePublicConnection(publicConn conn.Conn) {
 	// no timeouts while connections are joined
 	proxyConn.SetDeadline(time.Time{})
 
+	// only an accepted upgrade keeps a rewritten connection open
+	if rewritten, ok := publicConn.(*rewrittenConn); ok && rewritten.upgrade != nil {
+		proxyConn = rewritten.upgrade.watch(proxyConn)
+	}
+
+	// log every request on the connection
+	joined := publicConn
+	if accessLog != nil && entry.Method != "" {
+		requests = newRequestLogger(accessLog, entry)
+		joined, proxyConn = requests.watch(publicConn, proxyConn)
+	}
+
 	// join the public and proxy connections
-	bytesIn, bytesOut := conn.Join(publicConn, proxyConn)
-	metrics.CloseConnection(t, publicConn, startTime, bytesIn, bytesOut)
+	bytesIn, bytesOut = conn.Join(joined, proxyConn)
+	atomic.AddInt64(&t.bytesIn, bytesIn)
+	atomic.AddInt64(&t.bytesOut, bytesOut)
 
 	//log.Info("Proxy authId=%s bytesIn=%d, bytesOut=%d\n", t.ctl.userInfo.Uc.UserId, bytesIn, bytesOut)
-	atomic.AddInt32(&t.ctl.userInfo.TransPerDay, int32(bytesIn+bytesOut))
-	atomic.AddInt32(&t.ctl.userInfo.TransAll, int32(bytesIn+bytesOut))
+	if t.ctl.userInfo != nil {
+		atomic.AddInt32(&t.ctl.userInfo.TransPerDay, int32(bytesIn+bytesOut))
+		atomic.AddInt32(&t.ctl.userInfo.TransAll, int32(bytesIn+bytesOut))
+	}
 }